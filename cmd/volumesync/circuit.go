@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// circuitBreaker stops a job from running again and again against a
+// destination that keeps failing it. After threshold failed runs in a row the
+// circuit opens and runs are skipped until cooldown has passed. The next run
+// is then let through as a trial: the circuit closes again if it succeeds and
+// opens for another cooldown if it fails.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int
+	// openedAt is when the circuit last opened, zero while it's closed.
+	openedAt time.Time
+	// trial is set while the run let through an open circuit is in flight.
+	trial bool
+}
+
+// newCircuitBreaker returns the breaker of the job name, or nil, which lets
+// every run through, when threshold is zero.
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow reports whether a run may go ahead.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.openedAt.IsZero():
+		return true
+	case b.trial:
+		return false
+	case b.now().Sub(b.openedAt) < b.cooldown:
+		slog.Warn("Circuit open after repeated failures, skipping this run", "volume", b.name, "failures", b.failures, "retry_after", b.openedAt.Add(b.cooldown).Format(time.RFC3339))
+		return false
+	}
+	slog.Info("Circuit half-open, trying a run", "volume", b.name)
+	b.trial = true
+	return true
+}
+
+// record takes in the outcome of a run that allow let through.
+func (b *circuitBreaker) record(success bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := !b.openedAt.IsZero()
+	b.trial = false
+	if success {
+		if wasOpen {
+			slog.Info("Circuit closed", "volume", b.name)
+		}
+		b.failures = 0
+		b.openedAt = time.Time{}
+		return
+	}
+	b.failures++
+	if wasOpen || b.failures >= b.threshold {
+		if !wasOpen {
+			slog.Error("Circuit opened after repeated failures", "volume", b.name, "failures", b.failures, "cooldown", b.cooldown)
+		}
+		b.openedAt = b.now()
+	}
+}
+
+// withCircuitBreaker wraps a job so that its runs are skipped while b is
+// open. The outcome of each run must be passed to b.record.
+func withCircuitBreaker(b *circuitBreaker, job func()) func() {
+	if b == nil {
+		return job
+	}
+	return func() {
+		if b.allow() {
+			job()
+		}
+	}
+}
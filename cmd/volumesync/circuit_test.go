@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker("vol", 2, time.Hour)
+	b.now = func() time.Time { return now }
+	runs := 0
+	run := withCircuitBreaker(b, func() { runs++ })
+	// tryRun runs the job if the breaker lets it, recording the outcome as
+	// the job's onDone would, and reports whether it ran.
+	tryRun := func(success bool) bool {
+		before := runs
+		run()
+		if runs == before {
+			return false
+		}
+		b.record(success)
+		return true
+	}
+
+	// Closed: a single failure leaves it closed, the second one opens it.
+	require.True(t, tryRun(false))
+	require.True(t, tryRun(false))
+
+	// Open: runs are skipped until the cooldown has passed.
+	require.False(t, tryRun(true))
+	now = now.Add(59 * time.Minute)
+	require.False(t, tryRun(true))
+
+	// Half-open: one trial run, which failing opens the circuit again for
+	// another cooldown.
+	now = now.Add(time.Minute)
+	require.True(t, tryRun(false))
+	require.False(t, tryRun(true))
+
+	// A successful trial closes it, and it takes the threshold of failures
+	// to open again.
+	now = now.Add(time.Hour)
+	require.True(t, tryRun(true))
+	require.True(t, tryRun(false))
+	require.True(t, tryRun(true))
+	require.True(t, tryRun(false))
+	require.True(t, tryRun(false))
+	require.False(t, tryRun(true))
+}
+
+func TestCircuitBreaker_OneTrialAtATime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker("vol", 1, time.Hour)
+	b.now = func() time.Time { return now }
+	require.True(t, b.allow())
+	b.record(false)
+
+	now = now.Add(time.Hour)
+	require.True(t, b.allow())
+	require.False(t, b.allow(), "a second run while the trial is in flight")
+	b.record(true)
+	require.True(t, b.allow())
+}
+
+func TestCircuitBreaker_Disabled(t *testing.T) {
+	b := newCircuitBreaker("vol", 0, time.Hour)
+	require.Nil(t, b)
+	for range 10 {
+		require.True(t, b.allow())
+		b.record(false)
+	}
+}
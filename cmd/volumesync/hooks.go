@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+)
+
+// commandRunner runs a shell command with extra environment variables,
+// returning its combined output.
+type commandRunner func(ctx context.Context, command string, env []string) ([]byte, error)
+
+// runShell runs command with sh, in the environment of this process plus env.
+// A command still running when ctx is done is killed.
+func runShell(ctx context.Context, command string, env []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	// Don't wait for children of a killed command that hold its output open.
+	cmd.WaitDelay = time.Second
+	return cmd.CombinedOutput()
+}
+
+// syncHooks are the commands run around each backup.
+type syncHooks struct {
+	pre, post string
+	timeout   time.Duration
+	// abortOnPreFailure skips the backup when the pre-sync hook fails.
+	abortOnPreFailure bool
+	run               commandRunner
+}
+
+// newSyncHooks returns the configured hooks, or nil if there are none.
+func newSyncHooks(globalCfg *config.GlobalConfig) *syncHooks {
+	if globalCfg.PreSyncHook == "" && globalCfg.PostSyncHook == "" {
+		return nil
+	}
+	return &syncHooks{
+		pre:               globalCfg.PreSyncHook,
+		post:              globalCfg.PostSyncHook,
+		timeout:           globalCfg.HookTimeout,
+		abortOnPreFailure: globalCfg.PreSyncHookAbort,
+		run:               runShell,
+	}
+}
+
+// beforeSync runs the pre-sync hook for a volume mounted at localPath,
+// returning an error if the backup should not go ahead.
+func (h *syncHooks) beforeSync(ctx context.Context, volume, localPath string) error {
+	if h == nil || h.pre == "" {
+		return nil
+	}
+	err := h.runHook(ctx, "pre-sync", h.pre, volume, hookEnv(volume, localPath))
+	if err != nil && !h.abortOnPreFailure {
+		slog.Warn("Pre-sync hook failed, backing up anyway", "volume", volume, "error", err)
+		return nil
+	}
+	return err
+}
+
+// afterSync runs the post-sync hook, telling it how the backup went. Its
+// failure is only logged, as the backup is over by then.
+func (h *syncHooks) afterSync(ctx context.Context, volume, localPath, status string) {
+	if h == nil || h.post == "" {
+		return
+	}
+	env := append(hookEnv(volume, localPath), "VOLUMESYNC_STATUS="+status)
+	if err := h.runHook(ctx, "post-sync", h.post, volume, env); err != nil {
+		slog.Error("Post-sync hook failed", "volume", volume, "error", err)
+	}
+}
+
+// runHook runs a hook command for up to the hook timeout and logs its output.
+func (h *syncHooks) runHook(ctx context.Context, name, command, volume string, env []string) error {
+	slog.Info("Running hook", "hook", name, "volume", volume)
+	hookCtx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	out, err := h.run(hookCtx, command, env)
+	if output := strings.TrimSpace(string(out)); output != "" {
+		slog.Info("Hook output", "hook", name, "volume", volume, "output", output)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(hookCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s hook timed out after %s", name, h.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %w", name, err)
+	}
+	return nil
+}
+
+// hookEnv returns the environment variables describing a backup to its hooks.
+func hookEnv(volume, localPath string) []string {
+	return []string{"VOLUMESYNC_VOLUME=" + volume, "VOLUMESYNC_PATH=" + localPath}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+	"github.com/dedalusj/docker-volume-sync/internal/notify"
+	"github.com/stretchr/testify/require"
+)
+
+// hookCall is a hook command run by stubRunner.
+type hookCall struct {
+	command string
+	env     []string
+}
+
+// stubRunner records the commands it is asked to run in place of a shell,
+// failing those listed in fail.
+type stubRunner struct {
+	calls []hookCall
+	fail  map[string]error
+}
+
+func (r *stubRunner) run(ctx context.Context, command string, env []string) ([]byte, error) {
+	r.calls = append(r.calls, hookCall{command: command, env: env})
+	return []byte("output of " + command), r.fail[command]
+}
+
+func TestSyncJob_Hooks(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		ContainerIDs:  []string{"app"},
+	}
+
+	tests := []struct {
+		name       string
+		fail       map[string]error
+		abort      bool
+		wantSync   bool
+		wantStatus string
+		wantCalls  []string
+	}{
+		{
+			name:       "Success",
+			abort:      true,
+			wantSync:   true,
+			wantStatus: notify.StatusSuccess,
+			wantCalls:  []string{"pre", "post"},
+		},
+		{
+			name:       "PreHookFails",
+			fail:       map[string]error{"pre": errors.New("exit status 1")},
+			abort:      true,
+			wantStatus: notify.StatusFailure,
+			wantCalls:  []string{"pre"},
+		},
+		{
+			name:       "PreHookFailsWithoutAbort",
+			fail:       map[string]error{"pre": errors.New("exit status 1")},
+			wantSync:   true,
+			wantStatus: notify.StatusSuccess,
+			wantCalls:  []string{"pre", "post"},
+		},
+		{
+			// The backup is over by the time the post-sync hook runs.
+			name:       "PostHookFails",
+			fail:       map[string]error{"post": errors.New("exit status 1")},
+			abort:      true,
+			wantSync:   true,
+			wantStatus: notify.StatusSuccess,
+			wantCalls:  []string{"pre", "post"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &stubRunner{fail: tt.fail}
+			hooks := &syncHooks{pre: "pre", post: "post", timeout: time.Minute, abortOnPreFailure: tt.abort, run: runner.run}
+			mgr := &fakeManager{}
+
+			synced := false
+			var startedBeforeSync []string
+			sync := func() error {
+				synced = true
+				// The pre-sync hook has run, with the app still up.
+				require.Len(t, runner.calls, 1)
+				startedBeforeSync = mgr.startedContainers()
+				return nil
+			}
+			var got notify.Event
+			syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: sync}, config.StopFailureAbort, config.QuiesceStop, 0, hooks, newStoppedContainers(), func(ev notify.Event) { got = ev })()
+
+			require.Equal(t, tt.wantSync, synced)
+			require.Empty(t, startedBeforeSync)
+			require.Equal(t, tt.wantStatus, got.Status)
+
+			var commands []string
+			for _, call := range runner.calls {
+				commands = append(commands, call.command)
+				require.Contains(t, call.env, "VOLUMESYNC_VOLUME=vol")
+				require.Contains(t, call.env, "VOLUMESYNC_PATH=/volumes/vol")
+			}
+			require.Equal(t, tt.wantCalls, commands)
+			if tt.wantSync {
+				// The post-sync hook runs once the containers are back, and
+				// is told how the backup went.
+				require.Equal(t, []string{"app"}, mgr.startedContainers())
+				require.Contains(t, runner.calls[1].env, "VOLUMESYNC_STATUS="+tt.wantStatus)
+			} else {
+				require.Contains(t, got.Error, "pre-sync hook failed")
+			}
+		})
+	}
+}
+
+func TestSyncHooks_Timeout(t *testing.T) {
+	hooks := &syncHooks{
+		pre:               "sleep",
+		timeout:           50 * time.Millisecond,
+		abortOnPreFailure: true,
+		run: func(ctx context.Context, command string, env []string) ([]byte, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	err := hooks.beforeSync(context.Background(), "vol", "/volumes/vol")
+	require.ErrorContains(t, err, "pre-sync hook timed out after 50ms")
+}
+
+func TestRunShell(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		out, err := runShell(context.Background(), `echo "dumping $VOLUMESYNC_VOLUME"`, hookEnv("db", "/volumes/db"))
+		require.NoError(t, err)
+		require.Equal(t, "dumping db", strings.TrimSpace(string(out)))
+	})
+
+	t.Run("NonZeroExit", func(t *testing.T) {
+		out, err := runShell(context.Background(), "echo failed >&2; exit 3", nil)
+		require.ErrorContains(t, err, "exit status 3")
+		require.Equal(t, "failed", strings.TrimSpace(string(out)))
+	})
+
+	t.Run("Killed on timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := runShell(ctx, "sleep 10", nil)
+		require.Error(t, err)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+}
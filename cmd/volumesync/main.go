@@ -1,18 +1,30 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/dedalusj/docker-volume-sync/internal/config"
 	"github.com/dedalusj/docker-volume-sync/internal/dockermanager"
+	"github.com/dedalusj/docker-volume-sync/internal/notify"
+	"github.com/dedalusj/docker-volume-sync/internal/status"
 	"github.com/dedalusj/docker-volume-sync/internal/syncer"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
@@ -20,9 +32,8 @@ import (
 )
 
 const (
-	sentinelFilename = ".volumesync_done"
-	readyVolsDir     = "/tmp/volumesync_vols"
-	volumesBaseDir   = "/volumes"
+	readyVolsDir   = "/tmp/volumesync_vols"
+	volumesBaseDir = "/volumes"
 )
 
 func main() {
@@ -30,30 +41,105 @@ func main() {
 		healthCheck()
 	}
 
-	log.Println("Starting Docker Volume Sync...")
+	direction := flag.String("direction", "", "with RUN_MODE=once, whether to back the volumes up or restore them: backup or restore (default SYNC_DIRECTION)")
+	confirmDelete := flag.Bool("confirm-delete", false, "allow a restore to delete files from volumes labelled volumesync.delete=true")
+	flag.Parse()
 
 	globalCfg, err := config.LoadGlobal()
 	if err != nil {
 		log.Fatalf("Failed to load global config: %v", err)
 	}
+	if *direction != "" {
+		d, err := config.ParseSyncDirection(*direction)
+		if err != nil {
+			log.Printf("Invalid -direction: %v", err)
+			os.Exit(exitUsage)
+		}
+		globalCfg.SyncDirection = d
+	}
+	if globalCfg.SyncDirection == config.SyncRestore && globalCfg.RunMode != config.RunModeOnce {
+		log.Printf("A restore requires RUN_MODE=%s", config.RunModeOnce)
+		os.Exit(exitUsage)
+	}
 
-	mgr, err := dockermanager.New()
+	// rclone logs through slog's default logger too, so this covers its
+	// output as well as ours.
+	slog.SetDefault(newLogger(os.Stderr, globalCfg.LogFormat, globalCfg.LogLevel))
+	slog.Info("Starting Docker Volume Sync")
+
+	syncer.LimitRequests(context.Background(), globalCfg.MaxRequestsPerSec)
+	httpOpt := syncer.HTTPOptions{Timeout: globalCfg.S3HTTPTimeout, CABundle: globalCfg.S3CABundle}
+	if err := syncer.ConfigureHTTP(context.Background(), httpOpt); err != nil {
+		fatal("Invalid S3_CA_BUNDLE", "path", globalCfg.S3CABundle, "error", err)
+	}
+
+	// Catch a mistyped destination or bad credentials before stopping any
+	// containers for it. Volumes with their own region or profile are only
+	// checked by their first sync.
+	dest, err := syncer.WithAWSConfig(globalCfg.DestinationPath, awsConfig(globalCfg, config.VolumeJob{}))
+	if err == nil {
+		err = syncer.CheckRemote(context.Background(), dest)
+	}
 	if err != nil {
-		log.Fatalf("Failed to create docker manager: %v", err)
+		fatal("Destination is not usable", "destination", globalCfg.DestinationPath, "error", err)
+	}
+
+	bindMountsDir := ""
+	if globalCfg.StopMatchBindMounts {
+		bindMountsDir = volumesBaseDir
+	}
+	schedules := config.ScheduleParser{WithSeconds: globalCfg.CronWithSeconds}
+	mgr, err := dockermanager.New(schedules,
+		dockermanager.WithStopExclusions(globalCfg.StopExcludeLabels, globalCfg.StopExcludeNames),
+		dockermanager.WithStopReaders(!globalCfg.StopOnlyWriters),
+		dockermanager.WithBindMounts(bindMountsDir),
+		dockermanager.WithStopOrder(globalCfg.StopOrder, globalCfg.StopDependsOn),
+		dockermanager.WithDaemon(globalCfg.DockerHost, globalCfg.DockerAPIVersion),
+		dockermanager.WithConcurrency(globalCfg.StopConcurrency),
+	)
+	if err != nil {
+		fatal("Failed to create docker manager", "error", err)
 	}
 	defer mgr.Close()
+	if err := mgr.Ping(context.Background()); err != nil {
+		fatal("Docker daemon is not usable", "error", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	ctx := context.Background()
+	var notifier *notify.Webhook
+	if globalCfg.NotifyWebhookURL != "" {
+		notifier = notify.NewWebhook(globalCfg.NotifyWebhookURL, globalCfg.NotifyOn)
+	}
+
+	if globalCfg.RunMode == config.RunModeOnce {
+		ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		code := runOnce(ctx, globalCfg, mgr, notifier, volumesBaseDir, *confirmDelete)
+		stop()
+		mgr.Close()
+		os.Exit(code)
+	}
 
 	_ = os.MkdirAll(readyVolsDir, 0755)
 
-	c := cron.New(cron.WithLocation(globalCfg.Location))
+	history := status.NewHistory(globalCfg.StatusHistorySize)
+	if globalCfg.StatusAddr != "" {
+		if err := serveStatus(ctx, globalCfg.StatusAddr, history); err != nil {
+			fatal("Failed to start status endpoint", "addr", globalCfg.StatusAddr, "error", err)
+		}
+	}
+
+	c := cron.New(cron.WithLocation(globalCfg.Location), cron.WithParser(schedules))
 	c.Start()
 
 	scheduledJobs := make(map[string]cron.EntryID)
+	stopped := newStoppedContainers()
+	syncers := &openSyncers{}
+	failed := make(chan string, 1)
 
 	// Single discovery run on startup
-	processJobs(ctx, globalCfg, mgr, c, scheduledJobs)
+	processJobs(ctx, globalCfg, mgr, c, scheduledJobs, stopped, syncers, failed, notifier, history)
 
 	// Periodic discovery in the background
 	ticker := time.NewTicker(30 * time.Second)
@@ -64,20 +150,155 @@ func main() {
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				processJobs(ctx, globalCfg, mgr, c, scheduledJobs)
+				processJobs(ctx, globalCfg, mgr, c, scheduledJobs, stopped, syncers, failed, notifier, history)
 			}
 		}
 	}()
 
-	// Wait for a stop signal
+	// Wait for a stop signal, or with FAIL_ON_SYNC_ERROR, a failed backup
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	code := 0
+	select {
+	case <-sigChan:
+	case volume := <-failed:
+		slog.Error("Backup failed, exiting as FAIL_ON_SYNC_ERROR is set", "volume", volume)
+		code = 1
+	}
 
-	log.Println("Shutting down...")
+	slog.Info("Shutting down")
 	ticker.Stop() // Not strictly needed as the ticker will be stopped by ctx.Done() above but good practice
-	c.Stop()
+	shutdown(c, cancel, globalCfg.ShutdownTimeout, stopped, syncers, mgr, globalCfg.QuiesceMode)
 	_ = os.RemoveAll(readyVolsDir)
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+// exitOnFailure reports whether a scheduled backup that ended as ev should
+// stop the process, as it does when it failed with failOnError set. Backups
+// that failed because the process is already shutting down don't count.
+func exitOnFailure(failOnError, shuttingDown bool, ev notify.Event) bool {
+	return failOnError && !shuttingDown && ev.Status == notify.StatusFailure
+}
+
+// newLogger builds the process logger in the configured format, writing to w.
+func newLogger(w io.Writer, format string, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == config.LogFormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// fatal logs an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// containerStarter is the part of the docker manager needed to bring
+// containers back up.
+type containerStarter interface {
+	StartContainers(ctx context.Context, ids []string) error
+	UnpauseContainers(ctx context.Context, ids []string) error
+}
+
+// shutdown stops scheduling, cancels any backup in flight and waits up to
+// timeout for it to wind down, then closes syncers, if set. Containers that are
+// still stopped or paused, as mode says, afterwards, because a backup was stuck
+// or got killed mid-run, are brought back so the app isn't left down.
+func shutdown(c *cron.Cron, cancel context.CancelFunc, timeout time.Duration, stopped *stoppedContainers, syncers *openSyncers, mgr containerStarter, mode config.QuiesceMode) {
+	cancel()
+
+	select {
+	case <-c.Stop().Done():
+		// Closing waits for the syncers' runs, so only once none is left.
+		syncers.close()
+	case <-time.After(timeout):
+		slog.Warn("Timed out waiting for running backups to finish", "timeout", timeout)
+	}
+
+	if ids := stopped.drain(); len(ids) > 0 {
+		slog.Info("Restarting containers left stopped by an interrupted backup", "count", len(ids), "mode", mode)
+		if err := resume(context.Background(), mgr, mode, ids); err != nil {
+			slog.Error("Error restarting containers", "error", err)
+		}
+	}
+}
+
+// resume brings back containers quiesced by mode.
+func resume(ctx context.Context, mgr containerStarter, mode config.QuiesceMode, ids []string) error {
+	if mode == config.QuiescePause {
+		return mgr.UnpauseContainers(ctx, ids)
+	}
+	return mgr.StartContainers(ctx, ids)
+}
+
+// stoppedContainers tracks the containers currently stopped, or paused, by
+// backups, so that shutdown knows what to bring back.
+type stoppedContainers struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+func newStoppedContainers() *stoppedContainers {
+	return &stoppedContainers{ids: make(map[string]struct{})}
+}
+
+func (s *stoppedContainers) add(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.ids[id] = struct{}{}
+	}
+}
+
+func (s *stoppedContainers) remove(ids []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.ids, id)
+	}
+}
+
+// drain returns every tracked container and stops tracking them.
+func (s *stoppedContainers) drain() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	clear(s.ids)
+	return ids
+}
+
+// openSyncers keeps the syncers of scheduled jobs, to be closed on shutdown.
+type openSyncers struct {
+	mu      sync.Mutex
+	syncers []volumeSyncer
+}
+
+func (o *openSyncers) add(s volumeSyncer) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.syncers = append(o.syncers, s)
+}
+
+// close closes every syncer kept. A nil openSyncers has none.
+func (o *openSyncers) close() {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, s := range o.syncers {
+		if err := s.Close(); err != nil {
+			slog.Error("Failed to close syncer", "error", err)
+		}
+	}
+	o.syncers = nil
 }
 
 func healthCheck() {
@@ -104,10 +325,33 @@ func healthCheck() {
 	os.Exit(1)
 }
 
-func processJobs(ctx context.Context, globalCfg *config.GlobalConfig, mgr *dockermanager.Manager, c *cron.Cron, scheduledJobs map[string]cron.EntryID) {
+// serveStatus serves the sync history at /status on addr until ctx is done.
+func serveStatus(ctx context.Context, addr string, history *status.History) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/status", history)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Status endpoint stopped", "addr", addr, "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	slog.Info("Serving status", "addr", ln.Addr().String())
+	return nil
+}
+
+func processJobs(ctx context.Context, globalCfg *config.GlobalConfig, mgr *dockermanager.Manager, c *cron.Cron, scheduledJobs map[string]cron.EntryID, stopped *stoppedContainers, syncers *openSyncers, failed chan<- string, notifier *notify.Webhook, history *status.History) {
+	hooks := newSyncHooks(globalCfg)
 	jobs, err := mgr.DiscoverJobs(ctx)
 	if err != nil {
-		log.Printf("Error discovering jobs: %v", err)
+		slog.Error("Error discovering jobs", "error", err)
 		return
 	}
 
@@ -117,80 +361,387 @@ func processJobs(ctx context.Context, globalCfg *config.GlobalConfig, mgr *docke
 		}
 
 		volumePath := filepath.Join(volumesBaseDir, job.VolumeName)
-		remotePath := syncer.JoinPath(globalCfg.DestinationPath, job.SubPath)
-		remotePath = syncer.WrapCompress(remotePath, globalCfg.ResolveCompression(job))
-
-		rules, err := syncer.BuildFilterRules(job.Exclude, job.Include)
+		s, remotePath, err := newJobSyncer(ctx, globalCfg, job)
 		if err != nil {
-			log.Printf("[%s] Invalid filter pattern, skipping volume: %v", job.VolumeName, err)
+			slog.Error("Failed to create syncer, skipping volume", "volume", job.VolumeName, "error", err)
 			continue
 		}
+		syncers.add(s)
 
-		// Create a syncer for this job
-		f := filter.Opt
-		f.MinAge = fs.DurationOff
-		f.MaxAge = fs.DurationOff
-		// The sentinel rule goes first so it always wins over the user's rules.
-		f.FilterRule = append([]string{"- " + sentinelFilename}, rules...)
-
-		s, err := syncer.New(ctx,
-			syncer.WithConcurrency(job.Concurrency),
-			syncer.WithDelete(job.Delete),
-			syncer.WithFilterOpt(f),
-		)
+		// 1. Initial Sync (Restore)
+		start := time.Now()
+		stats, err := initialSync(ctx, volumePath, sentinelPath(globalCfg, volumePath), remotePath, s, job.UID, job.GID)
 		if err != nil {
-			log.Printf("Failed to create syncer for %s: %v", job.VolumeName, err)
-			continue
+			fatal("Initial sync failed", "volume", job.VolumeName, "error", err)
+		}
+		if stats != nil {
+			history.Add(status.SyncResult{
+				Time:            time.Now(),
+				Direction:       string(config.SyncRestore),
+				Volume:          job.VolumeName,
+				Files:           stats.Transfers,
+				Bytes:           stats.Bytes,
+				DurationSeconds: time.Since(start).Seconds(),
+			})
 		}
-
-		// 1. Initial Sync (Restore)
-		initialSync(ctx, volumePath, remotePath, s, job.UID, job.GID)
 
 		// 2. Mark as ready (for the health check)
 		markerPath := filepath.Join(readyVolsDir, job.VolumeName)
 		if err := os.WriteFile(markerPath, []byte(time.Now().String()), 0644); err != nil {
-			log.Printf("Warning: failed to create ready marker for %s: %v", job.VolumeName, err)
+			slog.Warn("Failed to create ready marker", "volume", job.VolumeName, "error", err)
 		}
 
 		// 3. Schedule Backup
-		onDone := func() {
+		breaker := newCircuitBreaker(job.VolumeName, globalCfg.CircuitThreshold, globalCfg.CircuitCooldown)
+		unchanged := newUnchangedCheck(globalCfg.SkipIfUnchanged, job.VolumeName, volumePath, sentinelPath(globalCfg, volumePath), s)
+		onDone := func(ev notify.Event) {
+			breaker.record(ev.Status == notify.StatusSuccess)
+			// Files skipped as unreadable are tried again next time.
+			unchanged.record(ev.Status == notify.StatusSuccess && len(ev.Skipped) == 0)
+			history.Add(status.SyncResult{
+				Time:            time.Now(),
+				Direction:       string(config.SyncBackup),
+				Volume:          ev.Volume,
+				Files:           ev.Files,
+				Bytes:           ev.Bytes,
+				DurationSeconds: ev.DurationSeconds,
+				Error:           ev.Error,
+			})
+			if notifier != nil {
+				// Sent even when shutdown cancelled the backup.
+				notifier.Notify(context.WithoutCancel(ctx), ev)
+			}
+			if exitOnFailure(globalCfg.FailOnSyncError, ctx.Err() != nil, ev) {
+				// The first failure is enough to exit on.
+				select {
+				case failed <- ev.Volume:
+				default:
+				}
+			}
 			entryID := scheduledJobs[job.VolumeName]
 			next := c.Entry(entryID).Next
-			log.Printf("[%s] Next scheduled backup: %s", job.VolumeName, next.Format(time.RFC3339))
+			slog.Info("Next scheduled backup", "volume", job.VolumeName, "next", next.Format(time.RFC3339))
 		}
 
-		entryID, err := c.AddFunc(job.Schedule, syncJob(ctx, job, volumePath, remotePath, mgr, s, onDone))
+		run := syncJob(ctx, job, volumePath, remotePath, mgr, s, globalCfg.StopFailurePolicy, globalCfg.QuiesceMode, globalCfg.SyncTimeout, hooks, stopped, onDone)
+		// Inside skipIfRunning, so that every run the breaker lets through
+		// ends in onDone.
+		run = withCircuitBreaker(breaker, run)
+		// Outside the breaker, so that a skipped run doesn't count as its
+		// trial, but inside skipIfRunning, so that runs don't check at once.
+		run = withUnchangedCheck(ctx, unchanged, run)
+		if !globalCfg.ConcurrentRuns {
+			run = skipIfRunning(job.VolumeName, run)
+		}
+		// Outside skipIfRunning, so that a run waiting out its delay doesn't
+		// count as running.
+		run = withJitter(ctx, job.VolumeName, globalCfg.SyncJitter, run)
+
+		entryID, err := c.AddFunc(job.Schedule, run)
 		if err != nil {
-			log.Printf("Failed to schedule job for %s: %v", job.VolumeName, err)
+			slog.Error("Failed to schedule job", "volume", job.VolumeName, "error", err)
 			continue
 		}
 
 		scheduledJobs[job.VolumeName] = entryID
 
-		log.Printf("[%s] Scheduled backup (%s). Next run: %s", job.VolumeName, job.Schedule, c.Entry(entryID).Next.Format(time.RFC3339))
+		slog.Info("Scheduled backup", "volume", job.VolumeName, "schedule", job.Schedule, "next", c.Entry(entryID).Next.Format(time.RFC3339))
 	}
 }
 
-func initialSync(ctx context.Context, localPath, remotePath string, s *syncer.Syncer, uid, gid *int) {
-	sentinelPath := filepath.Join(localPath, sentinelFilename)
-	if _, err := os.Stat(sentinelPath); os.IsNotExist(err) {
-		log.Printf("[%s] Sentinel file not found. Starting INITIAL SYNC (Remote -> Local)...", filepath.Base(localPath))
-		if err := s.Sync(ctx, remotePath, localPath); err != nil {
-			log.Fatalf("Initial sync failed for %s: %v", localPath, err)
+// newJobSyncer builds the syncer for a job, returning it with the job's remote
+// path. In archive or snapshot mode it backs up to and restores from archives
+// or snapshots instead, and with RestoreAtTime it restores from object
+// versions.
+func newJobSyncer(ctx context.Context, globalCfg *config.GlobalConfig, job config.VolumeJob) (volumeSyncer, string, error) {
+	archive := globalCfg.BackupMode == config.BackupModeArchive
+	remotePath, err := syncer.JoinPath(globalCfg.DestinationPath, job.SubPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid subpath: %w", err)
+	}
+	if globalCfg.PreserveEmptyDirs {
+		remotePath, err = syncer.WithDirectoryMarkers(remotePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to enable directory markers: %w", err)
 		}
-		log.Printf("[%s] Initial sync completed.", filepath.Base(localPath))
-
-		if uid != nil || gid != nil {
-			log.Printf("[%s] Applying ownership to folders...", filepath.Base(localPath))
-			chownDirectories(uid, gid, localPath)
+	}
+	remotePath, err = syncer.WithAWSConfig(remotePath, awsConfig(globalCfg, job))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to set AWS options: %w", err)
+	}
+	remotePath, err = syncer.WithACL(remotePath, globalCfg.S3ACL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to set object ACL: %w", err)
+	}
+	remotePath, err = syncer.WithUploadParts(remotePath, globalCfg.UploadPartSize, globalCfg.PartConcurrency)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to set upload part options: %w", err)
+	}
+	// Restores read the files as they were then, which leaves the remote
+	// read-only, so backups still go to the plain one.
+	restorePath := remotePath
+	if !globalCfg.RestoreAtTime.IsZero() {
+		restorePath, err = syncer.WithVersionAt(remotePath, globalCfg.RestoreAtTime)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to restore from object versions: %w", err)
 		}
+	}
+	// Archives are compressed whole, if at all, so their files aren't.
+	compress := syncer.CompressNone
+	if globalCfg.ResolveCompression(job) && !archive {
+		compress = syncer.CompressMode(globalCfg.CompressionCodec)
+	}
+	remotePath = syncer.WrapCompress(remotePath, compress)
+	restorePath = syncer.WrapCompress(restorePath, compress)
+
+	rules, err := syncer.BuildFilterRules(job.Exclude, job.Include, globalCfg.IgnorePatterns)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid filter pattern: %w", err)
+	}
 
-		if err := os.WriteFile(sentinelPath, []byte(time.Now().String()), 0644); err != nil {
-			log.Fatalf("Failed to create sentinel file for %s: %v", localPath, err)
+	f := filter.Opt
+	f.MinAge = fs.DurationOff
+	f.MaxAge = fs.DurationOff
+	f.FilterRule = rules
+
+	archiveFormat := syncer.ArchiveTarGz
+	if !globalCfg.ArchiveGzip {
+		archiveFormat = syncer.ArchiveTar
+	}
+
+	s, err := syncer.New(ctx,
+		syncer.WithConcurrency(globalCfg.ResolveConcurrency(job)),
+		syncer.WithPartConcurrency(globalCfg.PartConcurrency),
+		syncer.WithDownloadPartSize(globalCfg.DownloadPartSize),
+		syncer.WithDelete(job.Delete),
+		syncer.WithDeleteFirst(globalCfg.DeleteFirst),
+		syncer.WithSkipRemoteDeletes(globalCfg.SkipRemoteDeletes),
+		syncer.WithMaxDeleteRatio(globalCfg.MaxDeleteRatio),
+		syncer.WithFilterOpt(f),
+		syncer.WithAgeLimits(globalCfg.MinAge, globalCfg.MaxAge),
+		syncer.WithSizeLimits(globalCfg.MinSize, globalCfg.MaxSize),
+		// The sentinel is reserved even when it's kept elsewhere, in case
+		// one was left in the volume.
+		syncer.WithReserved(cmp.Or(globalCfg.SentinelFile, config.DefaultSentinelFile)),
+		syncer.WithPreservePermissions(globalCfg.PreservePermissions),
+		syncer.WithPreserveSymlinks(globalCfg.PreserveSymlinks),
+		syncer.WithFollowSymlinks(globalCfg.FollowSymlinks),
+		syncer.WithSkipUnreadable(globalCfg.SkipErrors),
+		syncer.WithModifyWindow(globalCfg.MtimeTolerance),
+		syncer.WithPreserveModTime(globalCfg.PreserveMtime),
+		syncer.WithVerify(syncer.VerifyMode(globalCfg.Verify)),
+		syncer.WithProgressInterval(globalCfg.ProgressInterval),
+		syncer.WithDedup(globalCfg.Dedup),
+		syncer.WithRequireNonEmptySource(globalCfg.NonEmptySource),
+		syncer.WithManifest(manifestPath(globalCfg, job), globalCfg.FullSyncEvery),
+		syncer.WithContentType(syncer.ContentTypeMode(globalCfg.ContentType)),
+		syncer.WithPreserveEmptyDirs(globalCfg.PreserveEmptyDirs),
+		syncer.WithObjectTags(globalCfg.S3ObjectTags),
+		syncer.WithArchiveFormat(archiveFormat),
+		syncer.WithRetention(globalCfg.RetentionCount, globalCfg.RetentionAge),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	switch {
+	case archive:
+		return archiveSyncer{s: s, remote: remotePath}, remotePath, nil
+	case globalCfg.SnapshotMode:
+		return snapshotSyncer{s: s, remote: remotePath, restoreAt: globalCfg.RestoreSnapshot}, remotePath, nil
+	case restorePath != remotePath:
+		return versionSyncer{s: s, remote: remotePath, restoreRemote: restorePath}, remotePath, nil
+	}
+	return s, remotePath, nil
+}
+
+// manifestPath returns where the manifest of job's backups is kept, or ""
+// when backups keep none.
+func manifestPath(globalCfg *config.GlobalConfig, job config.VolumeJob) string {
+	if globalCfg.ManifestDir == "" {
+		return ""
+	}
+	return filepath.Join(globalCfg.ManifestDir, job.VolumeName+".json")
+}
+
+// archiveSyncer backs volumes up to archives at remote and restores them from
+// the latest one there, in place of syncing them file by file.
+type archiveSyncer struct {
+	s      *syncer.Syncer
+	remote string
+}
+
+func (a archiveSyncer) Close() error {
+	return a.s.Close()
+}
+
+func (a archiveSyncer) Signature(ctx context.Context, src string) (string, error) {
+	return a.s.Signature(ctx, src)
+}
+
+func (a archiveSyncer) SyncWithStats(ctx context.Context, src, dst string) (syncer.Stats, error) {
+	if src == a.remote {
+		return a.s.RestoreArchive(ctx, src, dst)
+	}
+	return a.s.Archive(ctx, src, dst)
+}
+
+// snapshotSyncer backs volumes up to a new snapshot at remote each time and
+// restores them from the one taken at restoreAt, or the latest.
+type snapshotSyncer struct {
+	s         *syncer.Syncer
+	remote    string
+	restoreAt string
+}
+
+func (s snapshotSyncer) Close() error {
+	return s.s.Close()
+}
+
+func (s snapshotSyncer) Signature(ctx context.Context, src string) (string, error) {
+	return s.s.Signature(ctx, src)
+}
+
+func (s snapshotSyncer) SyncWithStats(ctx context.Context, src, dst string) (syncer.Stats, error) {
+	if src == s.remote {
+		return s.s.RestoreSnapshot(ctx, src, dst, s.restoreAt)
+	}
+	return s.s.Snapshot(ctx, src, dst)
+}
+
+// versionSyncer restores volumes from restoreRemote, which reads remote as it
+// was at some time, while still backing them up to remote.
+type versionSyncer struct {
+	s             *syncer.Syncer
+	remote        string
+	restoreRemote string
+}
+
+func (v versionSyncer) Close() error {
+	return v.s.Close()
+}
+
+func (v versionSyncer) Signature(ctx context.Context, src string) (string, error) {
+	return v.s.Signature(ctx, src)
+}
+
+func (v versionSyncer) SyncWithStats(ctx context.Context, src, dst string) (syncer.Stats, error) {
+	if src == v.remote {
+		src = v.restoreRemote
+	}
+	return v.s.SyncWithStats(ctx, src, dst)
+}
+
+// awsConfig returns the S3 settings for a job, with its label overrides
+// applied.
+func awsConfig(globalCfg *config.GlobalConfig, job config.VolumeJob) syncer.AWSConfig {
+	return syncer.AWSConfig{
+		Region:          globalCfg.ResolveS3Region(job),
+		Profile:         globalCfg.ResolveAWSProfile(job),
+		CredentialsFile: globalCfg.AWSCredentialsFile,
+		RoleARN:         globalCfg.AssumeRoleARN,
+		ExternalID:      globalCfg.ExternalID,
+	}
+}
+
+// sentinelPath returns where the sentinel marking the volume at volumePath as
+// restored is kept, or "" when sentinels are disabled.
+func sentinelPath(globalCfg *config.GlobalConfig, volumePath string) string {
+	if globalCfg.SentinelDisable {
+		return ""
+	}
+	name := cmp.Or(globalCfg.SentinelFile, config.DefaultSentinelFile)
+	if globalCfg.SentinelDir != "" {
+		return filepath.Join(globalCfg.SentinelDir, filepath.Base(volumePath), name)
+	}
+	return filepath.Join(volumePath, name)
+}
+
+// initialSync restores a volume from the remote, unless its sentinel shows an
+// earlier restore already completed, in which case it returns nil stats. The
+// sentinel is only written once a sync has succeeded, so a restore interrupted
+// by a crash is picked up again on the next start. Files already restored in
+// full are then skipped, as the sync only transfers what differs, so the
+// restore resumes rather than starting over. With no sentinel, given as "",
+// the volume is restored every time.
+func initialSync(ctx context.Context, localPath, sentinelPath, remotePath string, s volumeSyncer, uid, gid *int) (*syncer.Stats, error) {
+	volume := filepath.Base(localPath)
+	if sentinelPath == "" {
+		slog.Info("Sentinel disabled, starting initial sync (remote -> local)", "volume", volume)
+		stats, err := restore(ctx, localPath, sentinelPath, remotePath, s, uid, gid)
+		if err != nil {
+			return nil, err
 		}
+		return &stats, nil
+	}
+	if _, err := os.Stat(sentinelPath); err == nil {
+		slog.Info("Sentinel file found, skipping initial sync", "volume", volume)
+		return nil, nil
+	}
+
+	if entries, _ := os.ReadDir(localPath); len(entries) > 0 {
+		slog.Info("Sentinel file not found but volume has data, resuming initial sync (remote -> local)", "volume", volume)
 	} else {
-		log.Printf("[%s] Sentinel file found. Skipping initial sync.", filepath.Base(localPath))
+		slog.Info("Sentinel file not found, starting initial sync (remote -> local)", "volume", volume)
+	}
+	stats, err := restore(ctx, localPath, sentinelPath, remotePath, s, uid, gid)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// restore syncs a volume from the remote, applies the job's ownership and
+// marks the volume as restored with its sentinel, unless sentinelPath is "".
+func restore(ctx context.Context, localPath, sentinelPath, remotePath string, s volumeSyncer, uid, gid *int) (syncer.Stats, error) {
+	volume := filepath.Base(localPath)
+
+	stats, err := s.SyncWithStats(ctx, remotePath, localPath)
+	if err != nil {
+		return stats, err
+	}
+	slog.Info("Restore completed", "volume", volume, "transfers", stats.Transfers, "bytes", stats.Bytes)
+
+	if uid != nil || gid != nil {
+		slog.Info("Applying ownership to folders", "volume", volume)
+		chownDirectories(uid, gid, localPath)
+	}
+
+	if sentinelPath == "" {
+		return stats, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(sentinelPath), 0755); err != nil {
+		return stats, fmt.Errorf("failed to create sentinel directory: %w", err)
+	}
+	if err := writeFileAtomic(sentinelPath, []byte(time.Now().String())); err != nil {
+		return stats, fmt.Errorf("failed to create sentinel file: %w", err)
+	}
+	return stats, nil
+}
+
+// writeFileAtomic writes data to path through a temporary file in the same
+// directory, so that a crash never leaves a partially written file at path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
 	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func chownDirectories(uid, gid *int, path string) {
@@ -211,41 +762,266 @@ func chownDirectories(uid, gid *int, path string) {
 			}
 			return nil
 		}); err != nil {
-			log.Printf("Warning: failed to chown directories in %s: %v", path, err)
+			slog.Warn("Failed to chown directories", "path", path, "error", err)
 		}
 	}
 }
 
-func syncJob(ctx context.Context, job config.VolumeJob, localPath, remotePath string, mgr *dockermanager.Manager, s *syncer.Syncer, onDone func()) func() {
+// containerManager is the part of the docker manager a backup needs.
+type containerManager interface {
+	containerStarter
+	StopContainers(ctx context.Context, ids []string, gracePeriod time.Duration) ([]string, error)
+	StopContainersByLabel(ctx context.Context, labelKey, labelValue string, gracePeriod time.Duration) ([]string, error)
+	StopContainersAttachedToVolume(ctx context.Context, volume string, gracePeriod time.Duration) ([]string, error)
+	PauseContainers(ctx context.Context, ids []string) ([]string, error)
+	PauseContainersByLabel(ctx context.Context, labelKey, labelValue string) ([]string, error)
+	PauseContainersAttachedToVolume(ctx context.Context, volume string) ([]string, error)
+	ExecInContainers(ctx context.Context, ids []string, cmd []string) ([]string, error)
+}
+
+// volumeSyncer syncs one location to another.
+type volumeSyncer interface {
+	SyncWithStats(ctx context.Context, src, dst string) (syncer.Stats, error)
+	Close() error
+}
+
+// syncJob returns a backup of job, with its containers quiesced by mode and
+// hooks, if any, run around it. onDone, if set, is handed the outcome of every
+// run, however it ends.
+func syncJob(ctx context.Context, job config.VolumeJob, localPath, remotePath string, mgr containerManager, s volumeSyncer, policy config.StopFailurePolicy, mode config.QuiesceMode, timeout time.Duration, hooks *syncHooks, tracker *stoppedContainers, onDone func(notify.Event)) func() {
 	return func() {
-		log.Printf("[%s] Starting scheduled backup...", job.VolumeName)
+		slog.Info("Starting backup", "volume", job.VolumeName)
 
-		var stopped []string
-		var stopErr error
+		// The whole run shares the timeout, so a stalled remote can't keep
+		// the containers down for longer. Restarting them ignores it.
+		runCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			runCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
 
-		if job.StopContainer {
-			stopped, stopErr = mgr.StopContainers(ctx, job.ContainerIDs, job.StopGracePeriod)
-			if stopErr != nil {
-				log.Printf("[%s] Error stopping containers: %v", job.VolumeName, stopErr)
-			}
+		start := time.Now()
+		// Assume the worst until the backup gets to the end.
+		ev := notify.Event{Status: notify.StatusFailure, Volume: job.VolumeName, Error: "backup did not complete"}
+		if onDone != nil {
+			defer func() {
+				ev.DurationSeconds = time.Since(start).Seconds()
+				onDone(ev)
+			}()
 		}
 
-		if stopErr == nil {
-			if err := s.Sync(ctx, localPath, remotePath); err != nil {
-				log.Printf("[%s] Error syncing volume: %v", job.VolumeName, err)
-			} else {
-				log.Printf("[%s] Backup completed successfully.", job.VolumeName)
+		// The pre-sync hook runs before the containers are stopped, so that
+		// it can still reach the app, and the post-sync hook once they are
+		// back.
+		if err := hooks.beforeSync(runCtx, job.VolumeName, localPath); err != nil {
+			slog.Error("Pre-sync hook failed, skipping backup", "volume", job.VolumeName, "error", err)
+			ev.Error = err.Error()
+			return
+		}
+		defer func() { hooks.afterSync(context.WithoutCancel(ctx), job.VolumeName, localPath, ev.Status) }()
+
+		// Like the pre-sync hook, the quiesce command needs the app up. The
+		// release command runs once the containers are back, and failing to
+		// run it fails the backup, as the app may be left locked.
+		flushed, err := execQuiesce(runCtx, job, mgr)
+		defer func() {
+			if err := execRelease(ctx, job, mgr, flushed); err != nil {
+				ev.Status = notify.StatusFailure
+				ev.Error = strings.TrimPrefix(ev.Error+"; "+err.Error(), "; ")
 			}
+		}()
+		if err != nil {
+			slog.Error("Quiesce command failed, skipping backup", "volume", job.VolumeName, "error", err)
+			ev.Error = err.Error()
+			return
 		}
 
-		if job.StopContainer && len(stopped) > 0 {
-			if err := mgr.StartContainers(ctx, stopped); err != nil {
-				log.Printf("[%s] Error restarting containers: %v", job.VolumeName, err)
+		stopped, err := quiesceContainers(runCtx, job, mgr, mode)
+		tracker.add(stopped)
+		// Deferred so the containers come back however the backup ends,
+		// including a panic. Containers left down fail the run, even if the
+		// backup itself succeeded, so that they get noticed.
+		var restartErr error
+		defer func() {
+			restartErr = errors.Join(restartErr, restartContainers(ctx, job.VolumeName, mgr, mode, tracker, stopped))
+			if restartErr != nil {
+				ev.Status = notify.StatusFailure
+				ev.Error = strings.TrimPrefix(ev.Error+"; "+restartErr.Error(), "; ")
+			}
+		}()
+		if err != nil {
+			switch policy {
+			case config.StopFailureSkip:
+				slog.Warn("Error stopping containers, backing up anyway", "volume", job.VolumeName, "error", err)
+			case config.StopFailureProceedAndRestart:
+				slog.Warn("Error stopping containers, restarting them and backing up live", "volume", job.VolumeName, "error", err)
+				restartErr = restartContainers(ctx, job.VolumeName, mgr, mode, tracker, stopped)
+				stopped = nil
+			default:
+				slog.Error("Error stopping containers, skipping backup", "volume", job.VolumeName, "error", err)
+				ev.Error = err.Error()
+				return
 			}
 		}
 
-		if onDone != nil {
-			onDone()
+		stats, err := s.SyncWithStats(runCtx, localPath, remotePath)
+		ev.Files, ev.Bytes = stats.Transfers, stats.Bytes
+		if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			slog.Error("Backup timed out", "volume", job.VolumeName, "timeout", timeout, "error", err)
+			ev.Error = fmt.Sprintf("backup timed out after %s: %v", timeout, err)
+			return
 		}
+		if err != nil {
+			slog.Error("Error syncing volume", "volume", job.VolumeName, "error", err)
+			ev.Error = err.Error()
+			return
+		}
+		ev.Status, ev.Error = notify.StatusSuccess, ""
+		if len(stats.Skipped) > 0 {
+			ev.Skipped = stats.Skipped
+			slog.Warn("Backup completed, skipping unreadable files", "volume", job.VolumeName, "skipped", len(stats.Skipped))
+			return
+		}
+		slog.Info("Backup completed successfully", "volume", job.VolumeName)
+	}
+}
+
+// quiesceContainers stops, or with QuiescePause pauses, the job's own
+// containers, unless it opted out, and then any containers selected by its
+// stop labels or mounting its volume. It keeps going past failures and returns
+// everything it stopped alongside the joined errors, so the caller can restart
+// them whatever the stop failure policy.
+func quiesceContainers(ctx context.Context, job config.VolumeJob, mgr containerManager, mode config.QuiesceMode) ([]string, error) {
+	var stopped []string
+	var errs []error
+
+	if job.StopContainer {
+		var ids []string
+		var err error
+		if mode == config.QuiescePause {
+			ids, err = mgr.PauseContainers(ctx, job.ContainerIDs)
+		} else {
+			ids, err = mgr.StopContainers(ctx, job.ContainerIDs, job.StopGracePeriod)
+		}
+		stopped = append(stopped, ids...)
+		errs = append(errs, err)
 	}
+
+	for key, value := range job.StopLabels {
+		var ids []string
+		var err error
+		if mode == config.QuiescePause {
+			ids, err = mgr.PauseContainersByLabel(ctx, key, value)
+		} else {
+			ids, err = mgr.StopContainersByLabel(ctx, key, value, job.StopGracePeriod)
+		}
+		stopped = append(stopped, ids...)
+		errs = append(errs, err)
+	}
+
+	if job.StopAttached {
+		var ids []string
+		var err error
+		if mode == config.QuiescePause {
+			ids, err = mgr.PauseContainersAttachedToVolume(ctx, job.VolumeName)
+		} else {
+			ids, err = mgr.StopContainersAttachedToVolume(ctx, job.VolumeName, job.StopGracePeriod)
+		}
+		stopped = append(stopped, ids...)
+		errs = append(errs, err)
+	}
+
+	return stopped, errors.Join(errs...)
+}
+
+// execQuiesce runs the job's quiesce command, if any, in its running
+// containers, returning those it ran in for execRelease.
+func execQuiesce(ctx context.Context, job config.VolumeJob, mgr containerManager) ([]string, error) {
+	if len(job.QuiesceExec) == 0 {
+		return nil, nil
+	}
+	ids, err := mgr.ExecInContainers(ctx, job.ContainerIDs, job.QuiesceExec)
+	if err != nil {
+		return ids, fmt.Errorf("quiesce command failed: %w", err)
+	}
+	return ids, nil
+}
+
+// execRelease runs the job's release command, if any, in the containers the
+// quiesce command ran in, or in all its running containers if it has none.
+// Like restartContainers, it runs even when ctx has been cancelled.
+func execRelease(ctx context.Context, job config.VolumeJob, mgr containerManager, flushed []string) error {
+	if len(job.ReleaseExec) == 0 {
+		return nil
+	}
+	ids := job.ContainerIDs
+	if len(job.QuiesceExec) > 0 {
+		ids = flushed
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, err := mgr.ExecInContainers(context.WithoutCancel(ctx), ids, job.ReleaseExec); err != nil {
+		slog.Error("Release command failed", "volume", job.VolumeName, "error", err)
+		return fmt.Errorf("release command failed: %w", err)
+	}
+	return nil
+}
+
+// restartContainers starts, or with QuiescePause unpauses, the containers a
+// backup quiesced, returning an error naming those that wouldn't come back. It
+// runs even when ctx has been cancelled, as leaving the app down is worse than
+// a late start.
+func restartContainers(ctx context.Context, name string, mgr containerStarter, mode config.QuiesceMode, tracker *stoppedContainers, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	defer tracker.remove(ids)
+	if err := resume(context.WithoutCancel(ctx), mgr, mode, ids); err != nil {
+		slog.Error("Error restarting containers", "volume", name, "error", err)
+		return fmt.Errorf("failed to restart containers: %w", err)
+	}
+	return nil
+}
+
+// skipIfRunning wraps a job so that a run firing while the previous one is
+// still in progress is skipped rather than overlapping it. Each job gets its
+// own lock, so different volumes still run independently.
+func skipIfRunning(name string, job func()) func() {
+	var mu sync.Mutex
+	return func() {
+		if !mu.TryLock() {
+			slog.Warn("Previous backup still running, skipping this run", "volume", name)
+			return
+		}
+		defer mu.Unlock()
+		job()
+	}
+}
+
+// withJitter wraps a job so that each run starts after a random delay below
+// maxDelay, spreading out instances that share a schedule. A run whose delay is
+// cut short by ctx is dropped.
+func withJitter(ctx context.Context, name string, maxDelay time.Duration, job func()) func() {
+	if maxDelay <= 0 {
+		return job
+	}
+	return func() {
+		delay := jitterDelay(maxDelay)
+		slog.Debug("Delaying backup", "volume", name, "delay", delay)
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		job()
+	}
+}
+
+// jitterDelay returns a random duration in [0, maxDelay).
+func jitterDelay(maxDelay time.Duration) time.Duration {
+	return rand.N(maxDelay)
 }
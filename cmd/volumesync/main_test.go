@@ -0,0 +1,825 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+	"github.com/dedalusj/docker-volume-sync/internal/notify"
+	"github.com/dedalusj/docker-volume-sync/internal/syncer"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeManager pretends to stop or pause the containers it is asked to, and
+// records the containers it starts, pauses and unpauses and the commands it
+// runs in them.
+type fakeManager struct {
+	mu       sync.Mutex
+	started  []string
+	paused   []string
+	unpaused []string
+	// byLabel maps "key=value" selectors to the containers they match.
+	byLabel map[string][]string
+	// byVolume maps volume names to the containers mounting them.
+	byVolume map[string][]string
+	// failing lists containers that refuse to stop, and wontStart those that
+	// refuse to start.
+	failing   map[string]bool
+	wontStart map[string]bool
+	// execs records the commands run in containers, as "id: command", and
+	// failingExecs lists those that fail.
+	execs        []string
+	failingExecs map[string]bool
+	// jobs and discoverErr are returned by DiscoverJobs.
+	jobs        []config.VolumeJob
+	discoverErr error
+}
+
+func (f *fakeManager) DiscoverJobs(ctx context.Context) ([]config.VolumeJob, error) {
+	return f.jobs, f.discoverErr
+}
+
+func (f *fakeManager) StopContainers(ctx context.Context, ids []string, gracePeriod time.Duration) ([]string, error) {
+	var stopped []string
+	var errs []error
+	for _, id := range ids {
+		if f.failing[id] {
+			errs = append(errs, errors.New("failed to stop container "+id))
+			continue
+		}
+		stopped = append(stopped, id)
+	}
+	return stopped, errors.Join(errs...)
+}
+
+func (f *fakeManager) startedContainers() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.started...)
+}
+
+func (f *fakeManager) StopContainersByLabel(ctx context.Context, labelKey, labelValue string, gracePeriod time.Duration) ([]string, error) {
+	return f.byLabel[labelKey+"="+labelValue], nil
+}
+
+func (f *fakeManager) StopContainersAttachedToVolume(ctx context.Context, volume string, gracePeriod time.Duration) ([]string, error) {
+	return f.byVolume[volume], nil
+}
+
+func (f *fakeManager) PauseContainers(ctx context.Context, ids []string) ([]string, error) {
+	var paused []string
+	var errs []error
+	for _, id := range ids {
+		if f.failing[id] {
+			errs = append(errs, errors.New("failed to pause container "+id))
+			continue
+		}
+		paused = append(paused, id)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paused = append(f.paused, paused...)
+	return paused, errors.Join(errs...)
+}
+
+func (f *fakeManager) PauseContainersByLabel(ctx context.Context, labelKey, labelValue string) ([]string, error) {
+	return f.PauseContainers(ctx, f.byLabel[labelKey+"="+labelValue])
+}
+
+func (f *fakeManager) PauseContainersAttachedToVolume(ctx context.Context, volume string) ([]string, error) {
+	return f.PauseContainers(ctx, f.byVolume[volume])
+}
+
+func (f *fakeManager) UnpauseContainers(ctx context.Context, ids []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unpaused = append(f.unpaused, ids...)
+	return nil
+}
+
+func (f *fakeManager) StartContainers(ctx context.Context, ids []string) error {
+	// Like the docker client, refuse to do anything with a cancelled context.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for _, id := range ids {
+		if f.wontStart[id] {
+			errs = append(errs, errors.New("failed to start container "+id))
+			continue
+		}
+		f.started = append(f.started, id)
+	}
+	return errors.Join(errs...)
+}
+
+func (f *fakeManager) ExecInContainers(ctx context.Context, ids []string, cmd []string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ran []string
+	var errs []error
+	for _, id := range ids {
+		exec := id + ": " + strings.Join(cmd, " ")
+		f.execs = append(f.execs, exec)
+		if f.failingExecs[exec] {
+			errs = append(errs, errors.New("exited with code 1"))
+			continue
+		}
+		ran = append(ran, id)
+	}
+	return ran, errors.Join(errs...)
+}
+
+// fakeSyncer runs sync in place of a real sync, reporting files and bytes
+// transferred.
+type fakeSyncer struct {
+	sync    func() error
+	files   int64
+	bytes   int64
+	skipped []string
+	closed  bool
+}
+
+func (f *fakeSyncer) SyncWithStats(ctx context.Context, src, dst string) (syncer.Stats, error) {
+	return syncer.Stats{Transfers: f.files, Bytes: f.bytes, Skipped: f.skipped}, f.sync()
+}
+
+func (f *fakeSyncer) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestExitOnFailure(t *testing.T) {
+	failure := notify.Event{Status: notify.StatusFailure, Volume: "vol"}
+	success := notify.Event{Status: notify.StatusSuccess, Volume: "vol"}
+	tests := []struct {
+		name         string
+		failOnError  bool
+		shuttingDown bool
+		ev           notify.Event
+		want         bool
+	}{
+		{name: "Failure", failOnError: true, ev: failure, want: true},
+		{name: "Success", failOnError: true, ev: success, want: false},
+		{name: "FailureWithOptionOff", failOnError: false, ev: failure, want: false},
+		{name: "FailureWhileShuttingDown", failOnError: true, shuttingDown: true, ev: failure, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, exitOnFailure(tt.failOnError, tt.shuttingDown, tt.ev))
+		})
+	}
+}
+
+func TestSkipIfRunning(t *testing.T) {
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	runs := 0
+
+	job := skipIfRunning("vol", func() {
+		runs++
+		started <- struct{}{}
+		<-release
+	})
+
+	done := make(chan struct{})
+	go func() {
+		job()
+		close(done)
+	}()
+	<-started
+
+	// The slow run is still holding the job, so this one must return
+	// immediately instead of blocking on release.
+	job()
+
+	close(release)
+	<-done
+	require.Equal(t, 1, runs)
+
+	// Once the previous run has finished the job runs again.
+	job()
+	require.Equal(t, 2, runs)
+}
+
+func TestJitterDelay(t *testing.T) {
+	for range 1000 {
+		d := jitterDelay(time.Minute)
+		require.GreaterOrEqual(t, d, time.Duration(0))
+		require.Less(t, d, time.Minute)
+	}
+}
+
+func TestWithJitter(t *testing.T) {
+	t.Run("Delays", func(t *testing.T) {
+		var ranAfter time.Duration
+		start := time.Now()
+		withJitter(context.Background(), "vol", 50*time.Millisecond, func() { ranAfter = time.Since(start) })()
+		require.NotZero(t, ranAfter)
+		require.Less(t, ranAfter, time.Second)
+	})
+
+	t.Run("Off", func(t *testing.T) {
+		runs := 0
+		withJitter(context.Background(), "vol", 0, func() { runs++ })()
+		require.Equal(t, 1, runs)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		// A shutdown during the delay drops the run instead of waiting it out.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		runs := 0
+		start := time.Now()
+		withJitter(ctx, "vol", time.Hour, func() { runs++ })()
+		require.Zero(t, runs)
+		require.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestShutdown_RestartsStoppedContainers(t *testing.T) {
+	c := cron.New()
+	c.Start()
+
+	stopped := newStoppedContainers()
+	stopped.add([]string{"c1", "c2"})
+	starter := &fakeManager{}
+	_, cancel := context.WithCancel(context.Background())
+
+	shutdown(c, cancel, time.Second, stopped, nil, starter, config.QuiesceStop)
+
+	require.ElementsMatch(t, []string{"c1", "c2"}, starter.started)
+	require.Empty(t, stopped.drain())
+}
+
+func TestShutdown_ClosesSyncers(t *testing.T) {
+	c := cron.New()
+	c.Start()
+	_, cancel := context.WithCancel(context.Background())
+	s1, s2 := &fakeSyncer{}, &fakeSyncer{}
+	syncers := &openSyncers{}
+	syncers.add(s1)
+	syncers.add(s2)
+
+	shutdown(c, cancel, time.Second, newStoppedContainers(), syncers, &fakeManager{}, config.QuiesceStop)
+
+	require.True(t, s1.closed)
+	require.True(t, s2.closed)
+}
+
+func TestShutdown_WaitsForRunningBackup(t *testing.T) {
+	c := cron.New(cron.WithSeconds())
+	stopped := newStoppedContainers()
+	starter := &fakeManager{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	running := make(chan struct{})
+	var once sync.Once
+	_, err := c.AddFunc("* * * * * *", func() {
+		once.Do(func() {
+			stopped.add([]string{"c1"})
+			close(running)
+			<-ctx.Done()
+			// A backup that winds down cleanly restarts its own containers.
+			_ = starter.StartContainers(context.Background(), []string{"c1"})
+			stopped.remove([]string{"c1"})
+		})
+	})
+	require.NoError(t, err)
+	c.Start()
+	<-running
+
+	shutdown(c, cancel, 5*time.Second, stopped, nil, starter, config.QuiesceStop)
+
+	require.Equal(t, []string{"c1"}, starter.started, "containers must be restarted exactly once")
+}
+
+func TestShutdown_TimesOut(t *testing.T) {
+	c := cron.New(cron.WithSeconds())
+	stopped := newStoppedContainers()
+	starter := &fakeManager{}
+	_, cancel := context.WithCancel(context.Background())
+
+	running := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	var once sync.Once
+	_, err := c.AddFunc("* * * * * *", func() {
+		once.Do(func() {
+			stopped.add([]string{"c1"})
+			close(running)
+			// Ignores cancellation, like a wedged upload.
+			<-release
+		})
+	})
+	require.NoError(t, err)
+	c.Start()
+	<-running
+
+	start := time.Now()
+	shutdown(c, cancel, 100*time.Millisecond, stopped, nil, starter, config.QuiesceStop)
+
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Equal(t, []string{"c1"}, starter.started)
+}
+
+func TestSyncJob_RestartsContainers(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		ContainerIDs:  []string{"c1", "c2"},
+	}
+
+	tests := []struct {
+		name      string
+		sync      func() error
+		wantPanic bool
+	}{
+		{name: "Success", sync: func() error { return nil }},
+		{name: "SyncError", sync: func() error { return errors.New("upload failed") }},
+		{name: "SyncPanic", sync: func() error { panic("boom") }, wantPanic: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := &fakeManager{}
+			tracker := newStoppedContainers()
+			done := false
+
+			run := syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: tt.sync}, config.StopFailureAbort, config.QuiesceStop, 0, nil, tracker, func(notify.Event) { done = true })
+			if tt.wantPanic {
+				require.Panics(t, run)
+			} else {
+				run()
+			}
+
+			require.Equal(t, []string{"c1", "c2"}, mgr.started)
+			require.Empty(t, tracker.drain())
+			require.True(t, done)
+		})
+	}
+}
+
+func TestSyncJob_RestartsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		ContainerIDs:  []string{"c1"},
+	}
+
+	mgr := &fakeManager{}
+	sync := func() error {
+		cancel()
+		return context.Canceled
+	}
+
+	syncJob(ctx, job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: sync}, config.StopFailureAbort, config.QuiesceStop, 0, nil, newStoppedContainers(), nil)()
+
+	require.Equal(t, []string{"c1"}, mgr.started)
+}
+
+// stallingSyncer is a sync whose remote stops responding: it blocks until its
+// context ends.
+type stallingSyncer struct{}
+
+func (stallingSyncer) SyncWithStats(ctx context.Context, src, dst string) (syncer.Stats, error) {
+	<-ctx.Done()
+	return syncer.Stats{Bytes: 7}, fmt.Errorf("sync failed: %w", ctx.Err())
+}
+
+func (stallingSyncer) Close() error { return nil }
+
+func TestSyncJob_Timeout(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		ContainerIDs:  []string{"c1"},
+	}
+	mgr := &fakeManager{}
+	var got notify.Event
+
+	start := time.Now()
+	syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, stallingSyncer{}, config.StopFailureAbort, config.QuiesceStop, 50*time.Millisecond, nil, newStoppedContainers(), func(ev notify.Event) { got = ev })()
+
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Equal(t, []string{"c1"}, mgr.started)
+	require.Equal(t, notify.StatusFailure, got.Status)
+	require.Contains(t, got.Error, "timed out after 50ms")
+	require.Equal(t, int64(7), got.Bytes)
+}
+
+func TestSyncJob_StopsContainersByLabel(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: false,
+		ContainerIDs:  []string{"app"},
+		StopLabels:    map[string]string{"com.docker.compose.project": "myapp"},
+	}
+	mgr := &fakeManager{
+		byLabel: map[string][]string{"com.docker.compose.project=myapp": {"db", "worker"}},
+	}
+
+	syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: func() error { return nil }}, config.StopFailureAbort, config.QuiesceStop, 0, nil, newStoppedContainers(), nil)()
+
+	// volumesync.stop=false keeps the labelled container itself running.
+	require.ElementsMatch(t, []string{"db", "worker"}, mgr.started)
+}
+
+func TestSyncJob_StopsAttachedContainers(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		StopAttached:  true,
+		ContainerIDs:  []string{"app"},
+	}
+	mgr := &fakeManager{
+		byVolume: map[string][]string{"vol": {"db"}},
+	}
+
+	syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: func() error { return nil }}, config.StopFailureAbort, config.QuiesceStop, 0, nil, newStoppedContainers(), nil)()
+
+	require.ElementsMatch(t, []string{"app", "db"}, mgr.started)
+}
+
+func TestSyncJob_PausesContainers(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		StopAttached:  true,
+		ContainerIDs:  []string{"app"},
+		StopLabels:    map[string]string{"com.docker.compose.project": "myapp"},
+	}
+	mgr := &fakeManager{
+		byLabel:  map[string][]string{"com.docker.compose.project=myapp": {"worker"}},
+		byVolume: map[string][]string{"vol": {"db"}},
+	}
+	tracker := newStoppedContainers()
+
+	var pausedDuringSync []string
+	sync := func() error {
+		pausedDuringSync = append([]string(nil), mgr.paused...)
+		return nil
+	}
+	var got notify.Event
+	syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: sync}, config.StopFailureAbort, config.QuiescePause, 0, nil, tracker, func(ev notify.Event) { got = ev })()
+
+	require.Equal(t, notify.StatusSuccess, got.Status)
+	require.ElementsMatch(t, []string{"app", "worker", "db"}, pausedDuringSync)
+	require.ElementsMatch(t, []string{"app", "worker", "db"}, mgr.unpaused)
+	require.Empty(t, mgr.started)
+	require.Empty(t, tracker.drain())
+}
+
+func TestSyncJob_ExecsInContainers(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:   "vol",
+		ContainerIDs: []string{"redis", "replica"},
+		QuiesceExec:  []string{"redis-cli", "BGSAVE"},
+		ReleaseExec:  []string{"redis-cli", "PING"},
+	}
+
+	tests := []struct {
+		name         string
+		job          config.VolumeJob
+		failingExecs map[string]bool
+		wantSync     bool
+		wantStatus   string
+		// wantQuiesced is how many commands have run by the time of the sync.
+		wantQuiesced int
+		wantExecs    []string
+		wantError    string
+	}{
+		{
+			name:         "Success",
+			job:          job,
+			wantSync:     true,
+			wantStatus:   notify.StatusSuccess,
+			wantQuiesced: 2,
+			wantExecs:    []string{"redis: redis-cli BGSAVE", "replica: redis-cli BGSAVE", "redis: redis-cli PING", "replica: redis-cli PING"},
+		},
+		{
+			// Only the container that was quiesced is released.
+			name:         "QuiesceFails",
+			job:          job,
+			failingExecs: map[string]bool{"replica: redis-cli BGSAVE": true},
+			wantStatus:   notify.StatusFailure,
+			wantExecs:    []string{"redis: redis-cli BGSAVE", "replica: redis-cli BGSAVE", "redis: redis-cli PING"},
+			wantError:    "quiesce command failed: exited with code 1",
+		},
+		{
+			name:         "ReleaseFails",
+			job:          job,
+			failingExecs: map[string]bool{"redis: redis-cli PING": true},
+			wantSync:     true,
+			wantStatus:   notify.StatusFailure,
+			wantQuiesced: 2,
+			wantExecs:    []string{"redis: redis-cli BGSAVE", "replica: redis-cli BGSAVE", "redis: redis-cli PING", "replica: redis-cli PING"},
+			wantError:    "release command failed: exited with code 1",
+		},
+		{
+			name: "ReleaseOnly",
+			job: config.VolumeJob{
+				VolumeName:   "vol",
+				ContainerIDs: []string{"app"},
+				ReleaseExec:  []string{"fsfreeze", "--unfreeze", "/data"},
+			},
+			wantSync:   true,
+			wantStatus: notify.StatusSuccess,
+			wantExecs:  []string{"app: fsfreeze --unfreeze /data"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := &fakeManager{failingExecs: tt.failingExecs}
+
+			synced := false
+			var execsBeforeSync int
+			sync := func() error {
+				synced = true
+				execsBeforeSync = len(mgr.execs)
+				return nil
+			}
+			var got notify.Event
+			syncJob(context.Background(), tt.job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: sync}, config.StopFailureAbort, config.QuiesceStop, 0, nil, newStoppedContainers(), func(ev notify.Event) { got = ev })()
+
+			require.Equal(t, tt.wantSync, synced)
+			require.Equal(t, tt.wantStatus, got.Status)
+			require.Equal(t, tt.wantExecs, mgr.execs)
+			require.Equal(t, tt.wantQuiesced, execsBeforeSync)
+			if tt.wantError != "" {
+				require.Contains(t, got.Error, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestShutdown_UnpausesPausedContainers(t *testing.T) {
+	c := cron.New()
+	c.Start()
+
+	paused := newStoppedContainers()
+	paused.add([]string{"c1"})
+	mgr := &fakeManager{}
+	_, cancel := context.WithCancel(context.Background())
+
+	shutdown(c, cancel, time.Second, paused, nil, mgr, config.QuiescePause)
+
+	require.Equal(t, []string{"c1"}, mgr.unpaused)
+	require.Empty(t, mgr.started)
+}
+
+func TestSyncJob_StopFailurePolicy(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		ContainerIDs:  []string{"c1", "stuck"},
+	}
+
+	tests := []struct {
+		policy config.StopFailurePolicy
+		// wantSync is whether the backup runs at all.
+		wantSync bool
+		// wantStartedDuringSync is what has been restarted by the time the
+		// backup runs.
+		wantStartedDuringSync []string
+	}{
+		{policy: config.StopFailureAbort},
+		{policy: config.StopFailureSkip, wantSync: true},
+		{policy: config.StopFailureProceedAndRestart, wantSync: true, wantStartedDuringSync: []string{"c1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			mgr := &fakeManager{failing: map[string]bool{"stuck": true}}
+			tracker := newStoppedContainers()
+			synced := false
+			var startedDuringSync []string
+			sync := func() error {
+				synced = true
+				startedDuringSync = mgr.startedContainers()
+				return nil
+			}
+
+			syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: sync}, tt.policy, config.QuiesceStop, 0, nil, tracker, nil)()
+
+			require.Equal(t, tt.wantSync, synced)
+			require.Equal(t, tt.wantStartedDuringSync, startedDuringSync)
+			// Whatever was stopped is restarted exactly once, and the
+			// container that never stopped is left alone.
+			require.Equal(t, []string{"c1"}, mgr.started)
+			require.Empty(t, tracker.drain())
+		})
+	}
+}
+
+func TestSyncJob_ReportsOutcome(t *testing.T) {
+	job := config.VolumeJob{
+		VolumeName:    "vol",
+		StopContainer: true,
+		ContainerIDs:  []string{"c1", "stuck"},
+	}
+
+	tests := []struct {
+		name      string
+		failing   map[string]bool
+		wontStart map[string]bool
+		sync      func() error
+		skipped   []string
+		want      notify.Event
+	}{
+		{
+			name: "Success",
+			sync: func() error { return nil },
+			want: notify.Event{Status: notify.StatusSuccess, Volume: "vol", Files: 3, Bytes: 42},
+		},
+		{
+			name:    "SkippedFiles",
+			sync:    func() error { return nil },
+			skipped: []string{"db/locked.db"},
+			want:    notify.Event{Status: notify.StatusSuccess, Volume: "vol", Files: 3, Bytes: 42, Skipped: []string{"db/locked.db"}},
+		},
+		{
+			name: "SyncError",
+			sync: func() error { return errors.New("upload failed") },
+			want: notify.Event{Status: notify.StatusFailure, Volume: "vol", Files: 3, Bytes: 42, Error: "upload failed"},
+		},
+		{
+			name:    "StopError",
+			failing: map[string]bool{"stuck": true},
+			sync:    func() error { return nil },
+			want:    notify.Event{Status: notify.StatusFailure, Volume: "vol", Error: "failed to stop container stuck"},
+		},
+		{
+			// The backup went through but left the app down.
+			name:      "RestartError",
+			wontStart: map[string]bool{"c1": true},
+			sync:      func() error { return nil },
+			want:      notify.Event{Status: notify.StatusFailure, Volume: "vol", Files: 3, Bytes: 42, Error: "failed to restart containers: failed to start container c1"},
+		},
+		{
+			name:      "SyncAndRestartError",
+			wontStart: map[string]bool{"c1": true},
+			sync:      func() error { return errors.New("upload failed") },
+			want:      notify.Event{Status: notify.StatusFailure, Volume: "vol", Files: 3, Bytes: 42, Error: "upload failed; failed to restart containers: failed to start container c1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := &fakeManager{failing: tt.failing, wontStart: tt.wontStart}
+			var got notify.Event
+
+			syncJob(context.Background(), job, "/volumes/vol", "remote:vol", mgr, &fakeSyncer{sync: tt.sync, files: 3, bytes: 42, skipped: tt.skipped}, config.StopFailureAbort, config.QuiesceStop, 0, nil, newStoppedContainers(), func(ev notify.Event) { got = ev })()
+
+			got.DurationSeconds = 0
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestInitialSync_ResumesAfterCrash(t *testing.T) {
+	volumeDir := t.TempDir()
+	sentinel := filepath.Join(volumeDir, config.DefaultSentinelFile)
+
+	// The first restore dies partway through, leaving some files behind.
+	calls := 0
+	s := &fakeSyncer{sync: func() error {
+		calls++
+		if calls == 1 {
+			require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "partial.db"), []byte("x"), 0644))
+			return errors.New("connection reset")
+		}
+		return nil
+	}}
+
+	_, err := initialSync(context.Background(), volumeDir, sentinel, "remote:vol", s, nil, nil)
+	require.Error(t, err)
+	_, err = os.Stat(sentinel)
+	require.True(t, os.IsNotExist(err), "sentinel written for an incomplete restore")
+
+	// The next start restores again, and only then marks the volume done.
+	stats, err := initialSync(context.Background(), volumeDir, sentinel, "remote:vol", s, nil, nil)
+	require.NoError(t, err)
+	require.NotNil(t, stats)
+	require.Equal(t, 2, calls)
+	_, err = os.Stat(sentinel)
+	require.NoError(t, err)
+
+	// No temporary sentinel is left behind.
+	leftovers, err := filepath.Glob(sentinel + ".*")
+	require.NoError(t, err)
+	require.Empty(t, leftovers)
+
+	// From then on the restore is skipped.
+	stats, err = initialSync(context.Background(), volumeDir, sentinel, "remote:vol", s, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, stats)
+	require.Equal(t, 2, calls)
+}
+
+func TestInitialSync_SentinelDisabled(t *testing.T) {
+	volumeDir := t.TempDir()
+	calls := 0
+	s := &fakeSyncer{sync: func() error {
+		calls++
+		return nil
+	}}
+
+	// Every start restores, and nothing is left in the volume.
+	for range 2 {
+		stats, err := initialSync(context.Background(), volumeDir, "", "remote:vol", s, nil, nil)
+		require.NoError(t, err)
+		require.NotNil(t, stats)
+	}
+	require.Equal(t, 2, calls)
+	entries, err := os.ReadDir(volumeDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestSentinelPath(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.GlobalConfig
+		want string
+	}{
+		{name: "Default", want: "/volumes/vol/.volumesync_done"},
+		{name: "CustomName", cfg: config.GlobalConfig{SentinelFile: ".restored"}, want: "/volumes/vol/.restored"},
+		{name: "OutsideVolume", cfg: config.GlobalConfig{SentinelDir: "/state"}, want: "/state/vol/.volumesync_done"},
+		{name: "Disabled", cfg: config.GlobalConfig{SentinelDisable: true, SentinelDir: "/state"}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, sentinelPath(&tt.cfg, "/volumes/vol"))
+		})
+	}
+}
+
+func TestInitialSync_SentinelOutsideVolume(t *testing.T) {
+	volumeDir := t.TempDir()
+	sentinel := filepath.Join(t.TempDir(), "state", "vol", config.DefaultSentinelFile)
+	calls := 0
+	s := &fakeSyncer{sync: func() error {
+		calls++
+		return nil
+	}}
+
+	_, err := initialSync(context.Background(), volumeDir, sentinel, "remote:vol", s, nil, nil)
+	require.NoError(t, err)
+	require.FileExists(t, sentinel)
+	require.NoFileExists(t, filepath.Join(volumeDir, config.DefaultSentinelFile))
+
+	stats, err := initialSync(context.Background(), volumeDir, sentinel, "remote:vol", s, nil, nil)
+	require.NoError(t, err)
+	require.Nil(t, stats)
+	require.Equal(t, 1, calls)
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(path, []byte("old"), 0644))
+
+	require.NoError(t, writeFileAtomic(path, []byte("new")))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "new", string(got))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, config.LogFormatJSON, slog.LevelWarn)
+
+	logger.Info("dropped")
+	logger.Warn("Backup skipped", "volume", "vol")
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	require.Equal(t, "Backup skipped", got["msg"])
+	require.Equal(t, "WARN", got["level"])
+	require.Equal(t, "vol", got["volume"])
+}
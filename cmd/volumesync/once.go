@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+	"github.com/dedalusj/docker-volume-sync/internal/notify"
+)
+
+// Exit codes of a one-off run.
+const (
+	// exitOK means every volume synced.
+	exitOK = 0
+	// exitFailed means at least one volume failed to sync.
+	exitFailed = 1
+	// exitUsage means the run was misconfigured and nothing was synced.
+	exitUsage = 2
+)
+
+// jobManager is the part of the docker manager a one-off run needs.
+type jobManager interface {
+	containerManager
+	DiscoverJobs(ctx context.Context) ([]config.VolumeJob, error)
+}
+
+// runOnce syncs every discovered volume in the configured direction straight
+// away and returns the exit code for the process. A backup stops and restarts
+// containers just like a scheduled one. A restore ignores the sentinel and
+// always runs, also with the containers stopped; as it deletes files from
+// volumes labelled for deletion, those need confirmDelete.
+func runOnce(ctx context.Context, globalCfg *config.GlobalConfig, mgr jobManager, notifier *notify.Webhook, volumesDir string, confirmDelete bool) int {
+	direction := globalCfg.SyncDirection
+
+	jobs, err := mgr.DiscoverJobs(ctx)
+	if err != nil {
+		slog.Error("Error discovering jobs", "error", err)
+		return exitFailed
+	}
+	if len(jobs) == 0 {
+		slog.Warn("No volumes found to sync")
+		return exitOK
+	}
+
+	if direction == config.SyncRestore && !confirmDelete {
+		var destructive []string
+		for _, job := range jobs {
+			if job.Delete {
+				destructive = append(destructive, job.VolumeName)
+			}
+		}
+		if len(destructive) > 0 {
+			slog.Error("Restoring would delete files from volumes labelled volumesync.delete=true, pass -confirm-delete to go ahead", "volumes", destructive)
+			return exitUsage
+		}
+	}
+
+	failed := 0
+	for _, job := range jobs {
+		if !runJobOnce(ctx, globalCfg, mgr, notifier, volumesDir, job) {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		slog.Error("Sync failed", "direction", direction, "failed", failed, "volumes", len(jobs))
+		return exitFailed
+	}
+	slog.Info("Sync completed", "direction", direction, "volumes", len(jobs))
+	return exitOK
+}
+
+// runJobOnce syncs a single volume, reporting whether it succeeded.
+func runJobOnce(ctx context.Context, globalCfg *config.GlobalConfig, mgr jobManager, notifier *notify.Webhook, volumesDir string, job config.VolumeJob) bool {
+	volumePath := filepath.Join(volumesDir, job.VolumeName)
+	s, remotePath, err := newJobSyncer(ctx, globalCfg, job)
+	if err != nil {
+		slog.Error("Failed to create syncer", "volume", job.VolumeName, "error", err)
+		return false
+	}
+	defer s.Close()
+
+	if globalCfg.SyncDirection == config.SyncRestore {
+		return restoreJob(ctx, job, volumePath, sentinelPath(globalCfg, volumePath), remotePath, mgr, s)
+	}
+
+	ok := false
+	syncJob(ctx, job, volumePath, remotePath, mgr, s, globalCfg.StopFailurePolicy, globalCfg.QuiesceMode, globalCfg.SyncTimeout, newSyncHooks(globalCfg), newStoppedContainers(), func(ev notify.Event) {
+		ok = ev.Status == notify.StatusSuccess
+		if notifier != nil {
+			notifier.Notify(context.WithoutCancel(ctx), ev)
+		}
+	})()
+	return ok
+}
+
+// restoreJob restores a volume over whatever it currently holds, with the
+// job's containers stopped so the app isn't running while its data is
+// replaced. Whatever the stop failure policy, a container that won't stop
+// cancels the restore, and a container that won't start again fails it.
+func restoreJob(ctx context.Context, job config.VolumeJob, volumePath, sentinelPath, remotePath string, mgr containerManager, s volumeSyncer) (ok bool) {
+	slog.Info("Starting restore", "volume", job.VolumeName)
+
+	tracker := newStoppedContainers()
+	stopped, err := quiesceContainers(ctx, job, mgr, config.QuiesceStop)
+	tracker.add(stopped)
+	defer func() {
+		if restartContainers(ctx, job.VolumeName, mgr, config.QuiesceStop, tracker, stopped) != nil {
+			ok = false
+		}
+	}()
+	if err != nil {
+		slog.Error("Error stopping containers, skipping restore", "volume", job.VolumeName, "error", err)
+		return false
+	}
+
+	if _, err := restore(ctx, volumePath, sentinelPath, remotePath, s, job.UID, job.GID); err != nil {
+		slog.Error("Error restoring volume", "volume", job.VolumeName, "error", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+// onceFixture is a volume holding data.db, backed up to a local destination.
+type onceFixture struct {
+	volumesDir string
+	volumeDir  string
+	destDir    string
+	globalCfg  *config.GlobalConfig
+	job        config.VolumeJob
+}
+
+func newOnceFixture(t *testing.T) *onceFixture {
+	t.Helper()
+	tmpDir := t.TempDir()
+	f := &onceFixture{
+		volumesDir: filepath.Join(tmpDir, "volumes"),
+		volumeDir:  filepath.Join(tmpDir, "volumes", "vol"),
+		destDir:    filepath.Join(tmpDir, "dest"),
+	}
+	f.globalCfg = &config.GlobalConfig{
+		DestinationPath:     f.destDir,
+		Location:            time.UTC,
+		PreservePermissions: true,
+		PreserveMtime:       true,
+		StopFailurePolicy:   config.StopFailureAbort,
+		RunMode:             config.RunModeOnce,
+		SyncDirection:       config.SyncBackup,
+		MaxDeleteRatio:      0.5,
+	}
+	f.job = config.VolumeJob{
+		VolumeName:    "vol",
+		SubPath:       "vol",
+		Concurrency:   4,
+		StopContainer: true,
+		ContainerIDs:  []string{"app"},
+	}
+
+	require.NoError(t, os.MkdirAll(f.volumeDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(f.volumeDir, "data.db"), []byte("hello"), 0644))
+	return f
+}
+
+func (f *onceFixture) run(mgr *fakeManager, direction config.SyncDirection, confirmDelete bool) int {
+	f.globalCfg.SyncDirection = direction
+	return runOnce(context.Background(), f.globalCfg, mgr, nil, f.volumesDir, confirmDelete)
+}
+
+func TestRunOnce(t *testing.T) {
+	f := newOnceFixture(t)
+	mgr := &fakeManager{jobs: []config.VolumeJob{f.job}}
+
+	// A backup stops the app, uploads the volume and brings the app back.
+	require.Equal(t, exitOK, f.run(mgr, config.SyncBackup, false))
+	got, err := os.ReadFile(filepath.Join(f.destDir, "vol", "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+	require.Equal(t, []string{"app"}, mgr.started)
+
+	// A restore runs even though the volume has been restored before, and
+	// also stops the app while it runs.
+	require.NoError(t, os.WriteFile(filepath.Join(f.volumeDir, config.DefaultSentinelFile), []byte("done"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(f.volumeDir, "data.db")))
+	require.Equal(t, exitOK, f.run(mgr, config.SyncRestore, false))
+	got, err = os.ReadFile(filepath.Join(f.volumeDir, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+	require.Equal(t, []string{"app", "app"}, mgr.started)
+}
+
+func TestRunOnce_Archive(t *testing.T) {
+	f := newOnceFixture(t)
+	f.globalCfg.BackupMode = config.BackupModeArchive
+	f.globalCfg.ArchiveGzip = true
+	require.NoError(t, os.WriteFile(filepath.Join(f.volumeDir, config.DefaultSentinelFile), []byte("done"), 0644))
+	mgr := &fakeManager{jobs: []config.VolumeJob{f.job}}
+
+	// A backup uploads a single archive in place of the volume's files.
+	require.Equal(t, exitOK, f.run(mgr, config.SyncBackup, false))
+	archives, err := filepath.Glob(filepath.Join(f.destDir, "vol", "backups", "*.tar.gz"))
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	require.NoFileExists(t, filepath.Join(f.destDir, "vol", "data.db"))
+
+	// A restore extracts it, leaving out the sentinel.
+	require.NoError(t, os.RemoveAll(f.volumeDir))
+	require.NoError(t, os.Mkdir(f.volumeDir, 0755))
+	require.Equal(t, exitOK, f.run(mgr, config.SyncRestore, false))
+	got, err := os.ReadFile(filepath.Join(f.volumeDir, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+	sentinel, err := os.ReadFile(filepath.Join(f.volumeDir, config.DefaultSentinelFile))
+	require.NoError(t, err)
+	require.NotEqual(t, "done", string(sentinel), "the sentinel is written afresh by the restore")
+}
+
+func TestRunOnce_RestoreWithDeleteNeedsConfirmation(t *testing.T) {
+	f := newOnceFixture(t)
+	f.job.Delete = true
+	mgr := &fakeManager{jobs: []config.VolumeJob{f.job}}
+	require.Equal(t, exitOK, f.run(mgr, config.SyncBackup, false))
+
+	extra := filepath.Join(f.volumeDir, "extra.txt")
+	require.NoError(t, os.WriteFile(extra, []byte("not backed up"), 0644))
+
+	// Without confirmation nothing is touched.
+	require.Equal(t, exitUsage, f.run(mgr, config.SyncRestore, false))
+	_, err := os.Stat(extra)
+	require.NoError(t, err)
+
+	require.Equal(t, exitOK, f.run(mgr, config.SyncRestore, true))
+	_, err = os.Stat(extra)
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestRunOnce_Failures(t *testing.T) {
+	tests := []struct {
+		name      string
+		direction config.SyncDirection
+		mgr       func(job config.VolumeJob) *fakeManager
+	}{
+		{
+			name:      "DiscoveryError",
+			direction: config.SyncBackup,
+			mgr: func(config.VolumeJob) *fakeManager {
+				return &fakeManager{discoverErr: errors.New("docker unreachable")}
+			},
+		},
+		{
+			name:      "InvalidFilter",
+			direction: config.SyncBackup,
+			mgr: func(job config.VolumeJob) *fakeManager {
+				job.Exclude = []string{"bad{"}
+				return &fakeManager{jobs: []config.VolumeJob{job}}
+			},
+		},
+		{
+			name:      "BackupContainerWontStop",
+			direction: config.SyncBackup,
+			mgr: func(job config.VolumeJob) *fakeManager {
+				return &fakeManager{jobs: []config.VolumeJob{job}, failing: map[string]bool{"app": true}}
+			},
+		},
+		{
+			name:      "RestoreContainerWontStop",
+			direction: config.SyncRestore,
+			mgr: func(job config.VolumeJob) *fakeManager {
+				return &fakeManager{jobs: []config.VolumeJob{job}, failing: map[string]bool{"app": true}}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOnceFixture(t)
+			require.Equal(t, exitFailed, f.run(tt.mgr(f.job), tt.direction, false))
+
+			// The volume was left as it was.
+			got, err := os.ReadFile(filepath.Join(f.volumeDir, "data.db"))
+			require.NoError(t, err)
+			require.Equal(t, "hello", string(got))
+		})
+	}
+}
+
+func TestRunOnce_Snapshots(t *testing.T) {
+	f := newOnceFixture(t)
+	f.globalCfg.SnapshotMode = true
+	mgr := &fakeManager{jobs: []config.VolumeJob{f.job}}
+
+	// Each backup is a full copy under a new dated prefix.
+	require.Equal(t, exitOK, f.run(mgr, config.SyncBackup, false))
+	snapshots, err := filepath.Glob(filepath.Join(f.destDir, "vol", "snapshots", "*.done"))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	first := strings.TrimSuffix(filepath.Base(snapshots[0]), ".done")
+	got, err := os.ReadFile(filepath.Join(f.destDir, "vol", "snapshots", first, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+	require.NoFileExists(t, filepath.Join(f.destDir, "vol", "data.db"))
+
+	// Backups are named to the second, so a later one needs a later second.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	require.NoError(t, os.WriteFile(filepath.Join(f.volumeDir, "data.db"), []byte("changed"), 0644))
+	require.Equal(t, exitOK, f.run(mgr, config.SyncBackup, false))
+
+	// A restore takes the latest snapshot, or the one asked for.
+	require.Equal(t, exitOK, f.run(mgr, config.SyncRestore, false))
+	got, err = os.ReadFile(filepath.Join(f.volumeDir, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(got))
+
+	f.globalCfg.RestoreSnapshot = first
+	require.Equal(t, exitOK, f.run(mgr, config.SyncRestore, false))
+	got, err = os.ReadFile(filepath.Join(f.volumeDir, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+}
+
+func TestRunOnce_RestoreAtTimeNeedsVersions(t *testing.T) {
+	f := newOnceFixture(t)
+	mgr := &fakeManager{jobs: []config.VolumeJob{f.job}}
+	require.Equal(t, exitOK, f.run(mgr, config.SyncBackup, false))
+
+	// A local destination keeps no versions, so rather than restore the
+	// latest files in their place the restore fails.
+	f.globalCfg.RestoreAtTime = time.Now().Add(-time.Hour)
+	require.NoError(t, os.WriteFile(filepath.Join(f.volumeDir, "data.db"), []byte("changed"), 0644))
+	require.Equal(t, exitFailed, f.run(mgr, config.SyncRestore, false))
+	got, err := os.ReadFile(filepath.Join(f.volumeDir, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(got))
+}
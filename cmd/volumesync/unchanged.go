@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// volumeSigner sums up the files of a volume, see syncer.Syncer.Signature.
+type volumeSigner interface {
+	Signature(ctx context.Context, src string) (string, error)
+}
+
+// sentinelSignaturePrefix starts the line of a sentinel holding the signature
+// of the volume as of its last successful backup.
+const sentinelSignaturePrefix = "signature "
+
+// unchangedCheck skips the backups of a volume that hasn't changed since its
+// last successful one, going by the signature of its files. The signature is
+// kept in the volume's sentinel, so that it outlives a restart, or only in
+// memory with sentinels disabled.
+type unchangedCheck struct {
+	name      string
+	localPath string
+	sentinel  string
+	signer    volumeSigner
+
+	mu sync.Mutex
+	// last is the signature as of the last successful backup, "" until one
+	// is known.
+	last string
+	// pending is the signature taken before the backup in flight.
+	pending string
+}
+
+// newUnchangedCheck returns the check of the volume name at localPath, or nil,
+// which lets every backup through, when it isn't enabled or s can't sign the
+// volume.
+func newUnchangedCheck(enabled bool, name, localPath, sentinel string, s volumeSyncer) *unchangedCheck {
+	signer, ok := s.(volumeSigner)
+	if !enabled || !ok {
+		return nil
+	}
+	return &unchangedCheck{name: name, localPath: localPath, sentinel: sentinel, signer: signer}
+}
+
+// unchanged reports whether the volume is as it was at its last successful
+// backup. A volume that can't be signed counts as changed.
+func (u *unchangedCheck) unchanged(ctx context.Context) bool {
+	sig, err := u.signer.Signature(ctx, u.localPath)
+	if err != nil {
+		slog.Warn("Failed to check volume for changes, backing it up", "volume", u.name, "error", err)
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.last == "" && u.sentinel != "" {
+		u.last = readSentinelSignature(u.sentinel)
+	}
+	u.pending = sig
+	return sig == u.last
+}
+
+// record takes in the outcome of a backup that unchanged let through, keeping
+// the signature taken before it once it succeeds.
+func (u *unchangedCheck) record(success bool) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	sig := u.pending
+	u.pending = ""
+	if !success || sig == "" {
+		return
+	}
+	u.last = sig
+	if u.sentinel == "" {
+		return
+	}
+	data := fmt.Sprintf("%s\n%s%s\n", time.Now(), sentinelSignaturePrefix, sig)
+	if err := os.MkdirAll(filepath.Dir(u.sentinel), 0755); err != nil {
+		slog.Warn("Failed to record volume signature", "volume", u.name, "error", err)
+		return
+	}
+	if err := writeFileAtomic(u.sentinel, []byte(data)); err != nil {
+		slog.Warn("Failed to record volume signature", "volume", u.name, "error", err)
+	}
+}
+
+// readSentinelSignature returns the signature recorded in the sentinel at
+// path, or "" if it holds none.
+func readSentinelSignature(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if sig, ok := strings.CutPrefix(scanner.Text(), sentinelSignaturePrefix); ok {
+			return sig
+		}
+	}
+	return ""
+}
+
+// withUnchangedCheck wraps a job so that its runs are skipped, before any
+// container is stopped, while u finds the volume unchanged. The outcome of
+// each run must be passed to u.record.
+func withUnchangedCheck(ctx context.Context, u *unchangedCheck, job func()) func() {
+	if u == nil {
+		return job
+	}
+	return func() {
+		if u.unchanged(ctx) {
+			slog.Info("Volume unchanged since its last backup, skipping", "volume", u.name)
+			return
+		}
+		job()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSigningSyncer signs volumes with sig, or fails to with err.
+type fakeSigningSyncer struct {
+	fakeSyncer
+	sig string
+	err error
+}
+
+func (f *fakeSigningSyncer) Signature(ctx context.Context, src string) (string, error) {
+	return f.sig, f.err
+}
+
+func TestUnchangedCheck(t *testing.T) {
+	ctx := context.Background()
+	sentinel := filepath.Join(t.TempDir(), "vol", ".volumesync_done")
+	s := &fakeSigningSyncer{sig: "one"}
+	u := newUnchangedCheck(true, "vol", "/volumes/vol", sentinel, s)
+	runs := 0
+	run := withUnchangedCheck(ctx, u, func() { runs++ })
+	// tryRun runs the job unless the volume is unchanged, recording the
+	// outcome as the job's onDone would, and reports whether it ran.
+	tryRun := func(success bool) bool {
+		before := runs
+		run()
+		if runs == before {
+			return false
+		}
+		u.record(success)
+		return true
+	}
+
+	// Nothing is known until a backup succeeds.
+	require.True(t, tryRun(false))
+	require.True(t, tryRun(true))
+	require.False(t, tryRun(true))
+
+	s.sig = "two"
+	require.True(t, tryRun(true))
+	require.False(t, tryRun(true))
+
+	// Failing to sign the volume backs it up all the same.
+	s.err = errors.New("permission denied")
+	require.True(t, tryRun(false))
+	s.err = nil
+	require.False(t, tryRun(true))
+
+	// The signature outlives a restart in the sentinel.
+	data, err := os.ReadFile(sentinel)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "\nsignature two\n")
+	u = newUnchangedCheck(true, "vol", "/volumes/vol", sentinel, s)
+	require.True(t, u.unchanged(ctx))
+}
+
+func TestUnchangedCheck_Disabled(t *testing.T) {
+	require.Nil(t, newUnchangedCheck(false, "vol", "/volumes/vol", "", &fakeSigningSyncer{}))
+	// A syncer that can't sign volumes is always backed up.
+	require.Nil(t, newUnchangedCheck(true, "vol", "/volumes/vol", "", &fakeSyncer{}))
+
+	// Without a sentinel, only the running process remembers.
+	u := newUnchangedCheck(true, "vol", "/volumes/vol", "", &fakeSigningSyncer{sig: "one"})
+	require.False(t, u.unchanged(context.Background()))
+	u.record(true)
+	require.True(t, u.unchanged(context.Background()))
+}
@@ -1,23 +1,397 @@
 package config
 
 import (
+	"bufio"
+	"cmp"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/robfig/cron/v3"
+)
+
+// StopFailurePolicy decides what a backup does when a container fails to stop.
+type StopFailurePolicy string
+
+const (
+	// StopFailureAbort skips the backup and restarts whatever was stopped.
+	StopFailureAbort StopFailurePolicy = "abort"
+	// StopFailureSkip backs up anyway with whatever could be stopped.
+	StopFailureSkip StopFailurePolicy = "skip"
+	// StopFailureProceedAndRestart restarts whatever was stopped straight away
+	// and backs up with every container running.
+	StopFailureProceedAndRestart StopFailurePolicy = "proceed-and-restart"
+)
+
+// QuiesceMode is how containers are kept from writing to a volume while it is
+// backed up.
+type QuiesceMode string
+
+const (
+	// QuiesceStop stops the containers and starts them again afterwards.
+	QuiesceStop QuiesceMode = "stop"
+	// QuiescePause freezes the containers' processes and unpauses them
+	// afterwards, which is quicker but leaves unflushed writes in memory.
+	QuiescePause QuiesceMode = "pause"
+)
+
+// VerifyMode selects how each sync is checked once it completes.
+type VerifyMode string
+
+const (
+	// VerifyOff doesn't check.
+	VerifyOff VerifyMode = ""
+	// VerifySize checks that every file has a copy of the same size.
+	VerifySize VerifyMode = "size"
+	// VerifyChecksum also compares checksums.
+	VerifyChecksum VerifyMode = "checksum"
+)
+
+// ContentTypeDetection selects how the content type of each uploaded file is
+// chosen.
+type ContentTypeDetection string
+
+const (
+	// ContentTypeExtension goes by the file's extension.
+	ContentTypeExtension ContentTypeDetection = "extension"
+	// ContentTypeSniff also sniffs the first bytes of files whose extension
+	// isn't known.
+	ContentTypeSniff ContentTypeDetection = "sniff"
+	// ContentTypeOff uploads every file as application/octet-stream.
+	ContentTypeOff ContentTypeDetection = "off"
+)
+
+// CompressionCodec selects how files are compressed at the destination.
+type CompressionCodec string
+
+const (
+	// CompressionGzip compresses with gzip, the codec COMPRESSION has always
+	// used.
+	CompressionGzip CompressionCodec = "gzip"
+	// CompressionZstd compresses with Zstandard, quicker for a similar ratio.
+	CompressionZstd CompressionCodec = "zstd"
+)
+
+// RunMode selects between the long-running scheduler and a single run.
+type RunMode string
+
+const (
+	// RunModeScheduled restores volumes on startup and then backs them up on
+	// their schedules until stopped.
+	RunModeScheduled RunMode = "scheduled"
+	// RunModeOnce syncs every volume straight away and exits.
+	RunModeOnce RunMode = "once"
+)
+
+// SnapshotTimeFormat is how snapshots are named after the UTC time they were
+// taken.
+const SnapshotTimeFormat = "2006-01-02T15:04:05"
+
+// BackupMode selects how volumes are backed up.
+type BackupMode string
+
+const (
+	// BackupModeSync mirrors each volume file by file.
+	BackupModeSync BackupMode = "sync"
+	// BackupModeArchive uploads each backup as a single timestamped tar, and
+	// restores from the latest one.
+	BackupModeArchive BackupMode = "archive"
+)
+
+// SyncDirection is which way a one-off run syncs volumes.
+type SyncDirection string
+
+const (
+	// SyncBackup syncs volumes to the destination.
+	SyncBackup SyncDirection = "backup"
+	// SyncRestore syncs volumes from the destination.
+	SyncRestore SyncDirection = "restore"
+)
+
+// ParseSyncDirection validates a sync direction.
+func ParseSyncDirection(s string) (SyncDirection, error) {
+	switch d := SyncDirection(s); d {
+	case SyncBackup, SyncRestore:
+		return d, nil
+	default:
+		return "", fmt.Errorf("invalid sync direction %q: must be %s or %s", s, SyncBackup, SyncRestore)
+	}
+}
+
+// NotifyOn selects which backup outcomes are sent to the webhook.
+type NotifyOn string
+
+const (
+	NotifyOnFailure NotifyOn = "failure"
+	NotifyOnSuccess NotifyOn = "success"
+	NotifyOnAlways  NotifyOn = "always"
+)
+
+// Log formats accepted by LOG_FORMAT.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
 )
 
 type GlobalConfig struct {
 	DestinationPath string
-	Location        *time.Location
-	Compression     bool
+	// Location is the time zone schedules run in.
+	Location *time.Location
+	// CronWithSeconds makes schedules start with a seconds field.
+	CronWithSeconds bool
+	// Compression is the default for volumes without a compression label, and
+	// CompressionCodec the codec compressed volumes use.
+	Compression      bool
+	CompressionCodec CompressionCodec
+	// IgnorePatterns holds the gitignore-style patterns read from
+	// SYNC_IGNORE_FILE, in file order. They apply to every volume.
+	IgnorePatterns []string
+	// PreservePermissions carries file mode and ownership through the
+	// destination so restores bring them back. On unless
+	// SYNC_PRESERVE_PERMISSIONS is "false".
+	PreservePermissions bool
+	// PreserveMtime gives restored files the modification time of their
+	// backup. On unless SYNC_PRESERVE_MTIME is "false".
+	PreserveMtime bool
+	// PreserveSymlinks backs symlinks up as links instead of skipping them.
+	PreserveSymlinks bool
+	// FollowSymlinks backs up the files and directories symlinks point to in
+	// their place, instead of skipping them. Symlink loops are skipped.
+	FollowSymlinks bool
+	// SkipErrors backs up the rest of a volume when some of its files can't
+	// be read, rather than failing the backup. Unreadable directories still
+	// fail it.
+	SkipErrors bool
+	// Dedup copies files identical to one already uploaded in the same backup
+	// server-side, rather than upload them again.
+	Dedup bool
+	// NonEmptySource fails a backup of a volume with no files in it, as when
+	// the volume failed to mount.
+	NonEmptySource bool
+	// SkipIfUnchanged skips a scheduled backup, containers and all, when
+	// the volume's files are as they were at its last successful one.
+	SkipIfUnchanged bool
+	// MinAge and MaxAge, when set, limit backups to the files last modified
+	// at least MinAge and at most MaxAge ago.
+	MinAge time.Duration
+	MaxAge time.Duration
+	// MinSize and MaxSize, when set, limit backups to the files of at least
+	// MinSize and at most MaxSize.
+	MinSize fs.SizeSuffix
+	MaxSize fs.SizeSuffix
+	// ManifestDir, when set, is the directory where each volume's backups
+	// keep a manifest of the files at the destination, for the next backup
+	// to upload only the changes since. Every FullSyncEvery-th backup is a
+	// full sync all the same.
+	ManifestDir   string
+	FullSyncEvery int
+	// DeleteFirst makes syncs that delete do so before copying anything.
+	DeleteFirst bool
+	// MaxDeleteRatio is the largest share of the destination's files a sync
+	// may delete. Syncs that would delete more are refused; 1 allows any.
+	MaxDeleteRatio float64
+	// MaxRequestsPerSec caps the requests made to the destination by every
+	// sync together. Zero leaves them unlimited.
+	MaxRequestsPerSec float64
+	// PreserveEmptyDirs backs empty directories up and recreates them on
+	// restore.
+	PreserveEmptyDirs bool
+	// ConcurrentRuns lets a scheduled backup start while the previous run of
+	// the same volume is still going. Off by default, so such runs are skipped.
+	ConcurrentRuns bool
+	// ShutdownTimeout bounds how long shutdown waits for a running backup to
+	// finish before restarting the containers it stopped.
+	ShutdownTimeout time.Duration
+	// SyncTimeout bounds each backup run, from stopping the containers to the
+	// end of the sync. Zero means no limit.
+	SyncTimeout time.Duration
+	// SyncJitter delays each scheduled backup by a random duration below it,
+	// so that many instances on the same schedule don't all start at once.
+	// Zero means no delay.
+	SyncJitter time.Duration
+	// MtimeTolerance is how far apart the modification times of a file on
+	// either side may be for it to count as unchanged. Zero leaves it to the
+	// precision of the two sides.
+	MtimeTolerance time.Duration
+	// ProgressInterval, unless zero, is how often the progress of each large
+	// file is logged while it transfers.
+	ProgressInterval time.Duration
+	// Verify, unless off, checks each sync once it completes against a
+	// fresh listing of its destination.
+	Verify VerifyMode
+	// ContentType is how the content type of uploaded files is set.
+	ContentType ContentTypeDetection
+	// StopFailurePolicy applies when a container fails to stop for a backup.
+	StopFailurePolicy StopFailurePolicy
+	// StopExcludeLabels and StopExcludeNames pick containers that are never
+	// stopped or paused, by label, written key or key=value, or by name.
+	StopExcludeLabels []string
+	StopExcludeNames  []string
+	// StopOnlyWriters leaves running the containers that only mount a volume
+	// read-only.
+	StopOnlyWriters bool
+	// StopMatchBindMounts has the containers attached to a volume include
+	// those bind mounting the host directory behind it.
+	StopMatchBindMounts bool
+	// StopOrder names containers to stop in that order, and StopDependsOn
+	// stops containers before the compose services they depend on. Both
+	// restart containers in reverse.
+	StopOrder     []string
+	StopDependsOn bool
+	// DockerHost and DockerAPIVersion, when set, are the docker daemon to
+	// connect to and the API version to speak to it.
+	DockerHost       string
+	DockerAPIVersion string
+	// StopConcurrency is how many containers are stopped or started
+	// at once.
+	StopConcurrency int
+	// PreSyncHook and PostSyncHook are shell commands run before and after
+	// each backup, each for up to HookTimeout. A failing PreSyncHook skips
+	// the backup unless PreSyncHookAbort is off.
+	PreSyncHook      string
+	PostSyncHook     string
+	HookTimeout      time.Duration
+	PreSyncHookAbort bool
+	// QuiesceMode is how containers are kept still during a backup.
+	// Restores always stop them.
+	QuiesceMode QuiesceMode
+	// LogFormat is LogFormatText or LogFormatJSON.
+	LogFormat string
+	// LogLevel is the minimum level logged.
+	LogLevel slog.Level
+	// NotifyWebhookURL, when set, receives a JSON payload after each scheduled
+	// backup whose outcome NotifyOn selects.
+	NotifyWebhookURL string
+	NotifyOn         NotifyOn
+	// StatusAddr, when set, is the address the status endpoint listens on,
+	// serving the last StatusHistorySize sync results.
+	StatusAddr        string
+	StatusHistorySize int
+	RunMode           RunMode
+	// BackupMode is how volumes are backed up and restored. ArchiveGzip
+	// applies to BackupModeArchive.
+	BackupMode  BackupMode
+	ArchiveGzip bool
+	// RetentionCount and RetentionAge bound the archives kept of each volume
+	// in BackupModeArchive, or the snapshots in SnapshotMode, the newest of
+	// which is always kept. Zero means no limit.
+	RetentionCount int
+	RetentionAge   time.Duration
+	// SnapshotMode backs each volume up to a new dated prefix in
+	// BackupModeSync, rather than mirroring it to one. Restores take the
+	// snapshot RestoreSnapshot names, or the latest one when it's empty.
+	SnapshotMode    bool
+	RestoreSnapshot string
+	// RestoreAtTime, unless zero, restores volumes as they were at that time
+	// from the object versions of a versioned bucket.
+	RestoreAtTime time.Time
+	// SentinelFile names the file marking a volume as restored, so that it
+	// isn't restored again on the next start. It is kept at the root of the
+	// volume, living and dying with it, unless SentinelDir is set, in which
+	// case it is kept under SentinelDir in a directory named after the
+	// volume. SentinelDisable restores every volume on every start.
+	SentinelFile    string
+	SentinelDir     string
+	SentinelDisable bool
+	// CircuitThreshold, unless zero, is how many scheduled backups of a
+	// volume have to fail in a row for the next ones to be skipped until
+	// CircuitCooldown has passed.
+	CircuitThreshold int
+	CircuitCooldown  time.Duration
+	// FailOnSyncError exits the process once a scheduled backup fails, after
+	// shutting down as on a stop signal, rather than wait for the next run.
+	FailOnSyncError bool
+	// SkipRemoteDeletes stops backups deleting from the destination, even for
+	// jobs with Delete set. Restores still delete.
+	SkipRemoteDeletes bool
+	// SyncDirection applies to RunModeOnce.
+	SyncDirection SyncDirection
+	// ObjectConcurrency is how many files a sync transfers at once, unless a
+	// job overrides it. See ResolveConcurrency. Zero, from "auto", has each
+	// sync choose it from the files it is about to transfer.
+	ObjectConcurrency int
+	// PartConcurrency is how many parts of a single file are transferred at
+	// once, and UploadPartSize and DownloadPartSize the size of those parts.
+	// Zero leaves rclone's defaults in place, or with ObjectConcurrency on
+	// auto, lets each sync choose the part concurrency too.
+	PartConcurrency  int
+	UploadPartSize   fs.SizeSuffix
+	DownloadPartSize fs.SizeSuffix
+	// S3Region and AWSProfile select the region and the shared config
+	// profile of an S3 destination. Empty leaves the remote's own config, or
+	// the SDK defaults, in place.
+	S3Region   string
+	AWSProfile string
+	// AWSCredentialsFile is the shared credentials file to read keys from,
+	// such as a mounted secret. Empty leaves the SDK to find its own.
+	AWSCredentialsFile string
+	// AssumeRoleARN, when set, is a role S3 destinations are accessed
+	// through, assumed with the credentials found otherwise. ExternalID is
+	// passed along when the role's trust policy requires one.
+	AssumeRoleARN string
+	ExternalID    string
+	// S3ACL is the canned ACL objects are uploaded to S3 with. Empty leaves
+	// the bucket's default in place.
+	S3ACL string
+	// S3ObjectTags are set on every object uploaded to S3.
+	S3ObjectTags map[string]string
+	// S3HTTPTimeout bounds connecting to the destination and each wait for
+	// data from it. Zero keeps rclone's defaults.
+	S3HTTPTimeout time.Duration
+	// S3CABundle is a file of PEM certificates to trust on top of the
+	// system's.
+	S3CABundle string
+}
+
+// S3CannedACLs are the canned ACLs S3 accepts for objects.
+var S3CannedACLs = []string{
+	"private",
+	"public-read",
+	"public-read-write",
+	"authenticated-read",
+	"aws-exec-read",
+	"bucket-owner-read",
+	"bucket-owner-full-control",
 }
 
+// DefaultPartConcurrency is lower than rclone's own default of 4 parts per
+// file, as every file in flight multiplies it.
+const DefaultPartConcurrency = 2
+
+// DefaultSentinelFile is the name of the file marking a volume as restored.
+const DefaultSentinelFile = ".volumesync_done"
+
+// DefaultFullSyncEvery has a daily backup with a manifest reconcile with the
+// destination every few weeks.
+const DefaultFullSyncEvery = 24
+
+// DefaultStopConcurrency stops a handful of containers at once, each
+// of which may take its whole grace period.
+const DefaultStopConcurrency = 4
+
+// DefaultCircuitCooldown is how long backups are skipped for once the circuit
+// opens.
+const DefaultCircuitCooldown = time.Hour
+
+// MinPartSize is the smallest part S3 accepts in a multipart upload, other
+// than the last.
+const MinPartSize = 5 * fs.Mebi
+
 type VolumeJob struct {
-	VolumeName      string
-	Schedule        string
-	Delete          bool
+	VolumeName string
+	Schedule   string
+	Delete     bool
+	// Concurrency is zero when the container carries no concurrency label, in
+	// which case the global default applies. See ResolveConcurrency.
 	Concurrency     int
 	StopContainer   bool
 	StopGracePeriod time.Duration
@@ -32,6 +406,20 @@ type VolumeJob struct {
 	// over includes, and any include restricts the sync to matching paths.
 	Include []string
 	Exclude []string
+	// StopLabels selects further containers to stop during a backup, by label
+	// key and value. An empty value matches any container with the key.
+	StopLabels map[string]string
+	// StopAttached also stops every running container mounting the volume.
+	StopAttached bool
+	// S3Region and AWSProfile override the global settings of the same name
+	// when set. See ResolveS3Region and ResolveAWSProfile.
+	S3Region   string
+	AWSProfile string
+	// QuiesceExec is a command run inside the job's running containers before
+	// the backup, for example to flush the app's data to disk, and
+	// ReleaseExec one run in those same containers afterwards.
+	QuiesceExec []string
+	ReleaseExec []string
 }
 
 // ResolveCompression reports whether compression is enabled for a job, falling
@@ -43,26 +431,624 @@ func (g *GlobalConfig) ResolveCompression(job VolumeJob) bool {
 	return g.Compression
 }
 
+// ResolveS3Region returns the S3 region for a job, falling back to the global
+// setting when the job carries no label override.
+func (g *GlobalConfig) ResolveS3Region(job VolumeJob) string {
+	if job.S3Region != "" {
+		return job.S3Region
+	}
+	return g.S3Region
+}
+
+// ResolveAWSProfile returns the AWS profile for a job, falling back to the
+// global setting when the job carries no label override.
+func (g *GlobalConfig) ResolveAWSProfile(job VolumeJob) string {
+	if job.AWSProfile != "" {
+		return job.AWSProfile
+	}
+	return g.AWSProfile
+}
+
+// ResolveConcurrency returns how many files a job transfers at once, falling
+// back to the global default when the job carries no label override.
+func (g *GlobalConfig) ResolveConcurrency(job VolumeJob) int {
+	if job.Concurrency > 0 {
+		return job.Concurrency
+	}
+	return g.ObjectConcurrency
+}
+
 func LoadGlobal() (*GlobalConfig, error) {
 	dest := os.Getenv("DESTINATION_PATH")
 	if dest == "" {
 		return nil, fmt.Errorf("DESTINATION_PATH environment variable is required")
 	}
+	// Only the syntax is checked here; whether the remote can be reached is
+	// left to startup, once logging is set up.
+	if _, err := fspath.Parse(dest); err != nil {
+		return nil, fmt.Errorf("invalid DESTINATION_PATH %q: %w", dest, err)
+	}
+
+	// Schedules run in CRON_TIMEZONE, falling back to the TZ the container
+	// runs with, and to UTC if neither is set.
+	loc := time.UTC
+	for _, name := range []string{"CRON_TIMEZONE", "TZ"} {
+		tz := os.Getenv(name)
+		if tz == "" {
+			continue
+		}
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", name, tz, err)
+		}
+		loc = l
+		break
+	}
+
+	shutdownTimeout := 30 * time.Second
+	if t := os.Getenv("SHUTDOWN_TIMEOUT"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SHUTDOWN_TIMEOUT: %w", err)
+		}
+		shutdownTimeout = d
+	}
+
+	var syncTimeout time.Duration
+	if t := os.Getenv("SYNC_TIMEOUT"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SYNC_TIMEOUT %q: must be a positive duration such as 2h", t)
+		}
+		syncTimeout = d
+	}
+
+	var syncJitter time.Duration
+	if j := os.Getenv("SYNC_JITTER"); j != "" {
+		d, err := time.ParseDuration(j)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SYNC_JITTER %q: must be a positive duration such as 5m", j)
+		}
+		syncJitter = d
+	}
+
+	circuitThreshold, err := positiveIntEnv("SYNC_CIRCUIT_THRESHOLD", 0)
+	if err != nil {
+		return nil, err
+	}
+	circuitCooldown := DefaultCircuitCooldown
+	if c := os.Getenv("SYNC_CIRCUIT_COOLDOWN"); c != "" {
+		d, err := time.ParseDuration(c)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SYNC_CIRCUIT_COOLDOWN %q: must be a positive duration such as 1h", c)
+		}
+		circuitCooldown = d
+	}
+
+	var mtimeTolerance time.Duration
+	if t := os.Getenv("SYNC_MTIME_TOLERANCE"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SYNC_MTIME_TOLERANCE %q: must be a positive duration such as 1s", t)
+		}
+		mtimeTolerance = d
+	}
+
+	var progressInterval time.Duration
+	if i := os.Getenv("SYNC_PROGRESS_INTERVAL"); i != "" {
+		d, err := time.ParseDuration(i)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid SYNC_PROGRESS_INTERVAL %q: must be a positive duration such as 30s", i)
+		}
+		progressInterval = d
+	}
+
+	verify := VerifyOff
+	if v := os.Getenv("SYNC_VERIFY"); v != "" {
+		switch mode := VerifyMode(v); mode {
+		case VerifySize, VerifyChecksum:
+			verify = mode
+		default:
+			return nil, fmt.Errorf("invalid SYNC_VERIFY %q: must be %s or %s", v, VerifySize, VerifyChecksum)
+		}
+	}
+
+	contentType := ContentTypeExtension
+	if c := os.Getenv("SYNC_CONTENT_TYPE_DETECTION"); c != "" {
+		switch mode := ContentTypeDetection(c); mode {
+		case ContentTypeExtension, ContentTypeSniff, ContentTypeOff:
+			contentType = mode
+		default:
+			return nil, fmt.Errorf("invalid SYNC_CONTENT_TYPE_DETECTION %q: must be %s, %s or %s", c, ContentTypeExtension, ContentTypeSniff, ContentTypeOff)
+		}
+	}
+
+	// SYNC_COMPRESSION supersedes COMPRESSION, which only ever meant gzip.
+	compression, codec := os.Getenv("COMPRESSION") == "true", CompressionGzip
+	if c := os.Getenv("SYNC_COMPRESSION"); c != "" {
+		switch mode := CompressionCodec(c); mode {
+		case CompressionGzip, CompressionZstd:
+			compression, codec = true, mode
+		case "none":
+			compression = false
+		default:
+			return nil, fmt.Errorf("invalid SYNC_COMPRESSION %q: must be none, %s or %s", c, CompressionGzip, CompressionZstd)
+		}
+	}
+
+	stopFailurePolicy := StopFailureAbort
+	if p := os.Getenv("STOP_FAILURE_POLICY"); p != "" {
+		switch policy := StopFailurePolicy(p); policy {
+		case StopFailureAbort, StopFailureSkip, StopFailureProceedAndRestart:
+			stopFailurePolicy = policy
+		default:
+			return nil, fmt.Errorf("invalid STOP_FAILURE_POLICY %q: must be %s, %s or %s", p, StopFailureAbort, StopFailureSkip, StopFailureProceedAndRestart)
+		}
+	}
 
-	loc := time.Local
-	if tz := os.Getenv("TZ"); tz != "" {
-		if l, err := time.LoadLocation(tz); err == nil {
-			loc = l
+	hookTimeout := 5 * time.Minute
+	if t := os.Getenv("HOOK_TIMEOUT"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid HOOK_TIMEOUT %q: must be a positive duration such as 10m", t)
+		}
+		hookTimeout = d
+	}
+
+	quiesceMode := QuiesceStop
+	if q := os.Getenv("CONTAINER_QUIESCE_MODE"); q != "" {
+		switch mode := QuiesceMode(q); mode {
+		case QuiesceStop, QuiescePause:
+			quiesceMode = mode
+		default:
+			return nil, fmt.Errorf("invalid CONTAINER_QUIESCE_MODE %q: must be %s or %s", q, QuiesceStop, QuiescePause)
+		}
+	}
+
+	logFormat := LogFormatText
+	if f := os.Getenv("LOG_FORMAT"); f != "" {
+		if f != LogFormatText && f != LogFormatJSON {
+			return nil, fmt.Errorf("invalid LOG_FORMAT %q: must be %s or %s", f, LogFormatText, LogFormatJSON)
+		}
+		logFormat = f
+	}
+
+	logLevel := slog.LevelInfo
+	if l := os.Getenv("LOG_LEVEL"); l != "" {
+		if err := logLevel.UnmarshalText([]byte(l)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+	}
+
+	notifyOn := NotifyOnFailure
+	if n := os.Getenv("NOTIFY_ON"); n != "" {
+		switch on := NotifyOn(n); on {
+		case NotifyOnFailure, NotifyOnSuccess, NotifyOnAlways:
+			notifyOn = on
+		default:
+			return nil, fmt.Errorf("invalid NOTIFY_ON %q: must be %s, %s or %s", n, NotifyOnFailure, NotifyOnSuccess, NotifyOnAlways)
+		}
+	}
+
+	dockerHost := os.Getenv("DOCKER_HOST")
+	if dockerHost != "" && !strings.Contains(dockerHost, "://") {
+		return nil, fmt.Errorf("invalid DOCKER_HOST %q: must be a URL such as unix:///var/run/docker.sock or tcp://docker:2375", dockerHost)
+	}
+	dockerAPIVersion := os.Getenv("DOCKER_API_VERSION")
+	if dockerAPIVersion != "" {
+		major, minor, ok := strings.Cut(strings.TrimPrefix(dockerAPIVersion, "v"), ".")
+		_, errMajor := strconv.ParseUint(major, 10, 32)
+		_, errMinor := strconv.ParseUint(minor, 10, 32)
+		if !ok || errMajor != nil || errMinor != nil {
+			return nil, fmt.Errorf("invalid DOCKER_API_VERSION %q: must be a version such as 1.43", dockerAPIVersion)
 		}
 	}
 
+	runMode := RunModeScheduled
+	if m := os.Getenv("RUN_MODE"); m != "" {
+		switch mode := RunMode(m); mode {
+		case RunModeScheduled, RunModeOnce:
+			runMode = mode
+		default:
+			return nil, fmt.Errorf("invalid RUN_MODE %q: must be %s or %s", m, RunModeScheduled, RunModeOnce)
+		}
+	}
+
+	backupMode := BackupModeSync
+	if m := os.Getenv("BACKUP_MODE"); m != "" {
+		switch mode := BackupMode(m); mode {
+		case BackupModeSync, BackupModeArchive:
+			backupMode = mode
+		default:
+			return nil, fmt.Errorf("invalid BACKUP_MODE %q: must be %s or %s", m, BackupModeSync, BackupModeArchive)
+		}
+	}
+
+	snapshotMode := os.Getenv("SNAPSHOT_MODE") == "true"
+	if snapshotMode && backupMode == BackupModeArchive {
+		return nil, fmt.Errorf("SNAPSHOT_MODE can't be used with BACKUP_MODE=%s, whose archives are already snapshots", BackupModeArchive)
+	}
+	restoreSnapshot := os.Getenv("RESTORE_SNAPSHOT")
+	if restoreSnapshot != "" {
+		if _, err := time.Parse(SnapshotTimeFormat, restoreSnapshot); err != nil {
+			return nil, fmt.Errorf("invalid RESTORE_SNAPSHOT %q: must be the time of a snapshot such as 2024-01-02T03:04:05", restoreSnapshot)
+		}
+	}
+
+	sentinelFile := DefaultSentinelFile
+	if name := os.Getenv("SENTINEL_FILE"); name != "" {
+		if strings.Contains(name, "/") || name == "." || name == ".." {
+			return nil, fmt.Errorf("invalid SENTINEL_FILE %q: must be a file name, without a directory", name)
+		}
+		// The name goes into the filter rules that keep the sentinel out of
+		// backups, where these would make it a pattern.
+		if strings.ContainsAny(name, `*?[]{}\`) {
+			return nil, fmt.Errorf("invalid SENTINEL_FILE %q: must not contain any of *?[]{}\\", name)
+		}
+		sentinelFile = name
+	}
+	sentinelDir := os.Getenv("SENTINEL_DIR")
+	if sentinelDir != "" && !filepath.IsAbs(sentinelDir) {
+		return nil, fmt.Errorf("invalid SENTINEL_DIR %q: must be an absolute path", sentinelDir)
+	}
+
+	var restoreAtTime time.Time
+	if at := os.Getenv("RESTORE_AT_TIME"); at != "" {
+		t, err := fs.ParseTime(at)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RESTORE_AT_TIME %q: must be a time such as 2024-01-02T03:04:05Z, or a duration ago such as 3d", at)
+		}
+		if backupMode == BackupModeArchive || snapshotMode {
+			return nil, fmt.Errorf("RESTORE_AT_TIME can't be used with BACKUP_MODE=%s or SNAPSHOT_MODE, whose backups are already points in time", BackupModeArchive)
+		}
+		restoreAtTime = t
+	}
+
+	retentionCount, err := positiveIntEnv("BACKUP_RETENTION_COUNT", 0)
+	if err != nil {
+		return nil, err
+	}
+	var retentionAge time.Duration
+	if a := os.Getenv("BACKUP_RETENTION_AGE"); a != "" {
+		d, err := fs.ParseDuration(a)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid BACKUP_RETENTION_AGE %q: must be a positive duration such as 30d", a)
+		}
+		retentionAge = time.Duration(d)
+	}
+
+	var minAge, maxAge time.Duration
+	if a := os.Getenv("SYNC_MIN_AGE"); a != "" {
+		d, err := fs.ParseDuration(a)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SYNC_MIN_AGE %q: must be a positive duration such as 1h", a)
+		}
+		minAge = time.Duration(d)
+	}
+	if a := os.Getenv("SYNC_MAX_AGE"); a != "" {
+		d, err := fs.ParseDuration(a)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SYNC_MAX_AGE %q: must be a positive duration such as 7d", a)
+		}
+		maxAge = time.Duration(d)
+	}
+	if minAge > 0 && maxAge > 0 && minAge >= maxAge {
+		return nil, fmt.Errorf("SYNC_MIN_AGE %s must be less than SYNC_MAX_AGE %s, or no file would be backed up", minAge, maxAge)
+	}
+
+	minSize, err := sizeEnv("SYNC_MIN_SIZE")
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := sizeEnv("SYNC_MAX_SIZE")
+	if err != nil {
+		return nil, err
+	}
+	if minSize > 0 && maxSize > 0 && minSize > maxSize {
+		return nil, fmt.Errorf("SYNC_MIN_SIZE %s must not be more than SYNC_MAX_SIZE %s, or no file would be backed up", minSize, maxSize)
+	}
+
+	if os.Getenv("SYNC_PRESERVE_SYMLINKS") == "true" && os.Getenv("SYNC_FOLLOW_SYMLINKS") == "true" {
+		return nil, fmt.Errorf("SYNC_PRESERVE_SYMLINKS and SYNC_FOLLOW_SYMLINKS can't both be set: a symlink is either backed up as a link or followed")
+	}
+
+	direction := SyncBackup
+	if d := os.Getenv("SYNC_DIRECTION"); d != "" {
+		parsed, err := ParseSyncDirection(d)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SYNC_DIRECTION: %w", err)
+		}
+		direction = parsed
+	}
+
+	objectConcurrency, partConcurrency := 0, 0
+	if os.Getenv("SYNC_OBJECT_CONCURRENCY") != "auto" {
+		objectConcurrency, err = positiveIntEnv("SYNC_OBJECT_CONCURRENCY", 16)
+		if err != nil {
+			return nil, err
+		}
+		partConcurrency = DefaultPartConcurrency
+	}
+	partConcurrency, err = positiveIntEnv("SYNC_PART_CONCURRENCY", partConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	statusHistorySize, err := positiveIntEnv("STATUS_HISTORY_SIZE", 20)
+	if err != nil {
+		return nil, err
+	}
+	stopConcurrency, err := positiveIntEnv("CONTAINER_OP_CONCURRENCY", DefaultStopConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	uploadPartSize, err := partSizeEnv("S3_UPLOAD_PART_SIZE")
+	if err != nil {
+		return nil, err
+	}
+	downloadPartSize, err := partSizeEnv("S3_DOWNLOAD_PART_SIZE")
+	if err != nil {
+		return nil, err
+	}
+
+	maxDeleteRatio := 0.5
+	if r := os.Getenv("SYNC_MAX_DELETE_RATIO"); r != "" {
+		ratio, err := strconv.ParseFloat(r, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("invalid SYNC_MAX_DELETE_RATIO %q: must be a number between 0 and 1", r)
+		}
+		maxDeleteRatio = ratio
+	}
+
+	var maxRequestsPerSec float64
+	if r := os.Getenv("SYNC_MAX_REQUESTS_PER_SEC"); r != "" {
+		rate, err := strconv.ParseFloat(r, 64)
+		if err != nil || math.IsNaN(rate) || math.IsInf(rate, 0) || rate <= 0 {
+			return nil, fmt.Errorf("invalid SYNC_MAX_REQUESTS_PER_SEC %q: must be a positive number", r)
+		}
+		maxRequestsPerSec = rate
+	}
+
+	roleARN := os.Getenv("S3_ASSUME_ROLE_ARN")
+	externalID := os.Getenv("S3_EXTERNAL_ID")
+	if roleARN != "" && (!strings.HasPrefix(roleARN, "arn:") || !strings.Contains(roleARN, ":role/")) {
+		return nil, fmt.Errorf("invalid S3_ASSUME_ROLE_ARN %q: must be an IAM role ARN such as arn:aws:iam::123456789012:role/backup", roleARN)
+	}
+	if externalID != "" && roleARN == "" {
+		return nil, fmt.Errorf("S3_EXTERNAL_ID is set but S3_ASSUME_ROLE_ARN is not")
+	}
+
+	objectTags, err := objectTagsEnv("S3_OBJECT_TAGS")
+	if err != nil {
+		return nil, err
+	}
+
+	acl := os.Getenv("S3_ACL")
+	if acl != "" && !slices.Contains(S3CannedACLs, acl) {
+		return nil, fmt.Errorf("invalid S3_ACL %q: must be one of %s", acl, strings.Join(S3CannedACLs, ", "))
+	}
+
+	credentialsFile := cmp.Or(os.Getenv("AWS_CREDENTIALS_FILE"), os.Getenv("AWS_SHARED_CREDENTIALS_FILE"))
+	if credentialsFile != "" {
+		if _, err := os.Stat(credentialsFile); err != nil {
+			return nil, fmt.Errorf("invalid AWS credentials file: %w", err)
+		}
+	}
+
+	var httpTimeout time.Duration
+	if t := os.Getenv("S3_HTTP_TIMEOUT"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid S3_HTTP_TIMEOUT %q: must be a positive duration such as 30s", t)
+		}
+		httpTimeout = d
+	}
+
+	fullSyncEvery, err := positiveIntEnv("SYNC_FULL_EVERY", DefaultFullSyncEvery)
+	if err != nil {
+		return nil, err
+	}
+
+	var ignore []string
+	if path := os.Getenv("SYNC_IGNORE_FILE"); path != "" {
+		patterns, err := readIgnoreFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SYNC_IGNORE_FILE: %w", err)
+		}
+		ignore = patterns
+	}
+
 	return &GlobalConfig{
-		DestinationPath: dest,
-		Location:        loc,
-		Compression:     os.Getenv("COMPRESSION") == "true",
+		DestinationPath:     dest,
+		Location:            loc,
+		CronWithSeconds:     os.Getenv("CRON_WITH_SECONDS") == "true",
+		Compression:         compression,
+		CompressionCodec:    codec,
+		IgnorePatterns:      ignore,
+		PreservePermissions: os.Getenv("SYNC_PRESERVE_PERMISSIONS") != "false",
+		PreserveMtime:       os.Getenv("SYNC_PRESERVE_MTIME") != "false",
+		PreserveSymlinks:    os.Getenv("SYNC_PRESERVE_SYMLINKS") == "true",
+		FollowSymlinks:      os.Getenv("SYNC_FOLLOW_SYMLINKS") == "true",
+		SkipErrors:          os.Getenv("SYNC_SKIP_ERRORS") == "true",
+		PreserveEmptyDirs:   os.Getenv("SYNC_PRESERVE_EMPTY_DIRS") == "true",
+		DeleteFirst:         os.Getenv("SYNC_DELETE_FIRST") == "true",
+		SkipIfUnchanged:     os.Getenv("SYNC_SKIP_IF_UNCHANGED") == "true",
+		MinAge:              minAge,
+		MaxAge:              maxAge,
+		MinSize:             minSize,
+		MaxSize:             maxSize,
+		Dedup:               os.Getenv("SYNC_DEDUP") == "true",
+		NonEmptySource:      os.Getenv("SYNC_REQUIRE_NONEMPTY_SOURCE") == "true",
+		ManifestDir:         os.Getenv("SYNC_MANIFEST_PATH"),
+		FullSyncEvery:       fullSyncEvery,
+		MaxDeleteRatio:      maxDeleteRatio,
+		MaxRequestsPerSec:   maxRequestsPerSec,
+		S3Region:            os.Getenv("S3_REGION"),
+		AWSProfile:          os.Getenv("AWS_PROFILE"),
+		AWSCredentialsFile:  credentialsFile,
+		AssumeRoleARN:       roleARN,
+		ExternalID:          externalID,
+		S3ACL:               acl,
+		S3ObjectTags:        objectTags,
+		S3HTTPTimeout:       httpTimeout,
+		S3CABundle:          os.Getenv("S3_CA_BUNDLE"),
+		ObjectConcurrency:   objectConcurrency,
+		PartConcurrency:     partConcurrency,
+		UploadPartSize:      uploadPartSize,
+		DownloadPartSize:    downloadPartSize,
+		ConcurrentRuns:      os.Getenv("SYNC_CONCURRENT_RUNS") == "true",
+		ShutdownTimeout:     shutdownTimeout,
+		SyncTimeout:         syncTimeout,
+		SyncJitter:          syncJitter,
+		MtimeTolerance:      mtimeTolerance,
+		ProgressInterval:    progressInterval,
+		Verify:              verify,
+		ContentType:         contentType,
+		StopFailurePolicy:   stopFailurePolicy,
+		StopExcludeLabels:   splitList(os.Getenv("STOP_EXCLUDE_LABELS"), ","),
+		StopExcludeNames:    splitList(os.Getenv("STOP_EXCLUDE_NAMES"), ","),
+		StopOnlyWriters:     os.Getenv("STOP_ONLY_WRITERS") != "false",
+		StopMatchBindMounts: os.Getenv("STOP_MATCH_BIND_MOUNTS") == "true",
+		StopOrder:           splitList(os.Getenv("STOP_ORDER"), ","),
+		StopDependsOn:       os.Getenv("STOP_DEPENDS_ON") == "true",
+		DockerHost:          dockerHost,
+		DockerAPIVersion:    dockerAPIVersion,
+		StopConcurrency:     stopConcurrency,
+		QuiesceMode:         quiesceMode,
+		PreSyncHook:         os.Getenv("PRE_SYNC_HOOK"),
+		PostSyncHook:        os.Getenv("POST_SYNC_HOOK"),
+		HookTimeout:         hookTimeout,
+		PreSyncHookAbort:    os.Getenv("PRE_SYNC_HOOK_ABORT") != "false",
+		LogFormat:           logFormat,
+		LogLevel:            logLevel,
+		NotifyWebhookURL:    os.Getenv("NOTIFY_WEBHOOK_URL"),
+		NotifyOn:            notifyOn,
+		StatusAddr:          os.Getenv("STATUS_ADDR"),
+		StatusHistorySize:   statusHistorySize,
+		RunMode:             runMode,
+		BackupMode:          backupMode,
+		ArchiveGzip:         os.Getenv("ARCHIVE_GZIP") != "false",
+		RetentionCount:      retentionCount,
+		RetentionAge:        retentionAge,
+		SnapshotMode:        snapshotMode,
+		RestoreSnapshot:     restoreSnapshot,
+		RestoreAtTime:       restoreAtTime,
+		SentinelFile:        sentinelFile,
+		SentinelDir:         sentinelDir,
+		SentinelDisable:     os.Getenv("SENTINEL_DISABLE") == "true",
+		SkipRemoteDeletes:   os.Getenv("SYNC_SKIP_REMOTE_DELETES") == "true",
+		FailOnSyncError:     os.Getenv("FAIL_ON_SYNC_ERROR") == "true",
+		CircuitThreshold:    circuitThreshold,
+		CircuitCooldown:     circuitCooldown,
+		SyncDirection:       direction,
 	}, nil
 }
 
+// positiveIntEnv reads a positive integer from the environment variable name,
+// returning def when it is unset.
+func positiveIntEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive integer", name, v)
+	}
+	return n, nil
+}
+
+// partSizeEnv reads a multipart part size, such as "64M", from the environment
+// variable name, returning zero when it is unset.
+func partSizeEnv(name string) (fs.SizeSuffix, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+	var size fs.SizeSuffix
+	if err := size.Set(v); err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if size < MinPartSize {
+		return 0, fmt.Errorf("invalid %s %q: must be at least %s", name, v, MinPartSize)
+	}
+	return size, nil
+}
+
+// sizeEnv reads a file size, such as "10M", from the environment variable
+// name, returning zero when it is unset.
+func sizeEnv(name string) (fs.SizeSuffix, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+	var size fs.SizeSuffix
+	if err := size.Set(v); err != nil || size <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: must be a positive size such as 10M", name, v)
+	}
+	return size, nil
+}
+
+// objectTagsEnv reads S3 object tags, written key=value and separated by
+// commas, from the environment variable name.
+func objectTagsEnv(name string) (map[string]string, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil, nil
+	}
+	tags := map[string]string{}
+	for _, tag := range splitList(v, ",") {
+		key, value, _ := strings.Cut(tag, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case key == "":
+			return nil, fmt.Errorf("invalid %s %q: tag %q has no key", name, v, tag)
+		case len(key) > maxTagKeyLen || len(value) > maxTagValueLen:
+			return nil, fmt.Errorf("invalid %s %q: tag %q is too long, keys are limited to %d characters and values to %d", name, v, tag, maxTagKeyLen, maxTagValueLen)
+		case strings.HasPrefix(strings.ToLower(key), "aws:"):
+			return nil, fmt.Errorf("invalid %s %q: tag key %q uses the reserved aws: prefix", name, v, key)
+		}
+		if _, ok := tags[key]; ok {
+			return nil, fmt.Errorf("invalid %s %q: tag key %q is repeated", name, v, key)
+		}
+		tags[key] = value
+	}
+	if len(tags) > maxObjectTags {
+		return nil, fmt.Errorf("invalid %s %q: S3 allows at most %d tags per object", name, v, maxObjectTags)
+	}
+	return tags, nil
+}
+
+// Limits S3 puts on object tags.
+const (
+	maxObjectTags  = 10
+	maxTagKeyLen   = 128
+	maxTagValueLen = 256
+)
+
+// readIgnoreFile reads a gitignore-style file, returning its patterns in order
+// with blank lines and comments dropped. Negations are kept as written.
+func readIgnoreFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
 const (
 	labelPrefix = "volumesync"
 
@@ -79,46 +1065,131 @@ const (
 	compressionLabel     = labelPrefix + ".compression"
 	includeLabel         = labelPrefix + ".include"
 	excludeLabel         = labelPrefix + ".exclude"
+	stopLabelsLabel      = labelPrefix + ".stop_labels"
+	stopAttachedLabel    = labelPrefix + ".stop_attached"
+	s3RegionLabel        = labelPrefix + ".s3_region"
+	awsProfileLabel      = labelPrefix + ".aws_profile"
+	quiesceExecLabel     = labelPrefix + ".quiesce_exec"
+	releaseExecLabel     = labelPrefix + ".release_exec"
 
 	// patternSeparator splits pattern lists. Not a comma: rclone globs use
 	// commas for brace alternation, as in *.{jpg,png}.
 	patternSeparator = ";"
+
+	// volumeSeparator splits the volume and subpath lists, which are matched
+	// up by position.
+	volumeSeparator = ","
+
+	// scheduleSeparator splits the schedule list. Not a comma: cron uses
+	// commas for lists, as in "0 3,15 * * *".
+	scheduleSeparator = ";"
 )
 
-// parsePatterns splits a pattern label into its individual glob patterns,
-// trimming whitespace and dropping empty entries.
-func parsePatterns(value string) []string {
-	var patterns []string
-	for _, p := range strings.Split(value, patternSeparator) {
-		if p = strings.TrimSpace(p); p != "" {
-			patterns = append(patterns, p)
+// ScheduleParser parses backup schedules: standard five-field cron
+// expressions, or six-field ones starting with seconds if WithSeconds is set.
+// Descriptors such as @daily are accepted either way. The zero value parses
+// five fields.
+type ScheduleParser struct {
+	WithSeconds bool
+}
+
+// Parse implements cron.ScheduleParser. When spec has the wrong number of
+// fields, the error says how many are expected and why.
+func (p ScheduleParser) Parse(spec string) (cron.Schedule, error) {
+	fields := cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor
+	want, format, other := 5, "minute hour day-of-month month day-of-week", "6 with CRON_WITH_SECONDS=true"
+	if p.WithSeconds {
+		fields |= cron.Second
+		want, format, other = 6, "second minute hour day-of-month month day-of-week", "5 without CRON_WITH_SECONDS"
+	}
+	sched, err := cron.NewParser(fields).Parse(spec)
+	if err != nil && !strings.HasPrefix(spec, "@") {
+		if n := len(strings.Fields(spec)); n != want {
+			return nil, fmt.Errorf("has %d fields but schedules take %d (%s), or %s", n, want, format, other)
 		}
 	}
-	return patterns
+	return sched, err
 }
 
-func ParseLabels(labels map[string]string) (*VolumeJob, error) {
+// splitList splits a label into its individual entries, trimming whitespace
+// and dropping empty entries.
+func splitList(value, sep string) []string {
+	var entries []string
+	for _, e := range strings.Split(value, sep) {
+		if e = strings.TrimSpace(e); e != "" {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// parsePatterns splits a pattern label into its individual glob patterns.
+func parsePatterns(value string) []string {
+	return splitList(value, patternSeparator)
+}
+
+// parseCommand parses a command label the way Dockerfiles read CMD: a JSON
+// array is run as is, anything else with sh -c.
+func parseCommand(label, value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, "[") {
+		return []string{"sh", "-c", value}, nil
+	}
+	var cmd []string
+	if err := json.Unmarshal([]byte(value), &cmd); err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", label, value, err)
+	}
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("invalid %s %q: command is empty", label, value)
+	}
+	return cmd, nil
+}
+
+// ParseLabels builds the jobs described by a container's labels, one per
+// volume listed in volumesync.volume. All of them share the container's other
+// settings; volumesync.subpath, when set, must list one subpath per volume, and
+// volumesync.schedule either a single schedule or one per volume, each of which
+// must parse with parser.
+func ParseLabels(labels map[string]string, parser ScheduleParser) ([]VolumeJob, error) {
 	if labels[enabledLabel] != "true" {
 		return nil, nil
 	}
 
-	volume := labels[volumeLabel]
-	if volume == "" {
+	volumes := splitList(labels[volumeLabel], volumeSeparator)
+	if len(volumes) == 0 {
 		return nil, fmt.Errorf("%s is required", volumeLabel)
 	}
 
-	schedule := labels[scheduleLabel]
-	if schedule == "" {
+	subPaths := volumes
+	if sub := labels[subPathLabel]; sub != "" {
+		subPaths = splitList(sub, volumeSeparator)
+		if len(subPaths) != len(volumes) {
+			return nil, fmt.Errorf("%s lists %d entries but %s lists %d", subPathLabel, len(subPaths), volumeLabel, len(volumes))
+		}
+	}
+
+	schedules := splitList(labels[scheduleLabel], scheduleSeparator)
+	if len(schedules) == 0 {
 		return nil, fmt.Errorf("%s is required", scheduleLabel)
 	}
+	if len(schedules) != 1 && len(schedules) != len(volumes) {
+		return nil, fmt.Errorf("%s lists %d entries but %s lists %d", scheduleLabel, len(schedules), volumeLabel, len(volumes))
+	}
+	for _, schedule := range schedules {
+		// The scheduler uses the same parser, so anything accepted here is
+		// guaranteed to schedule.
+		if _, err := parser.Parse(schedule); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", scheduleLabel, schedule, err)
+		}
+	}
 
-	job := &VolumeJob{
-		VolumeName:    volume,
-		Schedule:      schedule,
+	job := VolumeJob{
 		Delete:        labels[deleteLabel] == "true",
-		Concurrency:   16,
 		StopContainer: true,
-		SubPath:       volume,
+		StopAttached:  labels[stopAttachedLabel] == "true",
 	}
 
 	if labels[stopLabel] == "false" {
@@ -142,10 +1213,6 @@ func ParseLabels(labels map[string]string) (*VolumeJob, error) {
 		}
 	}
 
-	if sub := labels[subPathLabel]; sub != "" {
-		job.SubPath = sub
-	}
-
 	if uidStr := labels[uidLabel]; uidStr != "" {
 		uid, err := strconv.Atoi(uidStr)
 		if err == nil {
@@ -167,8 +1234,40 @@ func ParseLabels(labels map[string]string) (*VolumeJob, error) {
 		job.Compression = &compression
 	}
 
+	job.S3Region = strings.TrimSpace(labels[s3RegionLabel])
+	job.AWSProfile = strings.TrimSpace(labels[awsProfileLabel])
+
 	job.Include = parsePatterns(labels[includeLabel])
 	job.Exclude = parsePatterns(labels[excludeLabel])
 
-	return job, nil
+	for _, selector := range splitList(labels[stopLabelsLabel], patternSeparator) {
+		key, value, _ := strings.Cut(selector, "=")
+		if key = strings.TrimSpace(key); key == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: missing label key", stopLabelsLabel, selector)
+		}
+		if job.StopLabels == nil {
+			job.StopLabels = make(map[string]string)
+		}
+		job.StopLabels[key] = strings.TrimSpace(value)
+	}
+
+	var err error
+	if job.QuiesceExec, err = parseCommand(quiesceExecLabel, labels[quiesceExecLabel]); err != nil {
+		return nil, err
+	}
+	if job.ReleaseExec, err = parseCommand(releaseExecLabel, labels[releaseExecLabel]); err != nil {
+		return nil, err
+	}
+
+	jobs := make([]VolumeJob, len(volumes))
+	for i, volume := range volumes {
+		jobs[i] = job
+		jobs[i].VolumeName = volume
+		jobs[i].SubPath = subPaths[i]
+		jobs[i].Schedule = schedules[0]
+		if len(schedules) > 1 {
+			jobs[i].Schedule = schedules[i]
+		}
+	}
+	return jobs, nil
 }
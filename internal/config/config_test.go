@@ -1,10 +1,14 @@
 package config
 
 import (
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -41,16 +45,12 @@ func TestLoadGlobal(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "SuccessWithInvalidTZ",
+			name: "InvalidTZ",
 			env: map[string]string{
 				"DESTINATION_PATH": "s3://my-bucket/path",
 				"TZ":               "Invalid/Timezone",
 			},
-			want: &GlobalConfig{
-				DestinationPath: "s3://my-bucket/path",
-				Location:        time.Local,
-			},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "MissingDestinationPath",
@@ -83,6 +83,80 @@ func TestLoadGlobal(t *testing.T) {
 	}
 }
 
+func TestLoadGlobal_CronTimezone(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "UnsetIsUTC", want: "UTC"},
+		{name: "TZ", env: map[string]string{"TZ": "Europe/Rome"}, want: "Europe/Rome"},
+		{name: "CronTimezone", env: map[string]string{"CRON_TIMEZONE": "America/New_York"}, want: "America/New_York"},
+		{name: "CronTimezoneWinsOverTZ", env: map[string]string{"CRON_TIMEZONE": "Asia/Tokyo", "TZ": "Europe/Rome"}, want: "Asia/Tokyo"},
+		{name: "InvalidCronTimezone", env: map[string]string{"CRON_TIMEZONE": "Mars/Olympus"}, wantErr: "CRON_TIMEZONE"},
+		{name: "InvalidTZ", env: map[string]string{"TZ": "Mars/Olympus"}, wantErr: "TZ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Location.String())
+		})
+	}
+}
+
+func TestLoadGlobal_IgnoreFile(t *testing.T) {
+	t.Run("ParsesPatterns", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".volumesyncignore")
+		content := "# build output\n" +
+			"build/\n" +
+			"\n" +
+			"*.log   \n" +
+			"!keep.log\n" +
+			"   \n" +
+			"\\#literal\n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		os.Clearenv()
+		t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+		t.Setenv("SYNC_IGNORE_FILE", path)
+
+		got, err := LoadGlobal()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"build/", "*.log", "!keep.log", `\#literal`}, got.IgnorePatterns)
+	})
+
+	t.Run("UnsetIsNil", func(t *testing.T) {
+		os.Clearenv()
+		t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+
+		got, err := LoadGlobal()
+		require.NoError(t, err)
+		assert.Nil(t, got.IgnorePatterns)
+	})
+
+	t.Run("MissingFileIsAnError", func(t *testing.T) {
+		os.Clearenv()
+		t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+		t.Setenv("SYNC_IGNORE_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		_, err := LoadGlobal()
+		assert.Error(t, err)
+	})
+}
+
 func TestParseLabels(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -102,6 +176,7 @@ func TestParseLabels(t *testing.T) {
 				"volumesync.subpath":           "custom/path",
 				"volumesync.uid":               "1000",
 				"volumesync.gid":               "1000",
+				"volumesync.stop_attached":     "true",
 			},
 			want: &VolumeJob{
 				VolumeName:      "my-vol",
@@ -113,6 +188,7 @@ func TestParseLabels(t *testing.T) {
 				SubPath:         "custom/path",
 				UID:             intPtr(1000),
 				GID:             intPtr(1000),
+				StopAttached:    true,
 			},
 			wantErr: false,
 		},
@@ -127,7 +203,6 @@ func TestParseLabels(t *testing.T) {
 				VolumeName:      "my-vol",
 				Schedule:        "@daily",
 				Delete:          false,
-				Concurrency:     16,
 				StopContainer:   true,
 				StopGracePeriod: 30 * time.Second,
 				SubPath:         "my-vol",
@@ -163,17 +238,315 @@ func TestParseLabels(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ParseLabels(tt.labels)
+			got, err := ParseLabels(tt.labels, ScheduleParser{})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			require.Len(t, got, 1)
+			assert.Equal(t, *tt.want, got[0])
+		})
+	}
+}
+
+func TestParseLabels_MultipleVolumes(t *testing.T) {
+	base := map[string]string{
+		"volumesync.enabled":  "true",
+		"volumesync.schedule": "@daily",
+		"volumesync.delete":   "true",
+	}
+
+	tests := []struct {
+		name         string
+		volume       string
+		subpath      string
+		wantVolumes  []string
+		wantSubPaths []string
+		wantErr      bool
+	}{
+		{
+			name:         "SubPathsDefaultToVolumeNames",
+			volume:       "db_data,app_data,media",
+			wantVolumes:  []string{"db_data", "app_data", "media"},
+			wantSubPaths: []string{"db_data", "app_data", "media"},
+		},
+		{
+			name:         "SubPathsMatchedByPosition",
+			volume:       "db_data, app_data",
+			subpath:      "backups/db, backups/app",
+			wantVolumes:  []string{"db_data", "app_data"},
+			wantSubPaths: []string{"backups/db", "backups/app"},
+		},
+		{
+			name:         "EmptyEntriesAreDropped",
+			volume:       "db_data,,app_data,",
+			wantVolumes:  []string{"db_data", "app_data"},
+			wantSubPaths: []string{"db_data", "app_data"},
+		},
+		{
+			name:    "TooFewSubPaths",
+			volume:  "db_data,app_data",
+			subpath: "backups/db",
+			wantErr: true,
+		},
+		{
+			name:    "TooManySubPaths",
+			volume:  "db_data",
+			subpath: "backups/db,backups/app",
+			wantErr: true,
+		},
+		{
+			name:    "OnlySeparators",
+			volume:  ",,",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{"volumesync.volume": tt.volume}
+			for k, v := range base {
+				labels[k] = v
+			}
+			if tt.subpath != "" {
+				labels["volumesync.subpath"] = tt.subpath
+			}
+
+			jobs, err := ParseLabels(labels, ScheduleParser{})
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
 			}
 			require.NoError(t, err)
+
+			var volumes, subPaths []string
+			for _, job := range jobs {
+				volumes = append(volumes, job.VolumeName)
+				subPaths = append(subPaths, job.SubPath)
+				// Everything else is shared across the container's volumes.
+				assert.Equal(t, "@daily", job.Schedule)
+				assert.True(t, job.Delete)
+			}
+			assert.Equal(t, tt.wantVolumes, volumes)
+			assert.Equal(t, tt.wantSubPaths, subPaths)
+		})
+	}
+}
+
+func TestParseLabels_Schedules(t *testing.T) {
+	tests := []struct {
+		name        string
+		volume      string
+		schedule    string
+		withSeconds bool
+		want        []string
+		wantErr     string
+	}{
+		{
+			name:     "SingleScheduleIsShared",
+			volume:   "hot,cold",
+			schedule: "*/15 * * * *",
+			want:     []string{"*/15 * * * *", "*/15 * * * *"},
+		},
+		{
+			name:     "OneSchedulePerVolume",
+			volume:   "hot,cold",
+			schedule: "*/15 * * * *; @daily",
+			want:     []string{"*/15 * * * *", "@daily"},
+		},
+		{
+			// Commas belong to cron, not to the list.
+			name:     "CronListsSurvive",
+			volume:   "hot",
+			schedule: "0 3,15 * * *",
+			want:     []string{"0 3,15 * * *"},
+		},
+		{
+			name:     "CountMismatch",
+			volume:   "hot,warm,cold",
+			schedule: "@hourly;@daily",
+			wantErr:  "lists 2 entries",
+		},
+		{
+			name:     "InvalidExpression",
+			volume:   "hot",
+			schedule: "every morning",
+			wantErr:  "has 2 fields but schedules take 5",
+		},
+		{
+			name:     "OneInvalidInList",
+			volume:   "hot,cold",
+			schedule: "@hourly;61 * * * *",
+			wantErr:  "61",
+		},
+		{
+			name:     "SecondsWithoutCronWithSeconds",
+			volume:   "hot",
+			schedule: "*/30 * * * * *",
+			wantErr:  "has 6 fields but schedules take 5 (minute hour day-of-month month day-of-week), or 6 with CRON_WITH_SECONDS=true",
+		},
+		{
+			name:        "Seconds",
+			volume:      "hot",
+			schedule:    "*/30 * * * * *",
+			withSeconds: true,
+			want:        []string{"*/30 * * * * *"},
+		},
+		{
+			name:        "DescriptorWithSeconds",
+			volume:      "hot",
+			schedule:    "@every 30s",
+			withSeconds: true,
+			want:        []string{"@every 30s"},
+		},
+		{
+			name:        "MinutesWithCronWithSeconds",
+			volume:      "hot",
+			schedule:    "*/15 * * * *",
+			withSeconds: true,
+			wantErr:     "has 5 fields but schedules take 6 (second minute hour day-of-month month day-of-week), or 5 without CRON_WITH_SECONDS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := ParseLabels(map[string]string{
+				"volumesync.enabled":  "true",
+				"volumesync.volume":   tt.volume,
+				"volumesync.schedule": tt.schedule,
+			}, ScheduleParser{WithSeconds: tt.withSeconds})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var got []string
+			for _, job := range jobs {
+				got = append(got, job.Schedule)
+			}
 			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
+func TestParseLabels_StopLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		label   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "AbsentIsNil"},
+		{
+			name:  "KeyAndValue",
+			label: "com.docker.compose.project=myapp",
+			want:  map[string]string{"com.docker.compose.project": "myapp"},
+		},
+		{
+			name:  "SeveralSelectors",
+			label: "com.docker.compose.project=myapp; tier = db",
+			want:  map[string]string{"com.docker.compose.project": "myapp", "tier": "db"},
+		},
+		{
+			name:  "KeyOnly",
+			label: "backup.stop",
+			want:  map[string]string{"backup.stop": ""},
+		},
+		{
+			name:    "MissingKey",
+			label:   "=myapp",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{
+				"volumesync.enabled":  "true",
+				"volumesync.volume":   "vol",
+				"volumesync.schedule": "@daily",
+			}
+			if tt.label != "" {
+				labels["volumesync.stop_labels"] = tt.label
+			}
+
+			jobs, err := ParseLabels(labels, ScheduleParser{})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, jobs, 1)
+			assert.Equal(t, tt.want, jobs[0].StopLabels)
+		})
+	}
+}
+
+func TestParseLabels_Exec(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		wantQuiesce []string
+		wantRelease []string
+		wantErr     string
+	}{
+		{name: "AbsentIsNil"},
+		{
+			name: "ExecForm",
+			labels: map[string]string{
+				"volumesync.quiesce_exec": `["redis-cli", "BGSAVE"]`,
+				"volumesync.release_exec": `["redis-cli", "PING"]`,
+			},
+			wantQuiesce: []string{"redis-cli", "BGSAVE"},
+			wantRelease: []string{"redis-cli", "PING"},
+		},
+		{
+			name:        "ShellForm",
+			labels:      map[string]string{"volumesync.quiesce_exec": "fsfreeze --freeze /data && sync"},
+			wantQuiesce: []string{"sh", "-c", "fsfreeze --freeze /data && sync"},
+		},
+		{
+			name:    "InvalidJSON",
+			labels:  map[string]string{"volumesync.quiesce_exec": `["redis-cli", BGSAVE]`},
+			wantErr: "invalid volumesync.quiesce_exec",
+		},
+		{
+			name:    "EmptyArray",
+			labels:  map[string]string{"volumesync.release_exec": `[]`},
+			wantErr: "invalid volumesync.release_exec \"[]\": command is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{
+				"volumesync.enabled":  "true",
+				"volumesync.volume":   "vol",
+				"volumesync.schedule": "@daily",
+			}
+			for k, v := range tt.labels {
+				labels[k] = v
+			}
+
+			jobs, err := ParseLabels(labels, ScheduleParser{})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, jobs, 1)
+			assert.Equal(t, tt.wantQuiesce, jobs[0].QuiesceExec)
+			assert.Equal(t, tt.wantRelease, jobs[0].ReleaseExec)
+		})
+	}
+}
+
 func boolPtr(b bool) *bool { return &b }
 
 func TestLoadGlobal_Compression(t *testing.T) {
@@ -203,62 +576,1818 @@ func TestLoadGlobal_Compression(t *testing.T) {
 	}
 }
 
-func TestParseLabels_Compression(t *testing.T) {
-	base := map[string]string{
-		"volumesync.enabled":  "true",
-		"volumesync.volume":   "db_data",
-		"volumesync.schedule": "@hourly",
+func TestLoadGlobal_SyncCompression(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		want      bool
+		wantCodec CompressionCodec
+		wantErr   bool
+	}{
+		{name: "UnsetIsOff", want: false, wantCodec: CompressionGzip},
+		{name: "CompressionMeansGzip", env: map[string]string{"COMPRESSION": "true"}, want: true, wantCodec: CompressionGzip},
+		{name: "Gzip", env: map[string]string{"SYNC_COMPRESSION": "gzip"}, want: true, wantCodec: CompressionGzip},
+		{name: "Zstd", env: map[string]string{"SYNC_COMPRESSION": "zstd"}, want: true, wantCodec: CompressionZstd},
+		{name: "NoneOverridesCompression", env: map[string]string{"COMPRESSION": "true", "SYNC_COMPRESSION": "none"}, want: false, wantCodec: CompressionGzip},
+		{name: "Invalid", env: map[string]string{"SYNC_COMPRESSION": "brotli"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, `invalid SYNC_COMPRESSION "brotli"`)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Compression)
+			assert.Equal(t, tt.wantCodec, got.CompressionCodec)
+		})
 	}
+}
 
+func TestLoadGlobal_PreservePermissions(t *testing.T) {
 	tests := []struct {
-		name  string
-		label *string
-		want  *bool
+		name string
+		env  string
+		want bool
 	}{
-		{name: "AbsentInheritsGlobal", label: nil, want: nil},
-		{name: "True", label: strPtr("true"), want: boolPtr(true)},
-		{name: "False", label: strPtr("false"), want: boolPtr(false)},
+		{name: "UnsetIsOn", env: "", want: true},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			labels := map[string]string{}
-			for k, v := range base {
-				labels[k] = v
-			}
-			if tt.label != nil {
-				labels["volumesync.compression"] = *tt.label
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_PRESERVE_PERMISSIONS", tt.env)
 			}
 
-			job, err := ParseLabels(labels)
+			got, err := LoadGlobal()
 			require.NoError(t, err)
-			assert.Equal(t, tt.want, job.Compression)
+			assert.Equal(t, tt.want, got.PreservePermissions)
 		})
 	}
 }
 
-func TestResolveCompression(t *testing.T) {
+func TestLoadGlobal_PreserveMtime(t *testing.T) {
 	tests := []struct {
-		name   string
-		global bool
-		job    *bool
-		want   bool
+		name string
+		env  string
+		want bool
 	}{
-		{name: "NoLabelInheritsGlobalOff", global: false, job: nil, want: false},
-		{name: "NoLabelInheritsGlobalOn", global: true, job: nil, want: true},
-		{name: "LabelEnablesOverGlobalOff", global: false, job: boolPtr(true), want: true},
-		{name: "LabelDisablesOverGlobalOn", global: true, job: boolPtr(false), want: false},
+		{name: "UnsetIsOn", env: "", want: true},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			g := &GlobalConfig{Compression: tt.global}
-			got := g.ResolveCompression(VolumeJob{Compression: tt.job})
-			assert.Equal(t, tt.want, got)
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_PRESERVE_MTIME", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.PreserveMtime)
 		})
 	}
 }
 
+func TestLoadGlobal_PreserveSymlinks(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_PRESERVE_SYMLINKS", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.PreserveSymlinks)
+		})
+	}
+}
+
+func TestLoadGlobal_FollowSymlinks(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		preserve string
+		want     bool
+		wantErr  string
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+		{name: "PreservedToo", env: "true", preserve: "true", wantErr: "can't both be set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_FOLLOW_SYMLINKS", tt.env)
+			}
+			if tt.preserve != "" {
+				t.Setenv("SYNC_PRESERVE_SYMLINKS", tt.preserve)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.FollowSymlinks)
+		})
+	}
+}
+
+func TestLoadGlobal_PreserveEmptyDirs(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_PRESERVE_EMPTY_DIRS", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.PreserveEmptyDirs)
+		})
+	}
+}
+
+func TestLoadGlobal_DeleteFirst(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_DELETE_FIRST", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.DeleteFirst)
+		})
+	}
+}
+
+func TestLoadGlobal_Sentinel(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantFile    string
+		wantDir     string
+		wantDisable bool
+		wantErr     string
+	}{
+		{name: "Defaults", wantFile: ".volumesync_done"},
+		{name: "CustomFile", env: map[string]string{"SENTINEL_FILE": ".restored-by-a"}, wantFile: ".restored-by-a"},
+		{name: "Dir", env: map[string]string{"SENTINEL_DIR": "/state"}, wantFile: ".volumesync_done", wantDir: "/state"},
+		{name: "Disabled", env: map[string]string{"SENTINEL_DISABLE": "true"}, wantFile: ".volumesync_done", wantDisable: true},
+		{name: "FileWithDirectory", env: map[string]string{"SENTINEL_FILE": "sub/.done"}, wantErr: "SENTINEL_FILE"},
+		{name: "FileDotDot", env: map[string]string{"SENTINEL_FILE": ".."}, wantErr: "SENTINEL_FILE"},
+		{name: "FileStar", env: map[string]string{"SENTINEL_FILE": ".done*"}, wantErr: "SENTINEL_FILE"},
+		{name: "FileQuestionMark", env: map[string]string{"SENTINEL_FILE": ".done?"}, wantErr: "SENTINEL_FILE"},
+		{name: "FileBrackets", env: map[string]string{"SENTINEL_FILE": ".done[12]"}, wantErr: "SENTINEL_FILE"},
+		{name: "FileBraces", env: map[string]string{"SENTINEL_FILE": ".done{a,b}"}, wantErr: "SENTINEL_FILE"},
+		{name: "FileBackslash", env: map[string]string{"SENTINEL_FILE": `.done\x`}, wantErr: "SENTINEL_FILE"},
+		{name: "RelativeDir", env: map[string]string{"SENTINEL_DIR": "state"}, wantErr: "SENTINEL_DIR"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFile, got.SentinelFile)
+			assert.Equal(t, tt.wantDir, got.SentinelDir)
+			assert.Equal(t, tt.wantDisable, got.SentinelDisable)
+		})
+	}
+}
+
+func TestLoadGlobal_Dedup(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_DEDUP", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Dedup)
+		})
+	}
+}
+
+func TestLoadGlobal_NonEmptySource(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_REQUIRE_NONEMPTY_SOURCE", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.NonEmptySource)
+		})
+	}
+}
+
+func TestLoadGlobal_Manifest(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		wantDir       string
+		wantFullEvery int
+		wantErr       string
+	}{
+		{name: "Unset", wantFullEvery: DefaultFullSyncEvery},
+		{name: "Set", env: map[string]string{"SYNC_MANIFEST_PATH": "/manifests", "SYNC_FULL_EVERY": "7"}, wantDir: "/manifests", wantFullEvery: 7},
+		{name: "ZeroFullEvery", env: map[string]string{"SYNC_FULL_EVERY": "0"}, wantErr: "invalid SYNC_FULL_EVERY"},
+		{name: "InvalidFullEvery", env: map[string]string{"SYNC_FULL_EVERY": "weekly"}, wantErr: "invalid SYNC_FULL_EVERY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantDir, got.ManifestDir)
+			assert.Equal(t, tt.wantFullEvery, got.FullSyncEvery)
+		})
+	}
+}
+
+func TestLoadGlobal_FailOnSyncError(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("FAIL_ON_SYNC_ERROR", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.FailOnSyncError)
+		})
+	}
+}
+
+func TestLoadGlobal_Circuit(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		wantThreshold int
+		wantCooldown  time.Duration
+		wantErr       string
+	}{
+		{name: "UnsetIsOff", wantCooldown: DefaultCircuitCooldown},
+		{name: "Set", env: map[string]string{"SYNC_CIRCUIT_THRESHOLD": "3", "SYNC_CIRCUIT_COOLDOWN": "30m"}, wantThreshold: 3, wantCooldown: 30 * time.Minute},
+		{name: "InvalidThreshold", env: map[string]string{"SYNC_CIRCUIT_THRESHOLD": "-1"}, wantErr: "invalid SYNC_CIRCUIT_THRESHOLD"},
+		{name: "InvalidCooldown", env: map[string]string{"SYNC_CIRCUIT_COOLDOWN": "soon"}, wantErr: "invalid SYNC_CIRCUIT_COOLDOWN"},
+		{name: "ZeroCooldown", env: map[string]string{"SYNC_CIRCUIT_COOLDOWN": "0s"}, wantErr: "invalid SYNC_CIRCUIT_COOLDOWN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantThreshold, got.CircuitThreshold)
+			assert.Equal(t, tt.wantCooldown, got.CircuitCooldown)
+		})
+	}
+}
+
+func TestLoadGlobal_StopExclusions(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+	t.Setenv("STOP_EXCLUDE_LABELS", "role=exporter, readonly")
+	t.Setenv("STOP_EXCLUDE_NAMES", "metrics,")
+
+	got, err := LoadGlobal()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"role=exporter", "readonly"}, got.StopExcludeLabels)
+	assert.Equal(t, []string{"metrics"}, got.StopExcludeNames)
+}
+
+func TestLoadGlobal_StopOnlyWriters(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOn", env: "", want: true},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("STOP_ONLY_WRITERS", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.StopOnlyWriters)
+		})
+	}
+}
+
+func TestLoadGlobal_StopMatchBindMounts(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+
+	got, err := LoadGlobal()
+	require.NoError(t, err)
+	assert.False(t, got.StopMatchBindMounts)
+
+	t.Setenv("STOP_MATCH_BIND_MOUNTS", "true")
+	got, err = LoadGlobal()
+	require.NoError(t, err)
+	assert.True(t, got.StopMatchBindMounts)
+}
+
+func TestLoadGlobal_StopOrder(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+
+	got, err := LoadGlobal()
+	require.NoError(t, err)
+	assert.Empty(t, got.StopOrder)
+	assert.False(t, got.StopDependsOn)
+
+	t.Setenv("STOP_ORDER", "web, worker,,db")
+	t.Setenv("STOP_DEPENDS_ON", "true")
+	got, err = LoadGlobal()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web", "worker", "db"}, got.StopOrder)
+	assert.True(t, got.StopDependsOn)
+}
+
+func TestLoadGlobal_Docker(t *testing.T) {
+	tests := []struct {
+		name           string
+		host           string
+		apiVersion     string
+		wantErr        string
+		wantHost       string
+		wantAPIVersion string
+	}{
+		{name: "Unset"},
+		{name: "Socket", host: "unix:///run/docker.sock", wantHost: "unix:///run/docker.sock"},
+		{name: "TCPAndVersion", host: "tcp://docker:2375", apiVersion: "1.43", wantHost: "tcp://docker:2375", wantAPIVersion: "1.43"},
+		{name: "PrefixedVersion", apiVersion: "v1.41", wantAPIVersion: "v1.41"},
+		{name: "HostWithoutScheme", host: "/var/run/docker.sock", wantErr: "invalid DOCKER_HOST"},
+		{name: "BadVersion", apiVersion: "latest", wantErr: "invalid DOCKER_API_VERSION"},
+		{name: "VersionWithoutMinor", apiVersion: "1", wantErr: "invalid DOCKER_API_VERSION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.host != "" {
+				t.Setenv("DOCKER_HOST", tt.host)
+			}
+			if tt.apiVersion != "" {
+				t.Setenv("DOCKER_API_VERSION", tt.apiVersion)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantHost, got.DockerHost)
+			assert.Equal(t, tt.wantAPIVersion, got.DockerAPIVersion)
+		})
+	}
+}
+
+func TestLoadGlobal_StopConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    int
+		wantErr bool
+	}{
+		{name: "Default", want: DefaultStopConcurrency},
+		{name: "Set", env: "8", want: 8},
+		{name: "Serial", env: "1", want: 1},
+		{name: "Zero", env: "0", wantErr: true},
+		{name: "NotANumber", env: "many", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("CONTAINER_OP_CONCURRENCY", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "invalid CONTAINER_OP_CONCURRENCY")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.StopConcurrency)
+		})
+	}
+}
+
+func TestLoadGlobal_SkipIfUnchanged(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+
+	got, err := LoadGlobal()
+	require.NoError(t, err)
+	assert.False(t, got.SkipIfUnchanged)
+
+	t.Setenv("SYNC_SKIP_IF_UNCHANGED", "true")
+	got, err = LoadGlobal()
+	require.NoError(t, err)
+	assert.True(t, got.SkipIfUnchanged)
+}
+
+func TestLoadGlobal_AgeLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		minAge  string
+		maxAge  string
+		wantMin time.Duration
+		wantMax time.Duration
+		wantErr string
+	}{
+		{name: "Unset"},
+		{name: "MaxAgeInDays", maxAge: "7d", wantMax: 7 * 24 * time.Hour},
+		{name: "MinAge", minAge: "90m", wantMin: 90 * time.Minute},
+		{name: "Both", minAge: "1h", maxAge: "30d", wantMin: time.Hour, wantMax: 30 * 24 * time.Hour},
+		{name: "Inverted", minAge: "7d", maxAge: "1d", wantErr: "must be less than SYNC_MAX_AGE"},
+		{name: "BadMinAge", minAge: "soon", wantErr: "invalid SYNC_MIN_AGE"},
+		{name: "NegativeMaxAge", maxAge: "-1h", wantErr: "invalid SYNC_MAX_AGE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.minAge != "" {
+				t.Setenv("SYNC_MIN_AGE", tt.minAge)
+			}
+			if tt.maxAge != "" {
+				t.Setenv("SYNC_MAX_AGE", tt.maxAge)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMin, got.MinAge)
+			assert.Equal(t, tt.wantMax, got.MaxAge)
+		})
+	}
+}
+
+func TestLoadGlobal_SizeLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		minSize string
+		maxSize string
+		wantMin fs.SizeSuffix
+		wantMax fs.SizeSuffix
+		wantErr string
+	}{
+		{name: "Unset"},
+		{name: "MaxSize", maxSize: "1G", wantMax: fs.Gibi},
+		{name: "MinSizeInBytes", minSize: "512B", wantMin: 512},
+		{name: "Both", minSize: "1k", maxSize: "10M", wantMin: fs.Kibi, wantMax: 10 * fs.Mebi},
+		{name: "Inverted", minSize: "10M", maxSize: "1M", wantErr: "must not be more than SYNC_MAX_SIZE"},
+		{name: "BadMinSize", minSize: "big", wantErr: "invalid SYNC_MIN_SIZE"},
+		{name: "ZeroMaxSize", maxSize: "0", wantErr: "invalid SYNC_MAX_SIZE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.minSize != "" {
+				t.Setenv("SYNC_MIN_SIZE", tt.minSize)
+			}
+			if tt.maxSize != "" {
+				t.Setenv("SYNC_MAX_SIZE", tt.maxSize)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMin, got.MinSize)
+			assert.Equal(t, tt.wantMax, got.MaxSize)
+		})
+	}
+}
+
+func TestLoadGlobal_SkipErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_SKIP_ERRORS", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.SkipErrors)
+		})
+	}
+}
+
+func TestLoadGlobal_CronWithSeconds(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("CRON_WITH_SECONDS", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.CronWithSeconds)
+		})
+	}
+}
+
+func TestLoadGlobal_MaxDeleteRatio(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    float64
+		wantErr bool
+	}{
+		{name: "Default", env: "", want: 0.5},
+		{name: "Set", env: "0.25", want: 0.25},
+		{name: "Off", env: "1", want: 1},
+		{name: "NoDeletes", env: "0", want: 0},
+		{name: "AboveOne", env: "1.5", wantErr: true},
+		{name: "Negative", env: "-0.1", wantErr: true},
+		{name: "Percentage", env: "50%", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_MAX_DELETE_RATIO", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_MAX_DELETE_RATIO")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.MaxDeleteRatio)
+		})
+	}
+}
+
+func TestLoadGlobal_MaxRequestsPerSec(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    float64
+		wantErr bool
+	}{
+		{name: "UnsetIsUnlimited", env: "", want: 0},
+		{name: "Whole", env: "100", want: 100},
+		{name: "Fraction", env: "0.5", want: 0.5},
+		{name: "Zero", env: "0", wantErr: true},
+		{name: "Negative", env: "-10", wantErr: true},
+		{name: "Infinite", env: "Inf", wantErr: true},
+		{name: "NotANumber", env: "fast", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_MAX_REQUESTS_PER_SEC", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_MAX_REQUESTS_PER_SEC")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.MaxRequestsPerSec)
+		})
+	}
+}
+
+func TestLoadGlobal_AWSCredentialsFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "aws")
+	require.NoError(t, os.WriteFile(secret, []byte("[default]\n"), 0600))
+	other := filepath.Join(t.TempDir(), "other")
+	require.NoError(t, os.WriteFile(other, []byte("[default]\n"), 0600))
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{name: "UnsetLeavesTheSDKDefault"},
+		{name: "CredentialsFile", env: map[string]string{"AWS_CREDENTIALS_FILE": secret}, want: secret},
+		{name: "SharedCredentialsFile", env: map[string]string{"AWS_SHARED_CREDENTIALS_FILE": secret}, want: secret},
+		{name: "CredentialsFileWins", env: map[string]string{"AWS_CREDENTIALS_FILE": secret, "AWS_SHARED_CREDENTIALS_FILE": other}, want: secret},
+		{name: "Missing", env: map[string]string{"AWS_CREDENTIALS_FILE": filepath.Join(t.TempDir(), "missing")}, wantErr: "invalid AWS credentials file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.AWSCredentialsFile)
+		})
+	}
+}
+
+func TestLoadGlobal_HTTP(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantTimeout time.Duration
+		wantBundle  string
+		wantErr     string
+	}{
+		{name: "Defaults"},
+		{name: "Timeout", env: map[string]string{"S3_HTTP_TIMEOUT": "45s"}, wantTimeout: 45 * time.Second},
+		{name: "CABundle", env: map[string]string{"S3_CA_BUNDLE": "/certs/ca.pem"}, wantBundle: "/certs/ca.pem"},
+		{name: "ZeroTimeout", env: map[string]string{"S3_HTTP_TIMEOUT": "0s"}, wantErr: `invalid S3_HTTP_TIMEOUT "0s"`},
+		{name: "TimeoutWithoutUnit", env: map[string]string{"S3_HTTP_TIMEOUT": "30"}, wantErr: `invalid S3_HTTP_TIMEOUT "30"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantTimeout, got.S3HTTPTimeout)
+			assert.Equal(t, tt.wantBundle, got.S3CABundle)
+		})
+	}
+}
+
+func TestLoadGlobal_ConcurrentRuns(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want bool
+	}{
+		{name: "UnsetIsOff", env: "", want: false},
+		{name: "True", env: "true", want: true},
+		{name: "False", env: "false", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_CONCURRENT_RUNS", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ConcurrentRuns)
+		})
+	}
+}
+
+func TestLoadGlobal_ShutdownTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "UnsetDefaults", env: "", want: 30 * time.Second},
+		{name: "Custom", env: "2m", want: 2 * time.Minute},
+		{name: "Invalid", env: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SHUTDOWN_TIMEOUT", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ShutdownTimeout)
+		})
+	}
+}
+
+func TestLoadGlobal_SyncTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "UnsetIsNoLimit", env: "", want: 0},
+		{name: "Custom", env: "2h", want: 2 * time.Hour},
+		{name: "Negative", env: "-1h", wantErr: true},
+		{name: "Invalid", env: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_TIMEOUT", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_TIMEOUT")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.SyncTimeout)
+		})
+	}
+}
+
+func TestLoadGlobal_SyncJitter(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "UnsetIsNone", env: "", want: 0},
+		{name: "Custom", env: "5m", want: 5 * time.Minute},
+		{name: "Negative", env: "-5m", wantErr: true},
+		{name: "Invalid", env: "some", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_JITTER", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_JITTER")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.SyncJitter)
+		})
+	}
+}
+
+func TestLoadGlobal_ProgressInterval(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "UnsetIsOff", env: "", want: 0},
+		{name: "Custom", env: "30s", want: 30 * time.Second},
+		{name: "Negative", env: "-30s", wantErr: true},
+		{name: "Invalid", env: "often", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_PROGRESS_INTERVAL", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_PROGRESS_INTERVAL")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ProgressInterval)
+		})
+	}
+}
+
+func TestLoadGlobal_MtimeTolerance(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "UnsetIsDefault", env: "", want: 0},
+		{name: "Custom", env: "1s", want: time.Second},
+		{name: "Negative", env: "-1s", wantErr: true},
+		{name: "Invalid", env: "a bit", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_MTIME_TOLERANCE", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_MTIME_TOLERANCE")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.MtimeTolerance)
+		})
+	}
+}
+
+func TestLoadGlobal_Verify(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    VerifyMode
+		wantErr bool
+	}{
+		{name: "UnsetIsOff", env: "", want: VerifyOff},
+		{name: "Size", env: "size", want: VerifySize},
+		{name: "Checksum", env: "checksum", want: VerifyChecksum},
+		{name: "Invalid", env: "true", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_VERIFY", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "SYNC_VERIFY")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Verify)
+		})
+	}
+}
+
+func TestLoadGlobal_ContentType(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    ContentTypeDetection
+		wantErr bool
+	}{
+		{name: "UnsetIsExtension", env: "", want: ContentTypeExtension},
+		{name: "Extension", env: "extension", want: ContentTypeExtension},
+		{name: "Sniff", env: "sniff", want: ContentTypeSniff},
+		{name: "Off", env: "off", want: ContentTypeOff},
+		{name: "Invalid", env: "true", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_CONTENT_TYPE_DETECTION", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, `invalid SYNC_CONTENT_TYPE_DETECTION "true"`)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ContentType)
+		})
+	}
+}
+
+func TestLoadGlobal_Hooks(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantPre     string
+		wantPost    string
+		wantTimeout time.Duration
+		wantAbort   bool
+		wantErr     string
+	}{
+		{name: "Defaults", wantTimeout: 5 * time.Minute, wantAbort: true},
+		{
+			name: "Set",
+			env: map[string]string{
+				"PRE_SYNC_HOOK":       "pg_dump -f /volumes/db/dump.sql",
+				"POST_SYNC_HOOK":      "curl -X POST https://cdn.example.com/purge",
+				"HOOK_TIMEOUT":        "30s",
+				"PRE_SYNC_HOOK_ABORT": "false",
+			},
+			wantPre:     "pg_dump -f /volumes/db/dump.sql",
+			wantPost:    "curl -X POST https://cdn.example.com/purge",
+			wantTimeout: 30 * time.Second,
+			wantAbort:   false,
+		},
+		{name: "ZeroTimeout", env: map[string]string{"HOOK_TIMEOUT": "0s"}, wantErr: "HOOK_TIMEOUT"},
+		{name: "InvalidTimeout", env: map[string]string{"HOOK_TIMEOUT": "a while"}, wantErr: "HOOK_TIMEOUT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPre, got.PreSyncHook)
+			assert.Equal(t, tt.wantPost, got.PostSyncHook)
+			assert.Equal(t, tt.wantTimeout, got.HookTimeout)
+			assert.Equal(t, tt.wantAbort, got.PreSyncHookAbort)
+		})
+	}
+}
+
+func TestLoadGlobal_QuiesceMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    QuiesceMode
+		wantErr bool
+	}{
+		{name: "DefaultIsStop", env: "", want: QuiesceStop},
+		{name: "Stop", env: "stop", want: QuiesceStop},
+		{name: "Pause", env: "pause", want: QuiescePause},
+		{name: "Invalid", env: "freeze", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("CONTAINER_QUIESCE_MODE", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "CONTAINER_QUIESCE_MODE")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.QuiesceMode)
+		})
+	}
+}
+
+func TestLoadGlobal_StopFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    StopFailurePolicy
+		wantErr bool
+	}{
+		{name: "UnsetIsAbort", env: "", want: StopFailureAbort},
+		{name: "Abort", env: "abort", want: StopFailureAbort},
+		{name: "Skip", env: "skip", want: StopFailureSkip},
+		{name: "ProceedAndRestart", env: "proceed-and-restart", want: StopFailureProceedAndRestart},
+		{name: "Invalid", env: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("STOP_FAILURE_POLICY", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.StopFailurePolicy)
+		})
+	}
+}
+
+func TestLoadGlobal_Logging(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantFormat string
+		wantLevel  slog.Level
+		wantErr    bool
+	}{
+		{name: "Defaults", wantFormat: LogFormatText, wantLevel: slog.LevelInfo},
+		{name: "JSON", env: map[string]string{"LOG_FORMAT": "json"}, wantFormat: LogFormatJSON, wantLevel: slog.LevelInfo},
+		{name: "Debug", env: map[string]string{"LOG_LEVEL": "debug"}, wantFormat: LogFormatText, wantLevel: slog.LevelDebug},
+		{name: "WarnUpperCase", env: map[string]string{"LOG_LEVEL": "WARN"}, wantFormat: LogFormatText, wantLevel: slog.LevelWarn},
+		{name: "InvalidFormat", env: map[string]string{"LOG_FORMAT": "logfmt"}, wantErr: true},
+		{name: "InvalidLevel", env: map[string]string{"LOG_LEVEL": "verbose"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantFormat, got.LogFormat)
+			assert.Equal(t, tt.wantLevel, got.LogLevel)
+		})
+	}
+}
+
+func TestLoadGlobal_Notify(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    NotifyOn
+		wantErr bool
+	}{
+		{name: "UnsetIsFailure", env: "", want: NotifyOnFailure},
+		{name: "Success", env: "success", want: NotifyOnSuccess},
+		{name: "Always", env: "always", want: NotifyOnAlways},
+		{name: "Invalid", env: "never", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			t.Setenv("NOTIFY_WEBHOOK_URL", "https://hooks.example.com/backup")
+			if tt.env != "" {
+				t.Setenv("NOTIFY_ON", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "https://hooks.example.com/backup", got.NotifyWebhookURL)
+			assert.Equal(t, tt.want, got.NotifyOn)
+		})
+	}
+}
+
+func TestLoadGlobal_RunMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    RunMode
+		wantErr bool
+	}{
+		{name: "UnsetIsScheduled", env: "", want: RunModeScheduled},
+		{name: "Scheduled", env: "scheduled", want: RunModeScheduled},
+		{name: "Once", env: "once", want: RunModeOnce},
+		{name: "Invalid", env: "daemon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("RUN_MODE", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.RunMode)
+		})
+	}
+}
+
+func TestLoadGlobal_BackupMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		want     BackupMode
+		wantGzip bool
+		wantErr  bool
+	}{
+		{name: "UnsetIsSync", want: BackupModeSync, wantGzip: true},
+		{name: "Archive", env: map[string]string{"BACKUP_MODE": "archive"}, want: BackupModeArchive, wantGzip: true},
+		{name: "ArchiveWithoutGzip", env: map[string]string{"BACKUP_MODE": "archive", "ARCHIVE_GZIP": "false"}, want: BackupModeArchive, wantGzip: false},
+		{name: "Invalid", env: map[string]string{"BACKUP_MODE": "snapshot"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, `invalid BACKUP_MODE "snapshot"`)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.BackupMode)
+			assert.Equal(t, tt.wantGzip, got.ArchiveGzip)
+		})
+	}
+}
+
+func TestLoadGlobal_Snapshots(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantSnapshot bool
+		wantRestore  string
+		wantErr      string
+	}{
+		{name: "UnsetMirrors"},
+		{name: "SnapshotMode", env: map[string]string{"SNAPSHOT_MODE": "true"}, wantSnapshot: true},
+		{name: "RestoreSnapshot", env: map[string]string{"SNAPSHOT_MODE": "true", "RESTORE_SNAPSHOT": "2024-01-02T03:04:05"}, wantSnapshot: true, wantRestore: "2024-01-02T03:04:05"},
+		{name: "InvalidRestoreSnapshot", env: map[string]string{"RESTORE_SNAPSHOT": "yesterday"}, wantErr: `invalid RESTORE_SNAPSHOT "yesterday"`},
+		{name: "WithArchives", env: map[string]string{"SNAPSHOT_MODE": "true", "BACKUP_MODE": "archive"}, wantErr: "SNAPSHOT_MODE can't be used with BACKUP_MODE=archive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSnapshot, got.SnapshotMode)
+			assert.Equal(t, tt.wantRestore, got.RestoreSnapshot)
+		})
+	}
+}
+
+func TestLoadGlobal_Versions(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantAt   time.Time
+		wantSkip bool
+		wantErr  string
+	}{
+		{name: "UnsetRestoresLatest"},
+		{name: "RestoreAtTime", env: map[string]string{"RESTORE_AT_TIME": "2024-01-02T03:04:05Z"}, wantAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{name: "RestoreAtDate", env: map[string]string{"RESTORE_AT_TIME": "2024-01-02"}, wantAt: time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		{name: "SkipRemoteDeletes", env: map[string]string{"SYNC_SKIP_REMOTE_DELETES": "true"}, wantSkip: true},
+		{name: "InvalidRestoreAtTime", env: map[string]string{"RESTORE_AT_TIME": "last tuesday"}, wantErr: `invalid RESTORE_AT_TIME "last tuesday"`},
+		{name: "WithArchives", env: map[string]string{"RESTORE_AT_TIME": "2024-01-02", "BACKUP_MODE": "archive"}, wantErr: "RESTORE_AT_TIME can't be used with BACKUP_MODE=archive"},
+		{name: "WithSnapshots", env: map[string]string{"RESTORE_AT_TIME": "2024-01-02", "SNAPSHOT_MODE": "true"}, wantErr: "RESTORE_AT_TIME can't be used"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.wantAt.Equal(got.RestoreAtTime), "got %s", got.RestoreAtTime)
+			assert.Equal(t, tt.wantSkip, got.SkipRemoteDeletes)
+		})
+	}
+}
+
+func TestLoadGlobal_Retention(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantCount int
+		wantAge   time.Duration
+		wantErr   string
+	}{
+		{name: "UnsetKeepsAll"},
+		{name: "Count", env: map[string]string{"BACKUP_RETENTION_COUNT": "7"}, wantCount: 7},
+		{name: "AgeInDays", env: map[string]string{"BACKUP_RETENTION_AGE": "30d"}, wantAge: 30 * 24 * time.Hour},
+		{name: "AgeInHours", env: map[string]string{"BACKUP_RETENTION_AGE": "36h"}, wantAge: 36 * time.Hour},
+		{name: "Both", env: map[string]string{"BACKUP_RETENTION_COUNT": "3", "BACKUP_RETENTION_AGE": "1w"}, wantCount: 3, wantAge: 7 * 24 * time.Hour},
+		{name: "ZeroCount", env: map[string]string{"BACKUP_RETENTION_COUNT": "0"}, wantErr: `invalid BACKUP_RETENTION_COUNT "0"`},
+		{name: "InvalidAge", env: map[string]string{"BACKUP_RETENTION_AGE": "forever"}, wantErr: `invalid BACKUP_RETENTION_AGE "forever"`},
+		{name: "ZeroAge", env: map[string]string{"BACKUP_RETENTION_AGE": "0s"}, wantErr: `invalid BACKUP_RETENTION_AGE "0s"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCount, got.RetentionCount)
+			assert.Equal(t, tt.wantAge, got.RetentionAge)
+		})
+	}
+}
+
+func TestLoadGlobal_SyncDirection(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		want    SyncDirection
+		wantErr bool
+	}{
+		{name: "UnsetIsBackup", env: "", want: SyncBackup},
+		{name: "Backup", env: "backup", want: SyncBackup},
+		{name: "Restore", env: "restore", want: SyncRestore},
+		{name: "Invalid", env: "both", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			if tt.env != "" {
+				t.Setenv("SYNC_DIRECTION", tt.env)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.SyncDirection)
+		})
+	}
+}
+
+func TestLoadGlobal_DestinationPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		dest    string
+		wantErr bool
+	}{
+		{name: "Remote", dest: "s3:my-bucket/backups"},
+		{name: "RemoteRoot", dest: "s3:"},
+		{name: "OnTheFlyRemote", dest: ":s3,provider=AWS:my-bucket"},
+		{name: "LocalPath", dest: "/mnt/backups"},
+		{name: "EmptyRemoteName", dest: "::my-bucket", wantErr: true},
+		{name: "InvalidRemoteName", dest: "my bucket!:backups", wantErr: true},
+		{name: "MissingColon", dest: ":", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", tt.dest)
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "DESTINATION_PATH")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.dest, got.DestinationPath)
+		})
+	}
+}
+
+func TestParseLabels_Compression(t *testing.T) {
+	base := map[string]string{
+		"volumesync.enabled":  "true",
+		"volumesync.volume":   "db_data",
+		"volumesync.schedule": "@hourly",
+	}
+
+	tests := []struct {
+		name  string
+		label *string
+		want  *bool
+	}{
+		{name: "AbsentInheritsGlobal", label: nil, want: nil},
+		{name: "True", label: strPtr("true"), want: boolPtr(true)},
+		{name: "False", label: strPtr("false"), want: boolPtr(false)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			labels := map[string]string{}
+			for k, v := range base {
+				labels[k] = v
+			}
+			if tt.label != nil {
+				labels["volumesync.compression"] = *tt.label
+			}
+
+			jobs, err := ParseLabels(labels, ScheduleParser{})
+			require.NoError(t, err)
+			require.Len(t, jobs, 1)
+			assert.Equal(t, tt.want, jobs[0].Compression)
+		})
+	}
+}
+
+func TestAWSConfig(t *testing.T) {
+	os.Clearenv()
+	t.Setenv("DESTINATION_PATH", "s3:my-bucket/path")
+	t.Setenv("S3_REGION", "eu-west-1")
+	t.Setenv("AWS_PROFILE", "default-account")
+
+	g, err := LoadGlobal()
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", g.S3Region)
+	assert.Equal(t, "default-account", g.AWSProfile)
+
+	jobs, err := ParseLabels(map[string]string{
+		"volumesync.enabled":     "true",
+		"volumesync.volume":      "db_data",
+		"volumesync.schedule":    "@hourly",
+		"volumesync.s3_region":   "ap-southeast-2",
+		"volumesync.aws_profile": "other-account",
+	}, ScheduleParser{})
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "ap-southeast-2", g.ResolveS3Region(jobs[0]))
+	assert.Equal(t, "other-account", g.ResolveAWSProfile(jobs[0]))
+
+	// Without labels the global settings apply.
+	assert.Equal(t, "eu-west-1", g.ResolveS3Region(VolumeJob{}))
+	assert.Equal(t, "default-account", g.ResolveAWSProfile(VolumeJob{}))
+}
+
+func TestLoadGlobal_AssumeRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		roleARN    string
+		externalID string
+		wantErr    string
+	}{
+		{name: "Unset"},
+		{name: "Role", roleARN: "arn:aws:iam::123456789012:role/backup"},
+		{name: "RoleWithExternalID", roleARN: "arn:aws:iam::123456789012:role/backup", externalID: "partner-42"},
+		{name: "NotARoleARN", roleARN: "arn:aws:iam::123456789012:user/backup", wantErr: "S3_ASSUME_ROLE_ARN"},
+		{name: "NotAnARN", roleARN: "backup", wantErr: "S3_ASSUME_ROLE_ARN"},
+		{name: "ExternalIDWithoutRole", externalID: "partner-42", wantErr: "S3_EXTERNAL_ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3:my-bucket/path")
+			if tt.roleARN != "" {
+				t.Setenv("S3_ASSUME_ROLE_ARN", tt.roleARN)
+			}
+			if tt.externalID != "" {
+				t.Setenv("S3_EXTERNAL_ID", tt.externalID)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.roleARN, got.AssumeRoleARN)
+			assert.Equal(t, tt.externalID, got.ExternalID)
+		})
+	}
+}
+
+func TestLoadGlobal_S3ACL(t *testing.T) {
+	tests := []struct {
+		name    string
+		acl     string
+		wantErr bool
+	}{
+		{name: "Unset"},
+		{name: "Private", acl: "private"},
+		{name: "BucketOwnerFullControl", acl: "bucket-owner-full-control"},
+		{name: "Unknown", acl: "everyone", wantErr: true},
+		{name: "WrongCase", acl: "Private", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3:my-bucket/path")
+			if tt.acl != "" {
+				t.Setenv("S3_ACL", tt.acl)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "S3_ACL")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.acl, got.S3ACL)
+		})
+	}
+}
+
+func TestLoadGlobal_S3ObjectTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "Unset"},
+		{name: "Tags", tags: "app=myapp,env=prod", want: map[string]string{"app": "myapp", "env": "prod"}},
+		{name: "Spaces", tags: " app = my app , env=prod,", want: map[string]string{"app": "my app", "env": "prod"}},
+		{name: "EmptyValue", tags: "keep", want: map[string]string{"keep": ""}},
+		{name: "ValueWithEquals", tags: "query=a=b", want: map[string]string{"query": "a=b"}},
+		{name: "NoKey", tags: "=prod", wantErr: "has no key"},
+		{name: "Repeated", tags: "env=prod,env=dev", wantErr: "repeated"},
+		{name: "Reserved", tags: "aws:createdBy=me", wantErr: "reserved"},
+		{name: "KeyTooLong", tags: strings.Repeat("k", 129) + "=v", wantErr: "too long"},
+		{name: "TooMany", tags: "a=1,b=2,c=3,d=4,e=5,f=6,g=7,h=8,i=9,j=10,k=11", wantErr: "at most 10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3:my-bucket/path")
+			if tt.tags != "" {
+				t.Setenv("S3_OBJECT_TAGS", tt.tags)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, "S3_OBJECT_TAGS")
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.S3ObjectTags)
+		})
+	}
+}
+
+func TestResolveCompression(t *testing.T) {
+	tests := []struct {
+		name   string
+		global bool
+		job    *bool
+		want   bool
+	}{
+		{name: "NoLabelInheritsGlobalOff", global: false, job: nil, want: false},
+		{name: "NoLabelInheritsGlobalOn", global: true, job: nil, want: true},
+		{name: "LabelEnablesOverGlobalOff", global: false, job: boolPtr(true), want: true},
+		{name: "LabelDisablesOverGlobalOn", global: true, job: boolPtr(false), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &GlobalConfig{Compression: tt.global}
+			got := g.ResolveCompression(VolumeJob{Compression: tt.job})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveConcurrency(t *testing.T) {
+	g := &GlobalConfig{ObjectConcurrency: 16}
+	assert.Equal(t, 16, g.ResolveConcurrency(VolumeJob{}))
+	assert.Equal(t, 4, g.ResolveConcurrency(VolumeJob{Concurrency: 4}))
+}
+
+func TestLoadGlobal_Concurrency(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantObject int
+		wantPart   int
+		wantErr    string
+	}{
+		{name: "Defaults", wantObject: 16, wantPart: 2},
+		{name: "Set", env: map[string]string{"SYNC_OBJECT_CONCURRENCY": "8", "SYNC_PART_CONCURRENCY": "2"}, wantObject: 8, wantPart: 2},
+		{name: "Auto", env: map[string]string{"SYNC_OBJECT_CONCURRENCY": "auto"}, wantObject: 0, wantPart: 0},
+		{name: "AutoWithParts", env: map[string]string{"SYNC_OBJECT_CONCURRENCY": "auto", "SYNC_PART_CONCURRENCY": "4"}, wantObject: 0, wantPart: 4},
+		{name: "ZeroObjects", env: map[string]string{"SYNC_OBJECT_CONCURRENCY": "0"}, wantErr: "SYNC_OBJECT_CONCURRENCY"},
+		{name: "NegativeParts", env: map[string]string{"SYNC_PART_CONCURRENCY": "-1"}, wantErr: "SYNC_PART_CONCURRENCY"},
+		{name: "NotANumber", env: map[string]string{"SYNC_PART_CONCURRENCY": "many"}, wantErr: "SYNC_PART_CONCURRENCY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantObject, got.ObjectConcurrency)
+			assert.Equal(t, tt.wantPart, got.PartConcurrency)
+		})
+	}
+}
+
+func TestLoadGlobal_Status(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		wantAddr string
+		wantSize int
+		wantErr  bool
+	}{
+		{name: "Defaults", wantSize: 20},
+		{name: "Set", env: map[string]string{"STATUS_ADDR": ":8080", "STATUS_HISTORY_SIZE": "5"}, wantAddr: ":8080", wantSize: 5},
+		{name: "ZeroSize", env: map[string]string{"STATUS_HISTORY_SIZE": "0"}, wantErr: true},
+		{name: "NotANumber", env: map[string]string{"STATUS_HISTORY_SIZE": "all"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Clearenv()
+			t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadGlobal()
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "STATUS_HISTORY_SIZE")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAddr, got.StatusAddr)
+			assert.Equal(t, tt.wantSize, got.StatusHistorySize)
+		})
+	}
+}
+
+func TestLoadGlobal_PartSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    fs.SizeSuffix
+		wantErr bool
+	}{
+		{name: "Unset", value: "", want: 0},
+		{name: "Minimum", value: "5M", want: 5 * fs.Mebi},
+		{name: "Bytes", value: "67108864b", want: 64 * fs.Mebi},
+		{name: "Large", value: "1G", want: fs.Gibi},
+		{name: "TooSmall", value: "4M", wantErr: true},
+		{name: "Invalid", value: "big", wantErr: true},
+	}
+
+	for _, name := range []string{"S3_UPLOAD_PART_SIZE", "S3_DOWNLOAD_PART_SIZE"} {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				os.Clearenv()
+				t.Setenv("DESTINATION_PATH", "s3://my-bucket/path")
+				if tt.value != "" {
+					t.Setenv(name, tt.value)
+				}
+
+				got, err := LoadGlobal()
+				if tt.wantErr {
+					assert.ErrorContains(t, err, name)
+					return
+				}
+				require.NoError(t, err)
+				if name == "S3_UPLOAD_PART_SIZE" {
+					assert.Equal(t, tt.want, got.UploadPartSize)
+				} else {
+					assert.Equal(t, tt.want, got.DownloadPartSize)
+				}
+			})
+		}
+	}
+}
+
 func strPtr(s string) *string { return &s }
 
 func TestParseLabels_Patterns(t *testing.T) {
@@ -326,10 +2455,11 @@ func TestParseLabels_Patterns(t *testing.T) {
 				labels["volumesync.exclude"] = tt.exclude
 			}
 
-			job, err := ParseLabels(labels)
+			jobs, err := ParseLabels(labels, ScheduleParser{})
 			require.NoError(t, err)
-			assert.Equal(t, tt.wantInclude, job.Include)
-			assert.Equal(t, tt.wantExclude, job.Exclude)
+			require.Len(t, jobs, 1)
+			assert.Equal(t, tt.wantInclude, jobs[0].Include)
+			assert.Equal(t, tt.wantExclude, jobs[0].Exclude)
 		})
 	}
 }
@@ -0,0 +1,70 @@
+package dockermanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	dockerClient "github.com/moby/moby/client"
+)
+
+// WithDaemon connects to the docker daemon at host, such as
+// unix:///var/run/docker.sock or tcp://docker:2375, speaking API version
+// apiVersion, rather than the ones the environment sets, or the default socket
+// and a version negotiated with the daemon. Empty values keep those. Only New
+// uses it.
+func WithDaemon(host, apiVersion string) Option {
+	return func(m *Manager) {
+		m.host = host
+		m.apiVersion = apiVersion
+	}
+}
+
+// Ping checks that the docker daemon can be reached and speaks an API version
+// in common, listing a container as the backups do, so that a misconfigured
+// connection shows up on start-up rather than at the first backup.
+func (m *Manager) Ping(ctx context.Context) error {
+	if _, err := m.client.ContainerList(ctx, dockerClient.ContainerListOptions{Limit: 1}); err != nil {
+		return daemonError(m.daemonHost(), err)
+	}
+	return nil
+}
+
+// daemonHost returns the host of the docker daemon talked to.
+func (m *Manager) daemonHost() string {
+	if m.host != "" {
+		return m.host
+	}
+	if host := os.Getenv(dockerClient.EnvOverrideHost); host != "" {
+		return host
+	}
+	return dockerClient.DefaultDockerHost
+}
+
+// daemonError explains err, from talking to the docker daemon at host, with
+// what to do about it in the common cases of a socket that isn't mounted or
+// can't be opened, nothing listening, or an API version the daemon doesn't
+// support.
+func daemonError(host string, err error) error {
+	socket, isSocket := strings.CutPrefix(host, "unix://")
+	msg := err.Error()
+	switch {
+	case isSocket && (errors.Is(err, os.ErrNotExist) || strings.Contains(msg, "no such file or directory")):
+		return fmt.Errorf("docker socket not found at %s: mount it into the container with -v %s:%s, or set DOCKER_HOST: %w", socket, socket, socket, err)
+	case errors.Is(err, os.ErrPermission) || strings.Contains(msg, "permission denied"):
+		if isSocket {
+			return fmt.Errorf("permission denied on docker socket at %s: run as root or with the group owning the socket (--group-add): %w", socket, err)
+		}
+		return fmt.Errorf("permission denied connecting to docker daemon at %s: %w", host, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("nothing is listening for docker at %s: check DOCKER_HOST and that the daemon is running: %w", host, err)
+	case strings.Contains(msg, "is too new") || strings.Contains(msg, "is too old") || strings.Contains(msg, "supported API version"):
+		return fmt.Errorf("docker daemon at %s doesn't support the API version used: unset DOCKER_API_VERSION, or set it to one the daemon supports (see docker version): %w", host, err)
+	case dockerClient.IsErrConnectionFailed(err):
+		return fmt.Errorf("failed to connect to docker daemon at %s: check DOCKER_HOST and that the daemon is running: %w", host, err)
+	}
+	return fmt.Errorf("failed to talk to docker daemon at %s: %w", host, err)
+}
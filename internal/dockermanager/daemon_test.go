@@ -0,0 +1,90 @@
+package dockermanager
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonError(t *testing.T) {
+	dial := func(network string, errno syscall.Errno) error {
+		return &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", errno)}
+	}
+	tests := []struct {
+		name string
+		host string
+		err  error
+		want string
+	}{
+		{
+			name: "SocketMissing",
+			host: "unix:///var/run/docker.sock",
+			err:  dial("unix", syscall.ENOENT),
+			want: "docker socket not found at /var/run/docker.sock: mount it into the container with -v /var/run/docker.sock:/var/run/docker.sock",
+		},
+		{
+			name: "SocketPermissionDenied",
+			host: "unix:///var/run/docker.sock",
+			err:  errors.New("permission denied while trying to connect to the docker API at unix:///var/run/docker.sock"),
+			want: "permission denied on docker socket at /var/run/docker.sock: run as root or with the group owning the socket",
+		},
+		{
+			name: "TCPPermissionDenied",
+			host: "tcp://docker:2375",
+			err:  dial("tcp", syscall.EACCES),
+			want: "permission denied connecting to docker daemon at tcp://docker:2375",
+		},
+		{
+			name: "Refused",
+			host: "tcp://docker:2375",
+			err:  dial("tcp", syscall.ECONNREFUSED),
+			want: "nothing is listening for docker at tcp://docker:2375: check DOCKER_HOST",
+		},
+		{
+			name: "APIVersionTooNew",
+			host: "unix:///var/run/docker.sock",
+			err:  errors.New("Error response from daemon: client version 1.55 is too new. Maximum supported API version is 1.43"),
+			want: "docker daemon at unix:///var/run/docker.sock doesn't support the API version used: unset DOCKER_API_VERSION",
+		},
+		{
+			name: "Other",
+			host: "unix:///var/run/docker.sock",
+			err:  errors.New("unexpected EOF"),
+			want: "failed to talk to docker daemon at unix:///var/run/docker.sock: unexpected EOF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := daemonError(tt.host, tt.err)
+			assert.ErrorContains(t, err, tt.want)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Reachable", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := NewWithClient(mockClient, nil)
+		mockClient.On("ContainerList", ctx, client.ContainerListOptions{Limit: 1}).Return(client.ContainerListResult{}, nil)
+
+		assert.NoError(t, mgr.Ping(ctx))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := NewWithClient(mockClient, nil, WithDaemon("unix:///run/docker.sock", ""))
+		mockClient.On("ContainerList", ctx, client.ContainerListOptions{Limit: 1}).Return(client.ContainerListResult{}, &net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.ENOENT)})
+
+		assert.ErrorContains(t, mgr.Ping(ctx), "docker socket not found at /run/docker.sock")
+	})
+}
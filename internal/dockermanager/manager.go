@@ -1,13 +1,22 @@
 package dockermanager
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dedalusj/docker-volume-sync/internal/config"
+	"github.com/moby/moby/api/pkg/stdcopy"
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
 	dockerClient "github.com/moby/moby/client"
 )
 
@@ -15,19 +24,138 @@ type DockerClient interface {
 	ContainerList(ctx context.Context, options dockerClient.ContainerListOptions) (dockerClient.ContainerListResult, error)
 	ContainerStop(ctx context.Context, containerID string, options dockerClient.ContainerStopOptions) (dockerClient.ContainerStopResult, error)
 	ContainerStart(ctx context.Context, containerID string, options dockerClient.ContainerStartOptions) (dockerClient.ContainerStartResult, error)
+	ContainerInspect(ctx context.Context, containerID string, options dockerClient.ContainerInspectOptions) (dockerClient.ContainerInspectResult, error)
+	ContainerPause(ctx context.Context, containerID string, options dockerClient.ContainerPauseOptions) (dockerClient.ContainerPauseResult, error)
+	ContainerUnpause(ctx context.Context, containerID string, options dockerClient.ContainerUnpauseOptions) (dockerClient.ContainerUnpauseResult, error)
+	ExecCreate(ctx context.Context, containerID string, options dockerClient.ExecCreateOptions) (dockerClient.ExecCreateResult, error)
+	ExecAttach(ctx context.Context, execID string, options dockerClient.ExecAttachOptions) (dockerClient.ExecAttachResult, error)
+	ExecInspect(ctx context.Context, execID string, options dockerClient.ExecInspectOptions) (dockerClient.ExecInspectResult, error)
 	Close() error
 }
 
+// Container starts and unpauses are retried up to startAttempts times, waiting
+// defaultStartRetryDelay before the first retry and twice as long before each
+// one after that.
+const (
+	startAttempts          = 5
+	defaultStartRetryDelay = time.Second
+)
+
+// After stopping a container, its state is polled every
+// defaultStopPollInterval until it is no longer running, for up to its grace
+// period but no less than minStopWait.
+const (
+	defaultStopPollInterval = 500 * time.Millisecond
+	minStopWait             = 5 * time.Second
+)
+
 type Manager struct {
 	client DockerClient
+	// schedules validates the schedules of discovered jobs.
+	schedules config.ScheduleParser
+	// startRetryDelay is the wait before retrying a container start. Zero
+	// retries straight away.
+	startRetryDelay time.Duration
+	// stopPollInterval is the wait between checks that a stopped container
+	// has exited. Zero checks again straight away.
+	stopPollInterval time.Duration
+	// excludeLabels and excludeNames pick the containers never stopped or
+	// paused, see WithStopExclusions.
+	excludeLabels []string
+	excludeNames  []string
+	// stopReaders also stops or pauses containers that only mount a volume
+	// read-only, see WithStopReaders.
+	stopReaders bool
+	// bindMountsDir, when set, is where this process's container mounts the
+	// volumes, see WithBindMounts.
+	bindMountsDir string
+	// stopOrder and dependsOn set the order containers are stopped in, see
+	// WithStopOrder.
+	stopOrder []string
+	dependsOn bool
+	// host and apiVersion override the daemon connected to, see WithDaemon.
+	host       string
+	apiVersion string
+	// concurrency is how many containers are stopped or started at once,
+	// see WithConcurrency.
+	concurrency int
 }
 
-func New() (*Manager, error) {
-	client, err := dockerClient.New(dockerClient.FromEnv)
+type Option func(*Manager)
+
+// WithStopReaders has containers that mount a volume read-only stopped or
+// paused along with the ones that write to it, when stopping the containers
+// attached to it. By default they are left running, as they can't change the
+// files backed up.
+func WithStopReaders(stop bool) Option {
+	return func(m *Manager) {
+		m.stopReaders = stop
+	}
+}
+
+// WithConcurrency has up to n containers stopped, or started again, at once,
+// rather than one after the other. Containers are still handled one at a time
+// in order when WithStopOrder sets one.
+func WithConcurrency(n int) Option {
+	return func(m *Manager) {
+		m.concurrency = n
+	}
+}
+
+// WithBindMounts has the containers attached to a volume include those that
+// bind mount the host directory behind it, or a directory inside or above it.
+// That directory is the source of the mount at dir/<volume> in the container
+// this process runs in, which is how a host path is backed up as a volume.
+func WithBindMounts(dir string) Option {
+	return func(m *Manager) {
+		m.bindMountsDir = dir
+	}
+}
+
+// WithStopExclusions leaves the containers matching any of labels, each
+// written key or key=value, or named any of names, running when containers
+// are stopped or paused for a backup, as for one that only reads the volume.
+// Commands are still run in them.
+func WithStopExclusions(labels, names []string) Option {
+	return func(m *Manager) {
+		m.excludeLabels = labels
+		m.excludeNames = names
+	}
+}
+
+// New returns a Manager for the docker daemon the environment points at, whose
+// discovered jobs have schedules that parse with schedules.
+func New(schedules config.ScheduleParser, opts ...Option) (*Manager, error) {
+	m := NewWithClient(nil, schedules, opts...)
+	clientOpts := []dockerClient.Opt{dockerClient.FromEnv}
+	if m.host != "" {
+		clientOpts = append(clientOpts, dockerClient.WithHost(m.host))
+	}
+	if m.apiVersion != "" {
+		clientOpts = append(clientOpts, dockerClient.WithAPIVersion(m.apiVersion))
+	}
+	client, err := dockerClient.New(clientOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create docker client: %w", err)
 	}
-	return &Manager{client: client}, nil
+	m.client = client
+	return m, nil
+}
+
+// NewWithClient returns a Manager that talks to the docker daemon through
+// client, for callers that set up their own, whose discovered jobs have
+// schedules that parse with schedules. Closing the Manager closes client.
+func NewWithClient(client DockerClient, schedules config.ScheduleParser, opts ...Option) *Manager {
+	m := &Manager{
+		client:           client,
+		schedules:        schedules,
+		startRetryDelay:  defaultStartRetryDelay,
+		stopPollInterval: defaultStopPollInterval,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 func (m *Manager) Close() error {
@@ -45,21 +173,20 @@ func (m *Manager) DiscoverJobs(ctx context.Context) ([]config.VolumeJob, error)
 	jobsMap := make(map[string]*config.VolumeJob)
 
 	for _, c := range containers {
-		job, err := config.ParseLabels(c.Labels)
+		parsed, err := config.ParseLabels(c.Labels, m.schedules)
 		if err != nil {
-			log.Printf("Warning: failed to parse labels for container %s: %v", c.ID, err)
-			continue
-		}
-		if job == nil {
+			slog.Warn("Failed to parse labels", "container", c.ID, "error", err)
 			continue
 		}
 
-		if existing, ok := jobsMap[job.VolumeName]; ok {
-			// Merge container IDs for the same volume job
-			existing.ContainerIDs = append(existing.ContainerIDs, c.ID)
-		} else {
-			job.ContainerIDs = []string{c.ID}
-			jobsMap[job.VolumeName] = job
+		for _, job := range parsed {
+			if existing, ok := jobsMap[job.VolumeName]; ok {
+				// Merge container IDs for the same volume job
+				existing.ContainerIDs = append(existing.ContainerIDs, c.ID)
+			} else {
+				job.ContainerIDs = []string{c.ID}
+				jobsMap[job.VolumeName] = &job
+			}
 		}
 	}
 
@@ -71,46 +198,559 @@ func (m *Manager) DiscoverJobs(ctx context.Context) ([]config.VolumeJob, error)
 	return jobs, nil
 }
 
-// StopContainers stops the given containers with a grace period.
+// StopContainers stops the given containers with a grace period. Containers
+// that aren't running are left alone, so only the ones this call actually
+// stopped are returned for restarting later.
+//
+// A container that fails to stop doesn't prevent the others from being
+// stopped; every failure is reported in the joined error.
 func (m *Manager) StopContainers(ctx context.Context, ids []string, gracePeriod time.Duration) ([]string, error) {
-	selfID, _ := os.Hostname()
+	running, err := m.runningContainers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return m.stopRunning(ctx, m.quiesceable(running), gracePeriod)
+}
 
-	var stoppedIDs []string
-	timeoutSeconds := int(gracePeriod.Seconds())
+// runningContainers returns those of ids that are running, in order, logging
+// the others.
+func (m *Manager) runningContainers(ctx context.Context, ids []string) ([]container.Summary, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
 
+	filters := make(dockerClient.Filters).Add("status", "running")
 	for _, id := range ids {
-		if id == selfID || (len(id) >= 12 && len(selfID) >= 12 && id[:12] == selfID[:12]) {
-			log.Printf("Skipping self (%s)", id)
+		filters.Add("id", id)
+	}
+	res, err := m.client.ContainerList(ctx, dockerClient.ContainerListOptions{Filters: filters})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running containers: %w", err)
+	}
+
+	running := make(map[string]container.Summary, len(res.Items))
+	for _, c := range res.Items {
+		running[c.ID] = c
+	}
+
+	var toQuiesce []container.Summary
+	for _, id := range ids {
+		c, ok := running[id]
+		if !ok {
+			idToLog := id
+			if len(id) > 12 {
+				idToLog = id[:12]
+			}
+			slog.Info("Container is not running, leaving it alone", "container", idToLog)
+			continue
+		}
+		toQuiesce = append(toQuiesce, c)
+	}
+	return toQuiesce, nil
+}
+
+// quiesceable returns the IDs of those of containers that may be stopped or
+// paused, in the order to do so, logging the ones excluded by
+// WithStopExclusions.
+func (m *Manager) quiesceable(containers []container.Summary) []string {
+	ids := make([]string, 0, len(containers))
+	for _, c := range m.order(containers) {
+		if reason := m.excluded(c); reason != "" {
+			idToLog := c.ID
+			if len(idToLog) > 12 {
+				idToLog = idToLog[:12]
+			}
+			slog.Info("Container excluded from stopping, leaving it running", "container", idToLog, "matched", reason)
 			continue
 		}
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// excluded returns the exclusion c matches, or "" if none.
+func (m *Manager) excluded(c container.Summary) string {
+	for _, l := range m.excludeLabels {
+		key, value, hasValue := strings.Cut(l, "=")
+		if v, ok := c.Labels[key]; ok && (!hasValue || v == value) {
+			return "label " + l
+		}
+	}
+	for _, name := range m.excludeNames {
+		if hasName(c, name) {
+			return "name " + name
+		}
+	}
+	return ""
+}
+
+// isSelf reports whether id is the container this process runs in.
+func isSelf(id string) bool {
+	selfID, _ := os.Hostname()
+	return id == selfID || (len(id) >= 12 && len(selfID) >= 12 && id[:12] == selfID[:12])
+}
+
+// stopRunning stops containers already known to be running. The containers
+// stopped and the errors are returned in the order of ids, however many are
+// stopped at once.
+func (m *Manager) stopRunning(ctx context.Context, ids []string, gracePeriod time.Duration) ([]string, error) {
+	stopped := make([]bool, len(ids))
+	errs := make([]error, len(ids))
+	timeoutSeconds := int(gracePeriod.Seconds())
+
+	m.forEach(ids, func(i int, id string) {
+		if isSelf(id) {
+			slog.Info("Skipping self", "container", id)
+			return
+		}
 
 		idToLog := id
 		if len(id) > 12 {
 			idToLog = id[:12]
 		}
-		log.Printf("Stopping container %s...", idToLog)
+		slog.Info("Stopping container", "container", idToLog)
 		_, err := m.client.ContainerStop(ctx, id, dockerClient.ContainerStopOptions{Timeout: &timeoutSeconds})
 		if err != nil {
-			log.Printf("Failed to stop container %s: %v", id, err)
-			continue
+			slog.Error("Failed to stop container", "container", id, "error", err)
+			errs[i] = fmt.Errorf("failed to stop container %s: %w", idToLog, err)
+			return
 		}
-		stoppedIDs = append(stoppedIDs, id)
+		// Returned even if it never exits, so that it is started again
+		// along with the others.
+		stopped[i] = true
+		if err := m.waitStopped(ctx, id, max(gracePeriod, minStopWait)); err != nil {
+			slog.Error("Container did not stop", "container", idToLog, "error", err)
+			errs[i] = fmt.Errorf("failed to stop container %s: %w", idToLog, err)
+		}
+	})
+
+	var stoppedIDs []string
+	for i, id := range ids {
+		if stopped[i] {
+			stoppedIDs = append(stoppedIDs, id)
+		}
+	}
+	return stoppedIDs, errors.Join(errs...)
+}
+
+// forEach calls fn with each of ids and its index, for up to the concurrency
+// set with WithConcurrency at once, returning once all calls have. Without
+// it, or with a stop order, ids are taken one at a time in order.
+func (m *Manager) forEach(ids []string, fn func(i int, id string)) {
+	limit := m.concurrency
+	if limit < 1 || m.ordered() {
+		limit = 1
+	}
+	if limit == 1 {
+		for i, id := range ids {
+			fn(i, id)
+		}
+		return
 	}
 
-	return stoppedIDs, nil
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	for i, id := range ids {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			fn(i, id)
+		})
+	}
+	wg.Wait()
 }
 
-func (m *Manager) StartContainers(ctx context.Context, ids []string) error {
+// waitStopped polls a container that has been asked to stop until the daemon
+// no longer reports it running, returning an error if it still is after
+// timeout. This keeps a backup from starting while the app is still flushing
+// its writes.
+func (m *Manager) waitStopped(ctx context.Context, id string, timeout time.Duration) error {
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		res, err := m.client.ContainerInspect(waitCtx, id, dockerClient.ContainerInspectOptions{})
+		if err != nil && waitCtx.Err() == nil {
+			return fmt.Errorf("failed to inspect container: %w", err)
+		}
+		if err == nil && (res.Container.State == nil || !res.Container.State.Running) {
+			return nil
+		}
+
+		t := time.NewTimer(m.stopPollInterval)
+		select {
+		case <-waitCtx.Done():
+			t.Stop()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("still running %s after being stopped", timeout)
+		case <-t.C:
+		}
+	}
+}
+
+// StopContainersByLabel stops the running containers carrying the given label,
+// with a grace period. An empty value matches any container with the label.
+func (m *Manager) StopContainersByLabel(ctx context.Context, labelKey, labelValue string, gracePeriod time.Duration) ([]string, error) {
+	selector := labelKey
+	if labelValue != "" {
+		selector += "=" + labelValue
+	}
+
+	res, err := m.client.ContainerList(ctx, dockerClient.ContainerListOptions{
+		Filters: make(dockerClient.Filters).Add("label", selector),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers with label %s: %w", selector, err)
+	}
+
+	return m.stopRunning(ctx, m.quiesceable(res.Items), gracePeriod)
+}
+
+// StopContainersAttachedToVolume stops the running containers that mount the
+// given volume, with a grace period. Unless WithStopReaders is set, those
+// that only mount it read-only are left running.
+func (m *Manager) StopContainersAttachedToVolume(ctx context.Context, volume string, gracePeriod time.Duration) ([]string, error) {
+	running, match, err := m.runningContainersWithVolume(ctx, volume)
+	if err != nil {
+		return nil, err
+	}
+	return m.stopRunning(ctx, m.quiesceable(m.writers(running, match)), gracePeriod)
+}
+
+// PauseContainers pauses the given containers, freezing their processes
+// without stopping them. Like StopContainers, containers that aren't running
+// are left alone and only the ones this call paused are returned, and a
+// failure to pause one doesn't prevent the others from being paused.
+func (m *Manager) PauseContainers(ctx context.Context, ids []string) ([]string, error) {
+	running, err := m.runningContainers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return m.pauseRunning(ctx, m.quiesceable(running))
+}
+
+// PauseContainersByLabel pauses the running containers carrying the given
+// label. An empty value matches any container with the label.
+func (m *Manager) PauseContainersByLabel(ctx context.Context, labelKey, labelValue string) ([]string, error) {
+	selector := labelKey
+	if labelValue != "" {
+		selector += "=" + labelValue
+	}
+
+	// Unlike a stop, a pause fails on a container that is already paused, so
+	// only running ones are asked for.
+	res, err := m.client.ContainerList(ctx, dockerClient.ContainerListOptions{
+		Filters: make(dockerClient.Filters).Add("label", selector).Add("status", "running"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers with label %s: %w", selector, err)
+	}
+
+	return m.pauseRunning(ctx, m.quiesceable(res.Items))
+}
+
+// PauseContainersAttachedToVolume pauses the running containers that mount
+// the given volume, leaving those that only read it as
+// StopContainersAttachedToVolume does.
+func (m *Manager) PauseContainersAttachedToVolume(ctx context.Context, volume string) ([]string, error) {
+	running, match, err := m.runningContainersWithVolume(ctx, volume)
+	if err != nil {
+		return nil, err
+	}
+	return m.pauseRunning(ctx, m.quiesceable(m.writers(running, match)))
+}
+
+// writers returns those of containers that can write to volume, logging the
+// others, or all of them with WithStopReaders set. A container is only taken
+// for a reader when every mount of volume it has is read-only.
+func (m *Manager) writers(containers []container.Summary, match volumeMatch) []container.Summary {
+	if m.stopReaders {
+		return containers
+	}
+	return slices.DeleteFunc(slices.Clone(containers), func(c container.Summary) bool {
+		mounted := false
+		for _, mp := range c.Mounts {
+			if !match.matches(mp) {
+				continue
+			}
+			if mp.RW {
+				return false
+			}
+			mounted = true
+		}
+		if mounted {
+			idToLog := c.ID
+			if len(idToLog) > 12 {
+				idToLog = idToLog[:12]
+			}
+			slog.Info("Container mounts the volume read-only, leaving it running", "container", idToLog, "volume", match.name)
+		}
+		return mounted
+	})
+}
+
+// pauseRunning pauses containers already known to be running.
+func (m *Manager) pauseRunning(ctx context.Context, ids []string) ([]string, error) {
+	var pausedIDs []string
+	var errs []error
+
 	for _, id := range ids {
+		if isSelf(id) {
+			slog.Info("Skipping self", "container", id)
+			continue
+		}
+
 		idToLog := id
 		if len(id) > 12 {
 			idToLog = id[:12]
 		}
-		log.Printf("Restarting container %s...", idToLog)
-		_, err := m.client.ContainerStart(ctx, id, dockerClient.ContainerStartOptions{})
-		if err != nil {
-			log.Printf("Failed to start container %s: %v", id, err)
+		slog.Info("Pausing container", "container", idToLog)
+		if _, err := m.client.ContainerPause(ctx, id, dockerClient.ContainerPauseOptions{}); err != nil {
+			slog.Error("Failed to pause container", "container", id, "error", err)
+			errs = append(errs, fmt.Errorf("failed to pause container %s: %w", idToLog, err))
+			continue
+		}
+		pausedIDs = append(pausedIDs, id)
+	}
+
+	return pausedIDs, errors.Join(errs...)
+}
+
+// ExecInContainers runs cmd in each of the given containers that is running,
+// returning the ones it succeeded in. Like PauseContainers, a failure in one
+// container doesn't prevent the command from running in the others.
+func (m *Manager) ExecInContainers(ctx context.Context, ids []string, cmd []string) ([]string, error) {
+	running, err := m.runningContainers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var ranIDs []string
+	var errs []error
+	for _, id := range containerIDs(running) {
+		if isSelf(id) {
+			slog.Info("Skipping self", "container", id)
+			continue
+		}
+		if err := m.ExecInContainer(ctx, id, cmd); err != nil {
+			errs = append(errs, err)
+			continue
 		}
+		ranIDs = append(ranIDs, id)
+	}
+	return ranIDs, errors.Join(errs...)
+}
+
+// ExecInContainer runs cmd inside a running container, waiting for it to exit
+// and logging its output. A command exiting with a non-zero code is reported
+// as an error, with its output.
+func (m *Manager) ExecInContainer(ctx context.Context, id string, cmd []string) error {
+	idToLog := id
+	if len(id) > 12 {
+		idToLog = id[:12]
+	}
+	command := strings.Join(cmd, " ")
+	slog.Info("Running command in container", "container", idToLog, "command", command)
+
+	created, err := m.client.ExecCreate(ctx, id, dockerClient.ExecCreateOptions{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to exec %q in container %s: %w", command, idToLog, err)
+	}
+
+	attached, err := m.client.ExecAttach(ctx, created.ID, dockerClient.ExecAttachOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to exec %q in container %s: %w", command, idToLog, err)
+	}
+	defer attached.Close()
+	// Reading the output only ends when the command does, so give up on it
+	// when ctx is done.
+	stop := context.AfterFunc(ctx, attached.Close)
+	defer stop()
+
+	var out bytes.Buffer
+	_, err = stdcopy.StdCopy(&out, &out, attached.Reader)
+	if ctx.Err() != nil {
+		return fmt.Errorf("failed to exec %q in container %s: %w", command, idToLog, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read output of %q in container %s: %w", command, idToLog, err)
+	}
+	output := strings.TrimSpace(out.String())
+	if output != "" {
+		slog.Info("Command output", "container", idToLog, "command", command, "output", output)
+	}
+
+	inspected, err := m.client.ExecInspect(ctx, created.ID, dockerClient.ExecInspectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec of %q in container %s: %w", command, idToLog, err)
+	}
+	if inspected.ExitCode != 0 {
+		if output != "" {
+			return fmt.Errorf("command %q in container %s exited with code %d: %s", command, idToLog, inspected.ExitCode, output)
+		}
+		return fmt.Errorf("command %q in container %s exited with code %d", command, idToLog, inspected.ExitCode)
 	}
 	return nil
 }
+
+// volumeMatch picks out the mounts of a volume: those of the named volume,
+// and bind mounts of hostPath, or a directory inside or above it, when set.
+type volumeMatch struct {
+	name     string
+	hostPath string
+}
+
+func (v volumeMatch) matches(mp container.MountPoint) bool {
+	switch mp.Type {
+	case mount.TypeVolume:
+		return mp.Name == v.name
+	case mount.TypeBind:
+		if v.hostPath == "" {
+			return false
+		}
+		source := path.Clean(mp.Source)
+		return within(source, v.hostPath) || within(v.hostPath, source)
+	}
+	return false
+}
+
+// within reports whether p is dir or lies inside it, both being clean.
+func within(p, dir string) bool {
+	return p == dir || dir == "/" || strings.HasPrefix(p, dir+"/")
+}
+
+// runningContainersWithVolume lists the running containers mounting volume,
+// returning them with the match for its mounts. The matching is left to the
+// daemon, unless bind mounts are matched too, which it can't do. Daemons that
+// reject the volume filter fall back to matching mounts here.
+func (m *Manager) runningContainersWithVolume(ctx context.Context, volume string) ([]container.Summary, volumeMatch, error) {
+	match := volumeMatch{name: volume}
+	if m.bindMountsDir == "" {
+		res, err := m.client.ContainerList(ctx, dockerClient.ContainerListOptions{
+			Filters: make(dockerClient.Filters).Add("volume", volume).Add("status", "running"),
+		})
+		if err == nil {
+			return res.Items, match, nil
+		}
+		slog.Debug("Volume filter unsupported, matching mounts client-side", "error", err)
+	}
+
+	res, err := m.client.ContainerList(ctx, dockerClient.ContainerListOptions{
+		Filters: make(dockerClient.Filters).Add("status", "running"),
+	})
+	if err != nil {
+		return nil, match, fmt.Errorf("failed to list containers: %w", err)
+	}
+	if m.bindMountsDir != "" {
+		match.hostPath = selfMountSource(res.Items, path.Join(m.bindMountsDir, volume))
+		if match.hostPath == "" {
+			slog.Debug("Volume not found among this container's mounts, matching it by name only", "volume", volume)
+		}
+	}
+
+	var matching []container.Summary
+	for _, c := range res.Items {
+		if slices.ContainsFunc(c.Mounts, match.matches) {
+			matching = append(matching, c)
+		}
+	}
+	return matching, match, nil
+}
+
+// selfMountSource returns the host path mounted at dest in the container this
+// process runs in, found among containers, or "" if there is none.
+func selfMountSource(containers []container.Summary, dest string) string {
+	for _, c := range containers {
+		if !isSelf(c.ID) {
+			continue
+		}
+		for _, mp := range c.Mounts {
+			if path.Clean(mp.Destination) == dest && mp.Source != "" {
+				return path.Clean(mp.Source)
+			}
+		}
+	}
+	return ""
+}
+
+func containerIDs(containers []container.Summary) []string {
+	ids := make([]string, 0, len(containers))
+	for _, c := range containers {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+// StartContainers starts the given containers, retrying each one that fails
+// to start with a growing delay. A container that won't start doesn't hold up
+// the others; every container that never started is reported in the joined
+// error. As many are started at once as are stopped, see WithConcurrency, and
+// with WithStopOrder set they are started in the reverse of the order they
+// were stopped in.
+func (m *Manager) StartContainers(ctx context.Context, ids []string) error {
+	ids = m.restartOrder(ids)
+	errs := make([]error, len(ids))
+	m.forEach(ids, func(i int, id string) {
+		idToLog := id
+		if len(id) > 12 {
+			idToLog = id[:12]
+		}
+		slog.Info("Restarting container", "container", idToLog)
+		errs[i] = m.retry(ctx, idToLog, "start", func() error {
+			_, err := m.client.ContainerStart(ctx, id, dockerClient.ContainerStartOptions{})
+			return err
+		})
+	})
+	return errors.Join(errs...)
+}
+
+// UnpauseContainers unpauses the given containers, retrying like
+// StartContainers.
+func (m *Manager) UnpauseContainers(ctx context.Context, ids []string) error {
+	var errs []error
+	for _, id := range m.restartOrder(ids) {
+		idToLog := id
+		if len(id) > 12 {
+			idToLog = id[:12]
+		}
+		slog.Info("Unpausing container", "container", idToLog)
+		err := m.retry(ctx, idToLog, "unpause", func() error {
+			_, err := m.client.ContainerUnpause(ctx, id, dockerClient.ContainerUnpauseOptions{})
+			return err
+		})
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// retry makes up to startAttempts attempts to apply action to a container
+// through fn.
+func (m *Manager) retry(ctx context.Context, idToLog, action string, fn func() error) error {
+	delay := m.startRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == startAttempts {
+			slog.Error("Failed to "+action+" container", "container", idToLog, "attempts", attempt, "error", err)
+			return fmt.Errorf("failed to %s container %s after %d attempts: %w", action, idToLog, attempt, err)
+		}
+		slog.Warn("Failed to "+action+" container, retrying", "container", idToLog, "attempt", attempt, "delay", delay, "error", err)
+
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("failed to %s container %s: %w", action, idToLog, err)
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
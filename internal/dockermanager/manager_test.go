@@ -1,12 +1,20 @@
 package dockermanager
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
+	"errors"
+	"net"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/moby/moby/api/pkg/stdcopy"
 	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/api/types/mount"
 	"github.com/moby/moby/client"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -31,6 +39,70 @@ func (m *MockDockerClient) ContainerStart(ctx context.Context, containerID strin
 	return args.Get(0).(client.ContainerStartResult), args.Error(1)
 }
 
+func (m *MockDockerClient) ContainerInspect(ctx context.Context, containerID string, options client.ContainerInspectOptions) (client.ContainerInspectResult, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(client.ContainerInspectResult), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerPause(ctx context.Context, containerID string, options client.ContainerPauseOptions) (client.ContainerPauseResult, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(client.ContainerPauseResult), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerUnpause(ctx context.Context, containerID string, options client.ContainerUnpauseOptions) (client.ContainerUnpauseResult, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(client.ContainerUnpauseResult), args.Error(1)
+}
+
+func (m *MockDockerClient) ExecCreate(ctx context.Context, containerID string, options client.ExecCreateOptions) (client.ExecCreateResult, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(client.ExecCreateResult), args.Error(1)
+}
+
+func (m *MockDockerClient) ExecAttach(ctx context.Context, execID string, options client.ExecAttachOptions) (client.ExecAttachResult, error) {
+	args := m.Called(ctx, execID, options)
+	return args.Get(0).(client.ExecAttachResult), args.Error(1)
+}
+
+func (m *MockDockerClient) ExecInspect(ctx context.Context, execID string, options client.ExecInspectOptions) (client.ExecInspectResult, error) {
+	args := m.Called(ctx, execID, options)
+	return args.Get(0).(client.ExecInspectResult), args.Error(1)
+}
+
+// execOutput returns an attachment to an exec that writes stdout and stderr,
+// multiplexed as the daemon sends them, and then exits.
+func execOutput(stdout, stderr string) client.ExecAttachResult {
+	var stream bytes.Buffer
+	for _, frame := range []struct {
+		stream stdcopy.StdType
+		data   string
+	}{{stdcopy.Stdout, stdout}, {stdcopy.Stderr, stderr}} {
+		// Each frame has an 8 byte header: the stream, three bytes of
+		// padding and the big-endian length of the data.
+		header := []byte{byte(frame.stream), 0, 0, 0, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(header[4:], uint32(len(frame.data)))
+		stream.Write(header)
+		stream.WriteString(frame.data)
+	}
+	conn, _ := net.Pipe()
+	return client.ExecAttachResult{HijackedResponse: client.HijackedResponse{Conn: conn, Reader: bufio.NewReader(&stream)}}
+}
+
+// exitsOnStop makes every container inspected report that it has exited, as
+// the daemon does once a stop has gone through.
+func (m *MockDockerClient) exitsOnStop() {
+	m.On("ContainerInspect", mock.Anything, mock.Anything, mock.Anything).Return(inspected(false), nil).Maybe()
+}
+
+// inspected returns an inspection of a container that is running or not.
+func inspected(running bool) client.ContainerInspectResult {
+	status := container.StateExited
+	if running {
+		status = container.StateRunning
+	}
+	return client.ContainerInspectResult{Container: container.InspectResponse{State: &container.State{Status: status, Running: running}}}
+}
+
 func (m *MockDockerClient) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -96,6 +168,47 @@ func TestDiscoverJobs(t *testing.T) {
 		assert.True(t, vol1Job)
 		assert.True(t, vol2Job)
 	})
+
+	t.Run("One container with several volumes", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		containers := []container.Summary{
+			{
+				ID: "c1",
+				Labels: map[string]string{
+					"volumesync.enabled":  "true",
+					"volumesync.volume":   "vol1,vol2",
+					"volumesync.schedule": "@daily",
+				},
+			},
+			{
+				ID: "c2",
+				Labels: map[string]string{
+					"volumesync.enabled":  "true",
+					"volumesync.volume":   "vol2",
+					"volumesync.schedule": "@daily",
+				},
+			},
+		}
+
+		mockClient.On("ContainerList", ctx, client.ContainerListOptions{All: true}).Return(client.ContainerListResult{Items: containers}, nil)
+
+		jobs, err := mgr.DiscoverJobs(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, jobs, 2)
+
+		for _, j := range jobs {
+			switch j.VolumeName {
+			case "vol1":
+				assert.ElementsMatch(t, []string{"c1"}, j.ContainerIDs)
+			case "vol2":
+				assert.ElementsMatch(t, []string{"c1", "c2"}, j.ContainerIDs)
+			default:
+				t.Errorf("unexpected job for volume %s", j.VolumeName)
+			}
+		}
+	})
 }
 
 func TestStopContainers(t *testing.T) {
@@ -104,25 +217,126 @@ func TestStopContainers(t *testing.T) {
 
 	t.Run("Stop multiple", func(t *testing.T) {
 		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
 		mgr := &Manager{client: mockClient}
 
 		ids := []string{"c1", "c2"}
 
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("status", "running").Add("id", "c1", "c2"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}, {ID: "c2"}}}, nil)
 		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
 		mockClient.On("ContainerStop", ctx, "c2", mock.Anything).Return(client.ContainerStopResult{}, nil)
 
 		stopped, err := mgr.StopContainers(ctx, ids, gracePeriod)
 		assert.NoError(t, err)
 		assert.ElementsMatch(t, ids, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Leave stopped containers alone", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		// c2 was already stopped before the backup ran.
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}}}, nil)
+		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainers(ctx, []string{"c1", "c2"}, gracePeriod)
+		assert.NoError(t, err)
+		// Only c1 is handed back, so c2 won't be started after the backup.
+		assert.Equal(t, []string{"c1"}, stopped)
+		mockClient.AssertNotCalled(t, "ContainerStop", ctx, "c2", mock.Anything)
+	})
+
+	t.Run("List error", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{}, assert.AnError)
+
+		stopped, err := mgr.StopContainers(ctx, []string{"c1"}, gracePeriod)
+		assert.Error(t, err)
+		assert.Empty(t, stopped)
+		mockClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failures are collected", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}, {ID: "c2"}, {ID: "c3"}}}, nil)
+		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, errors.New("stuck"))
+		mockClient.On("ContainerStop", ctx, "c2", mock.Anything).Return(client.ContainerStopResult{}, nil)
+		mockClient.On("ContainerStop", ctx, "c3", mock.Anything).Return(client.ContainerStopResult{}, errors.New("gone"))
+
+		stopped, err := mgr.StopContainers(ctx, []string{"c1", "c2", "c3"}, gracePeriod)
+		assert.Equal(t, []string{"c2"}, stopped)
+		assert.ErrorContains(t, err, "c1")
+		assert.ErrorContains(t, err, "stuck")
+		assert.ErrorContains(t, err, "c3")
+		assert.ErrorContains(t, err, "gone")
+	})
+
+	t.Run("Wait until stopped", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		// The app is still flushing its writes when the stop returns.
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}}}, nil)
+		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
+		mockClient.On("ContainerInspect", mock.Anything, "c1", mock.Anything).Return(inspected(true), nil).Twice()
+		mockClient.On("ContainerInspect", mock.Anything, "c1", mock.Anything).Return(inspected(false), nil).Once()
+
+		stopped, err := mgr.StopContainers(ctx, []string{"c1"}, gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"c1"}, stopped)
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNumberOfCalls(t, "ContainerInspect", 3)
+	})
+
+	t.Run("Still running after the grace period", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient, stopPollInterval: 100 * time.Millisecond}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}}}, nil)
+		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
+		mockClient.On("ContainerInspect", mock.Anything, "c1", mock.Anything).Return(inspected(true), nil)
+
+		start := time.Now()
+		stopped, err := mgr.StopContainers(ctx, []string{"c1"}, 0)
+		assert.ErrorContains(t, err, "failed to stop container c1: still running")
+		// The wait lasts at least minStopWait, even with no grace period.
+		assert.GreaterOrEqual(t, time.Since(start), minStopWait)
+		// It is still handed back, so that it is started again afterwards.
+		assert.Equal(t, []string{"c1"}, stopped)
+	})
+
+	t.Run("Nothing to stop", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		stopped, err := mgr.StopContainers(ctx, nil, gracePeriod)
+		assert.NoError(t, err)
+		assert.Empty(t, stopped)
+		// An empty id filter would match every running container.
+		mockClient.AssertNotCalled(t, "ContainerList", mock.Anything, mock.Anything)
 	})
 
 	t.Run("Skip self", func(t *testing.T) {
 		hostname, _ := os.Hostname()
 		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
 		mgr := &Manager{client: mockClient}
 
 		ids := []string{hostname, "c1"}
 
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: hostname}, {ID: "c1"}}}, nil)
 		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
 
 		stopped, err := mgr.StopContainers(ctx, ids, gracePeriod)
@@ -131,6 +345,297 @@ func TestStopContainers(t *testing.T) {
 	})
 }
 
+func TestStopContainersByLabel(t *testing.T) {
+	ctx := context.Background()
+	gracePeriod := 10 * time.Second
+
+	t.Run("Stop matching containers", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		containers := []container.Summary{
+			{ID: "app", Labels: map[string]string{"com.docker.compose.project": "myapp"}},
+			{ID: "db", Labels: map[string]string{"com.docker.compose.project": "myapp"}},
+		}
+
+		// Filtering is left to the daemon, which only returns running containers.
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("label", "com.docker.compose.project=myapp"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: containers}, nil)
+		mockClient.On("ContainerStop", ctx, "app", mock.Anything).Return(client.ContainerStopResult{}, nil)
+		mockClient.On("ContainerStop", ctx, "db", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainersByLabel(ctx, "com.docker.compose.project", "myapp", gracePeriod)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"app", "db"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Empty value matches the key alone", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("label", "backup.quiesce"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{}, nil)
+
+		stopped, err := mgr.StopContainersByLabel(ctx, "backup.quiesce", "", gracePeriod)
+		assert.NoError(t, err)
+		assert.Empty(t, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Skip self", func(t *testing.T) {
+		hostname, _ := os.Hostname()
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		containers := []container.Summary{
+			{ID: hostname, Labels: map[string]string{"com.docker.compose.project": "myapp"}},
+			{ID: "app", Labels: map[string]string{"com.docker.compose.project": "myapp"}},
+		}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: containers}, nil)
+		mockClient.On("ContainerStop", ctx, "app", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainersByLabel(ctx, "com.docker.compose.project", "myapp", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"app"}, stopped)
+	})
+
+	t.Run("List error", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{}, assert.AnError)
+
+		_, err := mgr.StopContainersByLabel(ctx, "com.docker.compose.project", "myapp", gracePeriod)
+		assert.Error(t, err)
+	})
+}
+
+func TestStopContainersAttachedToVolume(t *testing.T) {
+	ctx := context.Background()
+	gracePeriod := 10 * time.Second
+
+	t.Run("Filters on the daemon", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("volume", "vol1").Add("status", "running"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}, {ID: "c2"}}}, nil)
+		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
+		mockClient.On("ContainerStop", ctx, "c2", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", gracePeriod)
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"c1", "c2"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Falls back to matching mounts", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := &Manager{client: mockClient}
+
+		filtered := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("volume", "vol1").Add("status", "running"),
+		}
+		running := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("status", "running"),
+		}
+		containers := []container.Summary{
+			{ID: "c1", Mounts: []container.MountPoint{{Type: mount.TypeVolume, Name: "vol1", RW: true}}},
+			{ID: "c2", Mounts: []container.MountPoint{{Type: mount.TypeVolume, Name: "vol2", RW: true}}},
+			// A bind mount whose name happens to match is not the volume.
+			{ID: "c3", Mounts: []container.MountPoint{{Type: mount.TypeBind, Name: "vol1", RW: true}}},
+		}
+		mockClient.On("ContainerList", ctx, filtered).Return(client.ContainerListResult{}, assert.AnError)
+		mockClient.On("ContainerList", ctx, running).Return(client.ContainerListResult{Items: containers}, nil)
+		mockClient.On("ContainerStop", ctx, "c1", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"c1"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestStopContainersAttachedToVolume_Readers(t *testing.T) {
+	ctx := context.Background()
+	gracePeriod := 10 * time.Second
+	opts := client.ContainerListOptions{
+		Filters: make(client.Filters).Add("volume", "vol1").Add("status", "running"),
+	}
+	containers := []container.Summary{
+		{ID: "writer", Mounts: []container.MountPoint{{Type: mount.TypeVolume, Name: "vol1", RW: true}}},
+		{ID: "reader", Mounts: []container.MountPoint{{Type: mount.TypeVolume, Name: "vol1", RW: false}}},
+		// Read-only here, but writable at another mount point.
+		{ID: "both", Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "vol1", RW: false, Destination: "/ro"},
+			{Type: mount.TypeVolume, Name: "vol1", RW: true, Destination: "/rw"},
+		}},
+		// Read-only, but of another volume.
+		{ID: "other", Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "vol1", RW: true},
+			{Type: mount.TypeVolume, Name: "vol2", RW: false},
+		}},
+	}
+
+	t.Run("Only writers", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := NewWithClient(mockClient, nil)
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: containers}, nil)
+		for _, id := range []string{"writer", "both", "other"} {
+			mockClient.On("ContainerStop", ctx, id, mock.Anything).Return(client.ContainerStopResult{}, nil)
+		}
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"writer", "both", "other"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Readers too", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := NewWithClient(mockClient, nil, WithStopReaders(true))
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: containers}, nil)
+		for _, id := range []string{"writer", "reader", "both", "other"} {
+			mockClient.On("ContainerStop", ctx, id, mock.Anything).Return(client.ContainerStopResult{}, nil)
+		}
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"writer", "reader", "both", "other"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestStopContainersAttachedToVolume_BindMounts(t *testing.T) {
+	ctx := context.Background()
+	gracePeriod := 10 * time.Second
+	hostname, _ := os.Hostname()
+	running := client.ContainerListOptions{
+		Filters: make(client.Filters).Add("status", "running"),
+	}
+	bind := func(source string) []container.MountPoint {
+		return []container.MountPoint{{Type: mount.TypeBind, Source: source, Destination: "/data", RW: true}}
+	}
+	others := []container.Summary{
+		{ID: "same", Mounts: bind("/srv/app")},
+		{ID: "unclean", Mounts: bind("/srv//app/")},
+		{ID: "inside", Mounts: bind("/srv/app/uploads")},
+		{ID: "above", Mounts: bind("/srv")},
+		{ID: "sibling", Mounts: bind("/srv/application")},
+		{ID: "elsewhere", Mounts: bind("/srv/other")},
+		{ID: "named", Mounts: []container.MountPoint{{Type: mount.TypeVolume, Name: "app", RW: true}}},
+	}
+
+	t.Run("Matches the host path", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := NewWithClient(mockClient, nil, WithBindMounts("/volumes"))
+		self := container.Summary{ID: hostname, Mounts: []container.MountPoint{{Type: mount.TypeBind, Source: "/srv/app/", Destination: "/volumes/app", RW: true}}}
+		mockClient.On("ContainerList", ctx, running).Return(client.ContainerListResult{Items: append([]container.Summary{self}, others...)}, nil)
+		for _, id := range []string{"same", "unclean", "inside", "above", "named"} {
+			mockClient.On("ContainerStop", ctx, id, mock.Anything).Return(client.ContainerStopResult{}, nil)
+		}
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "app", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"same", "unclean", "inside", "above", "named"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Not mounted here", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := NewWithClient(mockClient, nil, WithBindMounts("/volumes"))
+		mockClient.On("ContainerList", ctx, running).Return(client.ContainerListResult{Items: others}, nil)
+		mockClient.On("ContainerStop", ctx, "named", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "app", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"named"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestStopExclusions(t *testing.T) {
+	ctx := context.Background()
+	gracePeriod := 10 * time.Second
+	containers := []container.Summary{
+		{ID: "app", Names: []string{"/app"}},
+		{ID: "exporter", Names: []string{"/exporter"}, Labels: map[string]string{"role": "exporter"}},
+		{ID: "sidecar", Names: []string{"/sidecar"}, Labels: map[string]string{"role": "sidecar"}},
+		{ID: "metrics", Names: []string{"/metrics"}},
+		{ID: "reader", Names: []string{"/reader"}, Labels: map[string]string{"backup.readonly": ""}},
+	}
+	newManager := func(mockClient *MockDockerClient) *Manager {
+		return NewWithClient(mockClient, nil, WithStopExclusions([]string{"role=exporter", "backup.readonly"}, []string{"metrics"}))
+	}
+
+	t.Run("Attached to volume", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := newManager(mockClient)
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("volume", "vol1").Add("status", "running"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: containers}, nil)
+		mockClient.On("ContainerStop", ctx, "app", mock.Anything).Return(client.ContainerStopResult{}, nil)
+		mockClient.On("ContainerStop", ctx, "sidecar", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"app", "sidecar"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("By ID", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mockClient.exitsOnStop()
+		mgr := newManager(mockClient)
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("status", "running").Add("id", "app").Add("id", "metrics"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: []container.Summary{containers[0], containers[3]}}, nil)
+		mockClient.On("ContainerStop", ctx, "app", mock.Anything).Return(client.ContainerStopResult{}, nil)
+
+		stopped, err := mgr.StopContainers(ctx, []string{"app", "metrics"}, gracePeriod)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"app"}, stopped)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Paused", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := newManager(mockClient)
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("volume", "vol1").Add("status", "running"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: containers}, nil)
+		mockClient.On("ContainerPause", ctx, "app", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+		mockClient.On("ContainerPause", ctx, "sidecar", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+
+		paused, err := mgr.PauseContainersAttachedToVolume(ctx, "vol1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"app", "sidecar"}, paused)
+		mockClient.AssertExpectations(t)
+	})
+}
+
 func TestStartContainers(t *testing.T) {
 	ctx := context.Background()
 
@@ -147,4 +652,256 @@ func TestStartContainers(t *testing.T) {
 		assert.NoError(t, err)
 		mockClient.AssertExpectations(t)
 	})
+
+	t.Run("Retry a container that fails to start", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient, startRetryDelay: time.Millisecond}
+
+		mockClient.On("ContainerStart", ctx, "container1", client.ContainerStartOptions{}).Return(client.ContainerStartResult{}, errors.New("port is already allocated")).Twice()
+		mockClient.On("ContainerStart", ctx, "container1", client.ContainerStartOptions{}).Return(client.ContainerStartResult{}, nil).Once()
+
+		err := mgr.StartContainers(ctx, []string{"container1"})
+		assert.NoError(t, err)
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNumberOfCalls(t, "ContainerStart", 3)
+	})
+
+	t.Run("Report containers that never start without blocking others", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerStart", ctx, "broken", client.ContainerStartOptions{}).Return(client.ContainerStartResult{}, errors.New("no such image"))
+		mockClient.On("ContainerStart", ctx, "container2", client.ContainerStartOptions{}).Return(client.ContainerStartResult{}, nil).Once()
+
+		err := mgr.StartContainers(ctx, []string{"broken", "container2"})
+		assert.ErrorContains(t, err, "failed to start container broken after 5 attempts: no such image")
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNumberOfCalls(t, "ContainerStart", startAttempts+1)
+	})
+}
+
+func TestConcurrency(t *testing.T) {
+	ctx := context.Background()
+	ids := []string{"c1", "c2", "c3", "c4", "c5"}
+	var containers []container.Summary
+	for _, id := range ids {
+		containers = append(containers, container.Summary{ID: id})
+	}
+	var inFlight, maxInFlight atomic.Int32
+	track := func(mock.Arguments) {
+		n := inFlight.Add(1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		inFlight.Add(-1)
+	}
+
+	mockClient := new(MockDockerClient)
+	mockClient.exitsOnStop()
+	mgr := NewWithClient(mockClient, nil, WithConcurrency(3))
+	mgr.startRetryDelay = 0
+	mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: containers}, nil)
+	for _, id := range ids {
+		stopErr, startErr := error(nil), error(nil)
+		switch id {
+		case "c2":
+			stopErr = errors.New("no such container")
+		case "c4":
+			startErr = errors.New("port is already allocated")
+		case "c5":
+			startErr = errors.New("no such image")
+		}
+		mockClient.On("ContainerStop", ctx, id, mock.Anything).Run(track).Return(client.ContainerStopResult{}, stopErr)
+		mockClient.On("ContainerStart", ctx, id, client.ContainerStartOptions{}).Run(track).Return(client.ContainerStartResult{}, startErr)
+	}
+
+	stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", time.Second)
+	assert.Equal(t, []string{"c1", "c3", "c4", "c5"}, stopped)
+	assert.EqualError(t, err, "failed to stop container c2: no such container")
+
+	err = mgr.StartContainers(ctx, stopped)
+	assert.EqualError(t, err, "failed to start container c4 after 5 attempts: port is already allocated\n"+
+		"failed to start container c5 after 5 attempts: no such image")
+	mockClient.AssertNumberOfCalls(t, "ContainerStart", 2+2*startAttempts)
+
+	assert.Greater(t, maxInFlight.Load(), int32(1), "containers should be handled at once")
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+}
+
+func TestPauseContainers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Pause running containers", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("status", "running").Add("id", "c1", "c2"),
+		}
+		// c2 was already stopped, or paused, before the backup ran.
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}}}, nil)
+		mockClient.On("ContainerPause", ctx, "c1", client.ContainerPauseOptions{}).Return(client.ContainerPauseResult{}, nil)
+
+		paused, err := mgr.PauseContainers(ctx, []string{"c1", "c2"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"c1"}, paused)
+		mockClient.AssertExpectations(t)
+		mockClient.AssertNotCalled(t, "ContainerPause", ctx, "c2", mock.Anything)
+		mockClient.AssertNotCalled(t, "ContainerStop", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Failures are collected", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}, {ID: "c2"}}}, nil)
+		mockClient.On("ContainerPause", ctx, "c1", mock.Anything).Return(client.ContainerPauseResult{}, errors.New("cgroup freeze failed"))
+		mockClient.On("ContainerPause", ctx, "c2", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+
+		paused, err := mgr.PauseContainers(ctx, []string{"c1", "c2"})
+		assert.Equal(t, []string{"c2"}, paused)
+		assert.ErrorContains(t, err, "failed to pause container c1: cgroup freeze failed")
+	})
+
+	t.Run("Skip self", func(t *testing.T) {
+		hostname, _ := os.Hostname()
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: hostname}, {ID: "c1"}}}, nil)
+		mockClient.On("ContainerPause", ctx, "c1", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+
+		paused, err := mgr.PauseContainers(ctx, []string{hostname, "c1"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"c1"}, paused)
+	})
+
+	t.Run("By label", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		// Paused containers can't be paused again, so only running ones are
+		// listed.
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("label", "com.docker.compose.project=myapp").Add("status", "running"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: []container.Summary{{ID: "app"}, {ID: "db"}}}, nil)
+		mockClient.On("ContainerPause", ctx, "app", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+		mockClient.On("ContainerPause", ctx, "db", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+
+		paused, err := mgr.PauseContainersByLabel(ctx, "com.docker.compose.project", "myapp")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"app", "db"}, paused)
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Attached to volume", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		opts := client.ContainerListOptions{
+			Filters: make(client.Filters).Add("volume", "vol1").Add("status", "running"),
+		}
+		mockClient.On("ContainerList", ctx, opts).Return(client.ContainerListResult{Items: []container.Summary{{ID: "c1"}, {ID: "c2"}}}, nil)
+		mockClient.On("ContainerPause", ctx, "c1", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+		mockClient.On("ContainerPause", ctx, "c2", mock.Anything).Return(client.ContainerPauseResult{}, nil)
+
+		paused, err := mgr.PauseContainersAttachedToVolume(ctx, "vol1")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"c1", "c2"}, paused)
+		mockClient.AssertExpectations(t)
+	})
+}
+
+func TestUnpauseContainers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Unpause containers", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerUnpause", ctx, "c1", client.ContainerUnpauseOptions{}).Return(client.ContainerUnpauseResult{}, nil)
+		mockClient.On("ContainerUnpause", ctx, "c2", client.ContainerUnpauseOptions{}).Return(client.ContainerUnpauseResult{}, nil)
+
+		assert.NoError(t, mgr.UnpauseContainers(ctx, []string{"c1", "c2"}))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Retry and report containers left paused", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ContainerUnpause", ctx, "c1", mock.Anything).Return(client.ContainerUnpauseResult{}, errors.New("daemon busy")).Once()
+		mockClient.On("ContainerUnpause", ctx, "c1", mock.Anything).Return(client.ContainerUnpauseResult{}, nil).Once()
+		mockClient.On("ContainerUnpause", ctx, "stuck", mock.Anything).Return(client.ContainerUnpauseResult{}, errors.New("daemon busy"))
+
+		err := mgr.UnpauseContainers(ctx, []string{"c1", "stuck"})
+		assert.ErrorContains(t, err, "failed to unpause container stuck after 5 attempts: daemon busy")
+		assert.NotContains(t, err.Error(), "c1")
+		mockClient.AssertNumberOfCalls(t, "ContainerUnpause", 2+startAttempts)
+	})
+}
+
+func TestExecInContainer(t *testing.T) {
+	ctx := context.Background()
+	cmd := []string{"redis-cli", "BGSAVE"}
+
+	t.Run("Success", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ExecCreate", ctx, "c1", client.ExecCreateOptions{AttachStdout: true, AttachStderr: true, Cmd: cmd}).Return(client.ExecCreateResult{ID: "exec1"}, nil)
+		mockClient.On("ExecAttach", mock.Anything, "exec1", client.ExecAttachOptions{}).Return(execOutput("Background saving started\n", ""), nil)
+		mockClient.On("ExecInspect", ctx, "exec1", client.ExecInspectOptions{}).Return(client.ExecInspectResult{ExitCode: 0}, nil)
+
+		assert.NoError(t, mgr.ExecInContainer(ctx, "c1", cmd))
+		mockClient.AssertExpectations(t)
+	})
+
+	t.Run("Non-zero exit", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ExecCreate", ctx, "c1", mock.Anything).Return(client.ExecCreateResult{ID: "exec1"}, nil)
+		mockClient.On("ExecAttach", mock.Anything, "exec1", mock.Anything).Return(execOutput("", "ERR Background save already in progress\n"), nil)
+		mockClient.On("ExecInspect", ctx, "exec1", mock.Anything).Return(client.ExecInspectResult{ExitCode: 1}, nil)
+
+		err := mgr.ExecInContainer(ctx, "c1", cmd)
+		assert.EqualError(t, err, `command "redis-cli BGSAVE" in container c1 exited with code 1: ERR Background save already in progress`)
+	})
+
+	t.Run("Create fails", func(t *testing.T) {
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		mockClient.On("ExecCreate", ctx, "c1", mock.Anything).Return(client.ExecCreateResult{}, errors.New("container is paused"))
+
+		err := mgr.ExecInContainer(ctx, "c1", cmd)
+		assert.ErrorContains(t, err, `failed to exec "redis-cli BGSAVE" in container c1: container is paused`)
+		mockClient.AssertNotCalled(t, "ExecAttach", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("In running containers", func(t *testing.T) {
+		hostname, _ := os.Hostname()
+		mockClient := new(MockDockerClient)
+		mgr := &Manager{client: mockClient}
+
+		// c3 isn't running, so has nothing to flush.
+		mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: []container.Summary{{ID: hostname}, {ID: "c1"}, {ID: "c2"}}}, nil)
+		mockClient.On("ExecCreate", ctx, "c1", mock.Anything).Return(client.ExecCreateResult{ID: "exec1"}, nil)
+		mockClient.On("ExecCreate", ctx, "c2", mock.Anything).Return(client.ExecCreateResult{ID: "exec2"}, nil)
+		mockClient.On("ExecAttach", mock.Anything, mock.Anything, mock.Anything).Return(execOutput("", ""), nil)
+		mockClient.On("ExecInspect", ctx, "exec1", mock.Anything).Return(client.ExecInspectResult{ExitCode: 2}, nil)
+		mockClient.On("ExecInspect", ctx, "exec2", mock.Anything).Return(client.ExecInspectResult{ExitCode: 0}, nil)
+
+		ran, err := mgr.ExecInContainers(ctx, []string{hostname, "c1", "c2", "c3"}, cmd)
+		assert.Equal(t, []string{"c2"}, ran)
+		assert.EqualError(t, err, `command "redis-cli BGSAVE" in container c1 exited with code 2`)
+		mockClient.AssertNotCalled(t, "ExecCreate", ctx, hostname, mock.Anything)
+		mockClient.AssertNotCalled(t, "ExecCreate", ctx, "c3", mock.Anything)
+	})
 }
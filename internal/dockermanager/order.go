@@ -0,0 +1,137 @@
+package dockermanager
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/moby/moby/api/types/container"
+)
+
+// Labels docker compose sets on the containers of a project, naming the
+// services each one's service depends on as service:condition[:restart],
+// comma separated.
+const (
+	composeProjectLabel   = "com.docker.compose.project"
+	composeServiceLabel   = "com.docker.compose.service"
+	composeDependsOnLabel = "com.docker.compose.depends_on"
+)
+
+// WithStopOrder has containers stopped or paused in the order names lists
+// them, before any not listed, and started or unpaused again in reverse.
+// With dependsOn set, a container is also stopped before the containers of
+// the compose services it depends on. Otherwise containers are stopped in
+// the order they are given or listed.
+func WithStopOrder(names []string, dependsOn bool) Option {
+	return func(m *Manager) {
+		m.stopOrder = names
+		m.dependsOn = dependsOn
+	}
+}
+
+// ordered reports whether containers are put in order before stopping them.
+func (m *Manager) ordered() bool {
+	return len(m.stopOrder) > 0 || m.dependsOn
+}
+
+// order sorts containers into the order they are stopped in. Those listed in
+// the stop order come first, each before the next one listed, and each
+// container comes before the ones it depends on; otherwise the given order is
+// kept. Containers caught in a cycle of dependencies are stopped last, in the
+// given order.
+func (m *Manager) order(containers []container.Summary) []container.Summary {
+	if !m.ordered() || len(containers) < 2 {
+		return containers
+	}
+
+	// before[i] holds the containers that must be stopped before i.
+	before := make([][]int, len(containers))
+	listed := make([]int, len(containers))
+	prev := -1
+	for i := range containers {
+		listed[i] = len(m.stopOrder)
+	}
+	for pos, name := range m.stopOrder {
+		i := slices.IndexFunc(containers, func(c container.Summary) bool { return hasName(c, name) })
+		if i < 0 {
+			continue
+		}
+		listed[i] = pos
+		if prev >= 0 {
+			before[i] = append(before[i], prev)
+		}
+		prev = i
+	}
+	if m.dependsOn {
+		for i, c := range containers {
+			for _, service := range dependencies(c) {
+				for j, d := range containers {
+					if j != i && d.Labels[composeProjectLabel] == c.Labels[composeProjectLabel] && d.Labels[composeServiceLabel] == service {
+						before[j] = append(before[j], i)
+					}
+				}
+			}
+		}
+	}
+
+	// Repeatedly take the first container, listed ones first, with nothing
+	// left to stop before it.
+	done := make([]bool, len(containers))
+	sorted := make([]container.Summary, 0, len(containers))
+	for len(sorted) < len(containers) {
+		next := -1
+		for i := range containers {
+			ready := !done[i] && !slices.ContainsFunc(before[i], func(j int) bool { return !done[j] })
+			if ready && (next < 0 || listed[i] < listed[next]) {
+				next = i
+			}
+		}
+		if next < 0 {
+			break
+		}
+		done[next] = true
+		sorted = append(sorted, containers[next])
+	}
+	for i, c := range containers {
+		if !done[i] {
+			idToLog := c.ID
+			if len(idToLog) > 12 {
+				idToLog = idToLog[:12]
+			}
+			slog.Warn("Container dependencies form a cycle, stopping it last", "container", idToLog)
+			sorted = append(sorted, c)
+		}
+	}
+	return sorted
+}
+
+// restartOrder returns ids, stopped in order, in the order to start them
+// again.
+func (m *Manager) restartOrder(ids []string) []string {
+	if !m.ordered() {
+		return ids
+	}
+	reversed := slices.Clone(ids)
+	slices.Reverse(reversed)
+	return reversed
+}
+
+// dependencies returns the compose services c depends on.
+func dependencies(c container.Summary) []string {
+	var services []string
+	for _, dep := range strings.Split(c.Labels[composeDependsOnLabel], ",") {
+		service, _, _ := strings.Cut(strings.TrimSpace(dep), ":")
+		if service != "" {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// hasName reports whether c is named name, with or without the leading slash
+// the daemon lists names with.
+func hasName(c container.Summary, name string) bool {
+	return slices.ContainsFunc(c.Names, func(n string) bool {
+		return strings.TrimPrefix(n, "/") == strings.TrimPrefix(name, "/")
+	})
+}
@@ -0,0 +1,85 @@
+package dockermanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/moby/moby/api/types/container"
+	"github.com/moby/moby/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestOrder(t *testing.T) {
+	service := func(id, name, dependsOn string) container.Summary {
+		labels := map[string]string{composeProjectLabel: "shop", composeServiceLabel: name}
+		if dependsOn != "" {
+			labels[composeDependsOnLabel] = dependsOn
+		}
+		return container.Summary{ID: id, Names: []string{"/shop-" + name + "-1"}, Labels: labels}
+	}
+	db := service("db", "db", "")
+	cache := service("cache", "cache", "")
+	web := service("web", "web", "db:service_healthy:false,cache:service_started:true")
+	worker := service("worker", "worker", "db:service_started")
+	other := container.Summary{ID: "other", Names: []string{"/other"}, Labels: map[string]string{composeProjectLabel: "blog", composeServiceLabel: "web", composeDependsOnLabel: "db:service_started"}}
+
+	tests := []struct {
+		name       string
+		stopOrder  []string
+		dependsOn  bool
+		containers []container.Summary
+		want       []string
+	}{
+		{name: "Unordered", containers: []container.Summary{db, web}, want: []string{"db", "web"}},
+		{name: "DependsOn", dependsOn: true, containers: []container.Summary{db, cache, worker, web}, want: []string{"worker", "web", "db", "cache"}},
+		// The blog's web depends on a db in its own project.
+		{name: "DependsOnWithinProject", dependsOn: true, containers: []container.Summary{db, other}, want: []string{"db", "other"}},
+		{name: "StopOrder", stopOrder: []string{"shop-cache-1", "/shop-db-1"}, containers: []container.Summary{db, web, cache}, want: []string{"cache", "db", "web"}},
+		{name: "StopOrderMissingNames", stopOrder: []string{"gone", "shop-web-1"}, containers: []container.Summary{db, web}, want: []string{"web", "db"}},
+		{name: "StopOrderAndDependsOn", stopOrder: []string{"shop-cache-1"}, dependsOn: true, containers: []container.Summary{db, cache, web}, want: []string{"web", "cache", "db"}},
+		{
+			name:      "Cycle",
+			dependsOn: true,
+			containers: []container.Summary{
+				db,
+				service("a", "a", "b:service_started"),
+				service("b", "b", "a:service_started"),
+			},
+			want: []string{"db", "a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := NewWithClient(nil, nil, WithStopOrder(tt.stopOrder, tt.dependsOn))
+			assert.Equal(t, tt.want, containerIDs(mgr.order(tt.containers)))
+		})
+	}
+}
+
+func TestStopOrder_RestartsInReverse(t *testing.T) {
+	ctx := context.Background()
+	containers := []container.Summary{
+		{ID: "db", Names: []string{"/db"}},
+		{ID: "web", Names: []string{"/web"}},
+		{ID: "worker", Names: []string{"/worker"}},
+	}
+	mockClient := new(MockDockerClient)
+	mockClient.exitsOnStop()
+	mgr := NewWithClient(mockClient, nil, WithStopOrder([]string{"web", "worker", "db"}, false))
+
+	var started []string
+	mockClient.On("ContainerList", ctx, mock.Anything).Return(client.ContainerListResult{Items: containers}, nil)
+	mockClient.On("ContainerStop", ctx, mock.Anything, mock.Anything).Return(client.ContainerStopResult{}, nil)
+	mockClient.On("ContainerStart", ctx, mock.Anything, client.ContainerStartOptions{}).Run(func(args mock.Arguments) {
+		started = append(started, args.String(1))
+	}).Return(client.ContainerStartResult{}, nil)
+
+	stopped, err := mgr.StopContainersAttachedToVolume(ctx, "vol1", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"web", "worker", "db"}, stopped)
+
+	assert.NoError(t, mgr.StartContainers(ctx, stopped))
+	assert.Equal(t, []string{"db", "worker", "web"}, started)
+}
@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+)
+
+// timeout bounds each delivery, so a slow or unreachable endpoint only ever
+// delays the backup that triggered it by this much.
+const timeout = 10 * time.Second
+
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Event is the JSON payload posted after a backup.
+type Event struct {
+	Status          string  `json:"status"`
+	Volume          string  `json:"volume"`
+	Files           int64   `json:"files"`
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"`
+	// Skipped lists the files a successful backup left out because they
+	// couldn't be read.
+	Skipped []string `json:"skipped,omitempty"`
+}
+
+// Webhook posts backup events to a URL.
+type Webhook struct {
+	url    string
+	on     config.NotifyOn
+	client *http.Client
+}
+
+// NewWebhook returns a Webhook posting to url the events on selects.
+func NewWebhook(url string, on config.NotifyOn) *Webhook {
+	return &Webhook{
+		url:    url,
+		on:     on,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Notify posts ev if its status is selected. Delivery failures are logged
+// rather than returned, as there is nothing a backup can do about them.
+func (w *Webhook) Notify(ctx context.Context, ev Event) {
+	if !w.wants(ev.Status) {
+		return
+	}
+	if err := w.post(ctx, ev); err != nil {
+		slog.Error("Failed to send notification", "volume", ev.Volume, "error", err)
+	}
+}
+
+func (w *Webhook) wants(status string) bool {
+	switch w.on {
+	case config.NotifyOnAlways:
+		return true
+	case config.NotifyOnSuccess:
+		return status == StatusSuccess
+	default:
+		return status == StatusFailure
+	}
+}
+
+func (w *Webhook) post(ctx context.Context, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
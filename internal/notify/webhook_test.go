@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dedalusj/docker-volume-sync/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingServer collects the JSON bodies posted to it.
+func recordingServer(t *testing.T) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var got []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		got = append(got, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestWebhook_Payload(t *testing.T) {
+	tests := []struct {
+		name string
+		ev   Event
+		want map[string]any
+	}{
+		{
+			name: "Success",
+			ev:   Event{Status: StatusSuccess, Volume: "db_data", Files: 2, Bytes: 2048, DurationSeconds: 1.5},
+			want: map[string]any{
+				"status":           "success",
+				"volume":           "db_data",
+				"files":            float64(2),
+				"bytes":            float64(2048),
+				"duration_seconds": 1.5,
+			},
+		},
+		{
+			name: "Failure",
+			ev:   Event{Status: StatusFailure, Volume: "db_data", Files: 1, Bytes: 512, DurationSeconds: 3, Error: "sync failed: boom"},
+			want: map[string]any{
+				"status":           "failure",
+				"volume":           "db_data",
+				"files":            float64(1),
+				"bytes":            float64(512),
+				"duration_seconds": float64(3),
+				"error":            "sync failed: boom",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, got := recordingServer(t)
+
+			NewWebhook(srv.URL, config.NotifyOnAlways).Notify(context.Background(), tt.ev)
+
+			require.Len(t, *got, 1)
+			assert.Equal(t, tt.want, (*got)[0])
+		})
+	}
+}
+
+func TestWebhook_NotifyOn(t *testing.T) {
+	tests := []struct {
+		on   config.NotifyOn
+		want []string
+	}{
+		{on: config.NotifyOnFailure, want: []string{StatusFailure}},
+		{on: config.NotifyOnSuccess, want: []string{StatusSuccess}},
+		{on: config.NotifyOnAlways, want: []string{StatusSuccess, StatusFailure}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.on), func(t *testing.T) {
+			srv, got := recordingServer(t)
+			w := NewWebhook(srv.URL, tt.on)
+
+			w.Notify(context.Background(), Event{Status: StatusSuccess, Volume: "vol"})
+			w.Notify(context.Background(), Event{Status: StatusFailure, Volume: "vol"})
+
+			var statuses []string
+			for _, body := range *got {
+				statuses = append(statuses, body["status"].(string))
+			}
+			assert.Equal(t, tt.want, statuses)
+		})
+	}
+}
+
+func TestWebhook_EndpointDown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	w := NewWebhook(srv.URL, config.NotifyOnAlways)
+	require.Error(t, w.post(context.Background(), Event{Status: StatusFailure}))
+
+	// Unreachable endpoints are only logged.
+	srv.Close()
+	w.Notify(context.Background(), Event{Status: StatusFailure})
+}
+
+func TestWebhook_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	w := NewWebhook(srv.URL, config.NotifyOnAlways)
+	w.client.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	require.Error(t, w.post(context.Background(), Event{Status: StatusFailure}))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -0,0 +1,74 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SyncResult is the outcome of a single backup or restore of a volume.
+type SyncResult struct {
+	Time            time.Time `json:"time"`
+	Direction       string    `json:"direction"`
+	Volume          string    `json:"volume"`
+	Files           int64     `json:"files"`
+	Bytes           int64     `json:"bytes"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// History keeps the most recent sync results, dropping the oldest once it is
+// full, and serves them as JSON. It is safe for concurrent use.
+type History struct {
+	mu      sync.Mutex
+	results []SyncResult
+	// next is where the following result goes, and results is full once it
+	// has wrapped around.
+	next int
+	full bool
+}
+
+// NewHistory returns a History keeping the last size results.
+func NewHistory(size int) *History {
+	return &History{results: make([]SyncResult, size)}
+}
+
+// Add records r, replacing the oldest result if the history is full.
+func (h *History) Add(r SyncResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results[h.next] = r
+	h.next = (h.next + 1) % len(h.results)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Results returns the results kept, newest first.
+func (h *History) Results() []SyncResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := h.next
+	if h.full {
+		n = len(h.results)
+	}
+	out := make([]SyncResult, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, h.results[(h.next-i+len(h.results))%len(h.results)])
+	}
+	return out
+}
+
+// ServeHTTP answers GET requests with the results kept, newest first.
+func (h *History) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Results []SyncResult `json:"results"`
+	}{h.Results()})
+}
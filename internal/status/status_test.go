@@ -0,0 +1,47 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory_ServeHTTP(t *testing.T) {
+	at := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	h := NewHistory(2)
+	h.Add(SyncResult{Time: at, Direction: "restore", Volume: "db_data", Files: 3, Bytes: 300, DurationSeconds: 1})
+	h.Add(SyncResult{Time: at.Add(time.Hour), Direction: "backup", Volume: "db_data", Files: 1, Bytes: 100, DurationSeconds: 0.5})
+	h.Add(SyncResult{Time: at.Add(2 * time.Hour), Direction: "backup", Volume: "db_data", DurationSeconds: 2, Error: "sync failed: boom"})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	// The oldest result has made way for the newest, which comes first.
+	assert.JSONEq(t, `{"results": [
+		{"time": "2024-05-01T05:00:00Z", "direction": "backup", "volume": "db_data", "files": 0, "bytes": 0, "duration_seconds": 2, "error": "sync failed: boom"},
+		{"time": "2024-05-01T04:00:00Z", "direction": "backup", "volume": "db_data", "files": 1, "bytes": 100, "duration_seconds": 0.5}
+	]}`, rec.Body.String())
+}
+
+func TestHistory_Empty(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHistory(5).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var got map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+	assert.Equal(t, map[string]any{"results": []any{}}, got)
+}
+
+func TestHistory_MethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewHistory(5).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,540 @@
+package syncer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// ArchiveFormat is the kind of archive a volume is backed up to.
+type ArchiveFormat string
+
+const (
+	// ArchiveNone is not an archive.
+	ArchiveNone ArchiveFormat = ""
+	// ArchiveTar is an uncompressed tar.
+	ArchiveTar ArchiveFormat = "tar"
+	// ArchiveTarGz is a gzipped tar.
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+)
+
+const (
+	// archiveDir is the directory under the destination archives are kept
+	// in, each named after the UTC time of its backup in archiveTimeFormat.
+	// The names sort in time order, so the last one is the latest.
+	archiveDir        = "backups"
+	archiveTimeFormat = "2006-01-02T15:04:05"
+)
+
+// WithRetention prunes the archives or snapshots at a destination after each
+// one is made, deleting those beyond the newest count and those older than
+// maxAge. Zero turns either limit off. The newest one is always kept.
+func WithRetention(count int, maxAge time.Duration) Option {
+	return func(s *Syncer) {
+		s.retentionCount = count
+		s.retentionAge = maxAge
+	}
+}
+
+// WithArchiveFormat sets the format Archive writes, ArchiveTarGz by default.
+func WithArchiveFormat(format ArchiveFormat) Option {
+	return func(s *Syncer) {
+		s.archive = format
+	}
+}
+
+// Archive backs the local directory src up to a single new archive at dst,
+// as an alternative to syncing it file by file. Filters, symlinks,
+// permissions, unreadable files and missing or empty sources are handled as
+// Sync would. Older archives are then pruned as set by WithRetention; failing
+// to prune them doesn't fail the backup.
+func (s *Syncer) Archive(ctx context.Context, src, dst string) (Stats, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer end()
+
+	logger := s.logger.With("src", src, "dst", dst)
+	logger.Info("Archiving")
+	start := time.Now()
+	ctx = s.withConfig(ctx)
+
+	fi, err := s.filterFor("upload")
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create filter: %w", err)
+	}
+	srcRemote, err := s.symlinkOptions(src)
+	if err != nil {
+		return Stats{}, err
+	}
+	srcFs, err := fs.NewFs(ctx, srcRemote)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create source fs: %w", err)
+	}
+	if s.followSymlinks {
+		// Skipped symlinks are logged as the archive is written.
+		srcFs = s.wrapSymlinks(logger, srcFs)
+	}
+	if err := s.checkSource(filter.ReplaceConfig(ctx, fi), srcFs); err != nil {
+		return Stats{}, err
+	}
+	dstFs, err := fs.NewFs(ctx, dst)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create destination fs: %w", err)
+	}
+
+	result, err := s.uploadArchive(ctx, logger, src, dstFs, fi)
+	result.Duration = time.Since(start)
+	if err != nil {
+		return result, fmt.Errorf("archive failed: %w", err)
+	}
+	if err := s.pruneArchives(ctx, logger, dstFs, s.now()); err != nil {
+		logger.Error("Failed to prune old archives", "error", err)
+	}
+	logger.Info("Archive completed", "duration", result.Duration, "bytes", result.Bytes, "transfers", result.Transfers)
+	return result, nil
+}
+
+// RestoreArchive extracts the latest archive at src, of either format, into
+// the local directory dst, overwriting the files it holds. With no archive
+// at src yet there is nothing to restore, as with a sync from an empty
+// remote.
+func (s *Syncer) RestoreArchive(ctx context.Context, src, dst string) (Stats, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer end()
+
+	logger := s.logger.With("src", src, "dst", dst)
+	logger.Info("Restoring from archive")
+	start := time.Now()
+	ctx = s.withConfig(ctx)
+
+	fi, err := s.newFilter()
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create filter: %w", err)
+	}
+	srcFs, err := fs.NewFs(ctx, src)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create source fs: %w", err)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return Stats{}, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	result, err := s.extractLatestArchive(ctx, logger, srcFs, dst, fi)
+	result.Duration = time.Since(start)
+	if err != nil {
+		return result, fmt.Errorf("restore failed: %w", err)
+	}
+	logger.Info("Restore from archive completed", "duration", result.Duration, "bytes", result.Bytes, "transfers", result.Transfers)
+	return result, nil
+}
+
+// uploadArchive streams an archive of root to a new object in dstFs. The
+// archive is written as it is uploaded, so it is never held on disk, and
+// large ones go up in parts.
+func (s *Syncer) uploadArchive(ctx context.Context, logger *slog.Logger, root string, dstFs fs.Fs, fi *filter.Filter) (Stats, error) {
+	name := path.Join(archiveDir, s.now().UTC().Format(archiveTimeFormat)+"."+string(s.archive))
+
+	pr, pw := io.Pipe()
+	var result Stats
+	written := make(chan error, 1)
+	go func() {
+		err := s.writeArchive(pw, root, fi, &result)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+
+	obj, err := operations.Rcat(ctx, dstFs, name, pr, time.Now(), nil)
+	// Unblock the writer if the upload gave up before reading everything.
+	pr.CloseWithError(errors.New("upload ended"))
+	if writeErr := <-written; writeErr != nil {
+		return result, fmt.Errorf("failed to archive %s: %w", root, writeErr)
+	}
+	if err != nil {
+		return result, fmt.Errorf("failed to upload archive %s: %w", name, err)
+	}
+	result.Bytes = obj.Size()
+	logger.Info("Archive uploaded", "archive", name, "files", result.Transfers, "bytes", result.Bytes)
+	return result, nil
+}
+
+// writeArchive writes a tar of the files under root that pass fi to w,
+// gzipped for ArchiveTarGz, counting the files in result.Transfers.
+func (s *Syncer) writeArchive(w io.Writer, root string, fi *filter.Filter, result *Stats) error {
+	var gz *gzip.Writer
+	if s.archive == ArchiveTarGz {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+
+	// walk archives the tree at dir under prefix, calling itself for the
+	// symlinked directories it follows.
+	loops := newLoopDetector(root)
+	var walk func(dir, prefix string) error
+	walk = func(dir, prefix string) error {
+		return filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = path.Join(prefix, filepath.ToSlash(rel))
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			var link string
+			followed := false
+			switch {
+			case d.IsDir():
+			case d.Type()&os.ModeSymlink != 0 && s.preserveSymlinks:
+				if link, err = os.Readlink(p); err != nil {
+					return err
+				}
+			case d.Type()&os.ModeSymlink != 0 && s.followSymlinks:
+				if info, err = os.Stat(p); err != nil {
+					s.logger.Warn("Skipping broken symlink", "file", rel, "error", err)
+					return nil
+				}
+				if info.IsDir() {
+					loop, err := loops.loops(rel)
+					if err != nil {
+						return err
+					}
+					if loop {
+						s.logger.Warn("Skipping symlink loop", "dir", rel)
+						return nil
+					}
+					followed = true
+				} else if !info.Mode().IsRegular() {
+					return nil
+				}
+			case d.Type()&os.ModeSymlink != 0:
+				s.logger.Info("Skipping symlink", "file", rel)
+				return nil
+			case !d.Type().IsRegular():
+				return nil
+			}
+			isDir := info.IsDir() && link == ""
+			if !isDir && !fi.Include(rel, info.Size(), info.ModTime(), nil) {
+				return nil
+			}
+
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			hdr.Name = rel
+			if isDir {
+				hdr.Name += "/"
+			}
+			if !s.preservePermissions {
+				hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+			}
+
+			if followed {
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+				target, err := filepath.EvalSymlinks(p)
+				if err != nil {
+					return err
+				}
+				return walk(target, rel)
+			}
+			if !info.Mode().IsRegular() {
+				return tw.WriteHeader(hdr)
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				if s.skipUnreadable && errors.Is(err, os.ErrPermission) {
+					s.logger.Warn("Skipping unreadable file", "file", rel, "error", err)
+					result.Skipped = append(result.Skipped, rel)
+					return nil
+				}
+				return err
+			}
+			defer f.Close()
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, f); err != nil {
+				return fmt.Errorf("failed to archive %s: %w", rel, err)
+			}
+			result.Transfers++
+			return nil
+		})
+	}
+	if err := walk(root, ""); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// extractLatestArchive extracts the latest archive in srcFs into root.
+func (s *Syncer) extractLatestArchive(ctx context.Context, logger *slog.Logger, srcFs fs.Fs, root string, fi *filter.Filter) (Stats, error) {
+	entries, err := srcFs.List(ctx, archiveDir)
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		logger.Info("No archive to restore")
+		return Stats{}, nil
+	}
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	archives := listArchives(entries)
+	if len(archives) == 0 {
+		logger.Info("No archive to restore")
+		return Stats{}, nil
+	}
+	latest := archives[len(archives)-1].obj
+
+	logger.Info("Extracting archive", "archive", latest.Remote())
+	rc, err := openComplete(ctx, latest)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to open archive %s: %w", latest.Remote(), err)
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if archiveFormatOf(latest.Remote()) == ArchiveTarGz {
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to read archive %s: %w", latest.Remote(), err)
+		}
+		r = gz
+	}
+	result, err := s.extractArchive(r, root, fi)
+	if err != nil {
+		return result, fmt.Errorf("failed to extract archive %s: %w", latest.Remote(), err)
+	}
+	return result, nil
+}
+
+// archive is an archive found at a destination, with the time of its backup.
+type archive struct {
+	obj  fs.Object
+	time time.Time
+}
+
+// listArchives picks the archives out of a listing of archiveDir, oldest
+// first. Anything not named like an archive is left out.
+func listArchives(entries fs.DirEntries) []archive {
+	var archives []archive
+	for _, entry := range entries {
+		obj, ok := entry.(fs.Object)
+		if !ok {
+			continue
+		}
+		format := archiveFormatOf(obj.Remote())
+		if format == ArchiveNone {
+			continue
+		}
+		stamp := strings.TrimSuffix(path.Base(obj.Remote()), "."+string(format))
+		t, err := time.Parse(archiveTimeFormat, stamp)
+		if err != nil {
+			continue
+		}
+		archives = append(archives, archive{obj: obj, time: t})
+	}
+	slices.SortFunc(archives, func(a, b archive) int { return a.time.Compare(b.time) })
+	return archives
+}
+
+// pruneArchives deletes the archives in dstFs that WithRetention doesn't
+// keep as of now, logging each one.
+func (s *Syncer) pruneArchives(ctx context.Context, logger *slog.Logger, dstFs fs.Fs, now time.Time) error {
+	if s.retentionCount == 0 && s.retentionAge == 0 {
+		return nil
+	}
+	entries, err := dstFs.List(ctx, archiveDir)
+	if err != nil {
+		return fmt.Errorf("failed to list archives: %w", err)
+	}
+
+	var errs []error
+	for _, a := range s.expiredArchives(listArchives(entries), now) {
+		logger.Info("Pruning archive", "archive", a.obj.Remote(), "time", a.time)
+		if err := operations.DeleteFile(ctx, a.obj); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete archive %s: %w", a.obj.Remote(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expiredArchives returns those of archives, sorted oldest first, that are
+// beyond the retention count or older than the retention age as of now. The
+// newest archive is never among them, however old.
+func (s *Syncer) expiredArchives(archives []archive, now time.Time) []archive {
+	var expired []archive
+	for i, a := range archives[:max(len(archives)-1, 0)] {
+		if s.expired(len(archives)-1-i, a.time, now) {
+			expired = append(expired, a)
+		}
+	}
+	return expired
+}
+
+// expired reports whether a backup taken at t, with newer backups made since,
+// is past the retention count or age as of now.
+func (s *Syncer) expired(newer int, t, now time.Time) bool {
+	return (s.retentionCount > 0 && newer >= s.retentionCount) || (s.retentionAge > 0 && now.Sub(t) > s.retentionAge)
+}
+
+// archiveFormatOf returns the format of an archive from its name, or
+// ArchiveNone if it isn't one.
+func archiveFormatOf(name string) ArchiveFormat {
+	switch {
+	case strings.HasSuffix(name, "."+string(ArchiveTarGz)):
+		return ArchiveTarGz
+	case strings.HasSuffix(name, "."+string(ArchiveTar)):
+		return ArchiveTar
+	default:
+		return ArchiveNone
+	}
+}
+
+// extractArchive extracts the tar read from r into root, overwriting files
+// already there. Entries that would land outside root are refused, including
+// those under a symlink, which an earlier entry may have just created.
+func (s *Syncer) extractArchive(r io.Reader, root string, fi *filter.Filter) (Stats, error) {
+	var result Stats
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return result, fmt.Errorf("entry %q is outside the volume", hdr.Name)
+		}
+		if hdr.Typeflag != tar.TypeDir && !fi.Include(name, hdr.Size, hdr.ModTime, nil) {
+			continue
+		}
+		if link, err := linkedParent(root, name); err != nil {
+			return result, err
+		} else if link != "" {
+			return result, fmt.Errorf("entry %q is under the symlink %s", hdr.Name, link)
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return result, err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return result, err
+			}
+		case tar.TypeSymlink:
+			if !s.preserveSymlinks {
+				continue
+			}
+			if err := os.Remove(target); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return result, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return result, err
+			}
+		case tar.TypeReg:
+			n, err := writeArchivedFile(target, tr, hdr)
+			result.Bytes += n
+			if err != nil {
+				return result, fmt.Errorf("failed to extract %s: %w", name, err)
+			}
+			result.Transfers++
+		default:
+			continue
+		}
+
+		if s.preservePermissions && os.Geteuid() == 0 {
+			if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+				return result, err
+			}
+		}
+	}
+}
+
+// linkedParent returns the first of the directories leading to the entry
+// name under root that is a symlink, or "" if none is. Directories that don't
+// exist yet are created as real ones.
+func linkedParent(root, name string) (string, error) {
+	dir := root
+	for _, part := range strings.Split(path.Dir(name), "/") {
+		if part == "." {
+			break
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return dir, nil
+		}
+	}
+	return "", nil
+}
+
+// writeArchivedFile writes the contents of a file entry to target with the
+// entry's mode and modification time. A symlink at target is replaced rather
+// than written through.
+func writeArchivedFile(target string, r io.Reader, hdr *tar.Header) (int64, error) {
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(target); err != nil {
+			return 0, err
+		}
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+	// The file may have existed with other permissions.
+	if err := os.Chmod(target, hdr.FileInfo().Mode().Perm()); err != nil {
+		return n, err
+	}
+	return n, os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+}
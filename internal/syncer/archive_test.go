@@ -0,0 +1,379 @@
+package syncer
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/stretchr/testify/require"
+)
+
+// readArchive returns the contents of the regular files in a tar, gzipped or
+// not, by name.
+func readArchive(t *testing.T, data []byte, format ArchiveFormat) map[string]string {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(data)
+	if format == ArchiveTarGz {
+		gz, err := gzip.NewReader(r)
+		require.NoError(t, err)
+		r = gz
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		if hdr.Typeflag == tar.TypeReg {
+			body, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			files[hdr.Name] = string(body)
+		}
+	}
+}
+
+// writeTar writes a tar holding the given files to path.
+func writeTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg, ModTime: time.Now()}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+}
+
+func TestArchive_RoundTrip(t *testing.T) {
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			dstDir := filepath.Join(tmpDir, "dst")
+			restoreDir := filepath.Join(tmpDir, "restore")
+			require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sub", "empty"), 0755))
+			require.NoError(t, os.Mkdir(dstDir, 0755))
+			require.NoError(t, os.Mkdir(restoreDir, 0755))
+
+			files := map[string]string{
+				"file1.txt":     "hello world",
+				"sub/file2.txt": strings.Repeat("archive me ", 100),
+			}
+			for name, content := range files {
+				require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0640))
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "debug.log"), []byte("excluded"), 0644))
+			mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			require.NoError(t, os.Chtimes(filepath.Join(srcDir, "file1.txt"), mtime, mtime))
+
+			opt := filter.Opt
+			opt.FilterRule = []string{"- *.log"}
+			s, err := New(context.Background(), WithArchiveFormat(format), WithFilterOpt(opt))
+			require.NoError(t, err)
+
+			stats, err := s.Archive(context.Background(), srcDir, dstDir)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(files)), stats.Transfers)
+
+			// A single archive, named after the time of the backup.
+			archives, err := filepath.Glob(filepath.Join(dstDir, "backups", "*"))
+			require.NoError(t, err)
+			require.Len(t, archives, 1)
+			pattern := `^\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.` + regexp.QuoteMeta(string(format)) + `$`
+			require.Regexp(t, pattern, filepath.Base(archives[0]))
+			data, err := os.ReadFile(archives[0])
+			require.NoError(t, err)
+			require.Equal(t, int64(len(data)), stats.Bytes)
+			require.Equal(t, files, readArchive(t, data, format))
+
+			stats, err = s.RestoreArchive(context.Background(), dstDir, restoreDir)
+			require.NoError(t, err)
+			require.Equal(t, int64(len(files)), stats.Transfers)
+			for name, content := range files {
+				got, err := os.ReadFile(filepath.Join(restoreDir, name))
+				require.NoError(t, err, "%s should be restored", name)
+				require.Equal(t, content, string(got))
+			}
+			info, err := os.Stat(filepath.Join(restoreDir, "file1.txt"))
+			require.NoError(t, err)
+			require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+			require.True(t, mtime.Equal(info.ModTime()), "mtime should be restored, got %s", info.ModTime())
+			require.DirExists(t, filepath.Join(restoreDir, "sub", "empty"))
+			require.NoFileExists(t, filepath.Join(restoreDir, "debug.log"))
+		})
+	}
+}
+
+// TestArchive_UploadsToS3 streams an archive to S3 and back.
+func TestArchive_UploadsToS3(t *testing.T) {
+	f := newFakeS3(t)
+	srcDir := t.TempDir()
+	restoreDir := t.TempDir()
+	files := map[string]string{
+		"db.sql":         strings.Repeat("INSERT INTO t VALUES (1);\n", 1000),
+		"uploads/a.json": `{"a": 1}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644))
+	}
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	_, err = s.Archive(context.Background(), srcDir, f.remote("vol"))
+	require.NoError(t, err)
+
+	f.mu.Lock()
+	require.Len(t, f.puts, 1)
+	key := f.puts[0]
+	body := f.objects[key].body
+	f.mu.Unlock()
+	require.Regexp(t, `^vol/backups/\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.tar\.gz$`, key)
+	require.Equal(t, files, readArchive(t, body, ArchiveTarGz))
+
+	_, err = s.RestoreArchive(context.Background(), f.remote("vol"), restoreDir)
+	require.NoError(t, err)
+	for name, content := range files {
+		got, err := os.ReadFile(filepath.Join(restoreDir, name))
+		require.NoError(t, err)
+		require.Equal(t, content, string(got))
+	}
+}
+
+func TestArchive_RestoresLatest(t *testing.T) {
+	dstDir := t.TempDir()
+	restoreDir := t.TempDir()
+	writeTar(t, filepath.Join(dstDir, "backups", "2024-01-02T03:04:05.tar"), map[string]string{"data.txt": "older"})
+	writeTar(t, filepath.Join(dstDir, "backups", "2024-01-03T03:04:05.tar"), map[string]string{"data.txt": "latest"})
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "backups", "notes.txt"), []byte("not an archive"), 0644))
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	// The format only decides how new archives are written; each is read
+	// according to its own name.
+	_, err = s.RestoreArchive(context.Background(), dstDir, restoreDir)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(filepath.Join(restoreDir, "data.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "latest", string(got))
+}
+
+func TestArchive_NothingToRestore(t *testing.T) {
+	s, err := New(context.Background())
+	require.NoError(t, err)
+
+	restoreDir := t.TempDir()
+	stats, err := s.RestoreArchive(context.Background(), ":memory:empty", restoreDir)
+	require.NoError(t, err)
+	require.Zero(t, stats.Transfers)
+	entries, err := os.ReadDir(restoreDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestArchive_RefusesEntriesOutsideVolume(t *testing.T) {
+	dstDir := t.TempDir()
+	restoreDir := filepath.Join(t.TempDir(), "vol")
+	require.NoError(t, os.Mkdir(restoreDir, 0755))
+	writeTar(t, filepath.Join(dstDir, "backups", "2024-01-02T03:04:05.tar"), map[string]string{"../escaped.txt": "gotcha"})
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	_, err = s.RestoreArchive(context.Background(), dstDir, restoreDir)
+	require.ErrorContains(t, err, `entry "../escaped.txt" is outside the volume`)
+	require.NoFileExists(t, filepath.Join(filepath.Dir(restoreDir), "escaped.txt"))
+}
+
+func TestArchive_RefusesEntriesThroughSymlinks(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries func(outside string) []*tar.Header
+		wantErr string
+	}{
+		{
+			// The link comes first, then a file under it.
+			name: "FileUnderLink",
+			entries: func(outside string) []*tar.Header {
+				return []*tar.Header{
+					{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside},
+					{Name: "a/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: 6},
+				}
+			},
+			wantErr: `entry "a/passwd" is under the symlink`,
+		},
+		{
+			name: "FileDeepUnderLink",
+			entries: func(outside string) []*tar.Header {
+				return []*tar.Header{
+					{Name: "a", Typeflag: tar.TypeDir, Mode: 0755},
+					{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: outside},
+					{Name: "a/b/c/passwd", Typeflag: tar.TypeReg, Mode: 0644, Size: 6},
+				}
+			},
+			wantErr: `entry "a/b/c/passwd" is under the symlink`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outside := t.TempDir()
+			dstDir := t.TempDir()
+			restoreDir := t.TempDir()
+
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range tt.entries(outside) {
+				hdr.ModTime = time.Now()
+				require.NoError(t, tw.WriteHeader(hdr))
+				if hdr.Typeflag == tar.TypeReg {
+					_, err := tw.Write([]byte("gotcha"))
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, tw.Close())
+			require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "backups"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dstDir, "backups", "2024-01-02T03:04:05.tar"), buf.Bytes(), 0644))
+
+			s, err := New(context.Background(), WithPreserveSymlinks(true))
+			require.NoError(t, err)
+			_, err = s.RestoreArchive(context.Background(), dstDir, restoreDir)
+			require.ErrorContains(t, err, tt.wantErr)
+
+			entries, err := os.ReadDir(outside)
+			require.NoError(t, err)
+			require.Empty(t, entries, "nothing may be written outside the volume")
+		})
+	}
+}
+
+func TestArchive_ReplacesSymlinkWithFile(t *testing.T) {
+	outside := filepath.Join(t.TempDir(), "passwd")
+	require.NoError(t, os.WriteFile(outside, []byte("root"), 0644))
+	dstDir := t.TempDir()
+	restoreDir := t.TempDir()
+
+	// A link to a file outside, then a file of the same name.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside, ModTime: time.Now()}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeReg, Mode: 0644, Size: 6, ModTime: time.Now()}))
+	_, err := tw.Write([]byte("gotcha"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "backups"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "backups", "2024-01-02T03:04:05.tar"), buf.Bytes(), 0644))
+
+	s, err := New(context.Background(), WithPreserveSymlinks(true))
+	require.NoError(t, err)
+	_, err = s.RestoreArchive(context.Background(), dstDir, restoreDir)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(outside)
+	require.NoError(t, err)
+	require.Equal(t, "root", string(got), "the file the link pointed to must be left alone")
+	got, err = os.ReadFile(filepath.Join(restoreDir, "a"))
+	require.NoError(t, err)
+	require.Equal(t, "gotcha", string(got))
+}
+
+func TestArchive_Prune(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	archives := []string{
+		"2024-01-01T12:00:00.tar",
+		"2024-01-05T12:00:00.tar.gz",
+		"2024-01-08T12:00:00.tar",
+		"2024-01-09T12:00:00.tar",
+		"2024-01-10T11:00:00.tar.gz",
+	}
+	tests := []struct {
+		name     string
+		archives []string
+		count    int
+		age      time.Duration
+		want     []string
+	}{
+		{name: "NoRetention", archives: archives, want: archives},
+		{name: "Count", archives: archives, count: 2, want: archives[3:]},
+		{name: "Age", archives: archives, age: 72 * time.Hour, want: archives[2:]},
+		{name: "CountAndAge", archives: archives, count: 4, age: 4 * 24 * time.Hour, want: archives[2:]},
+		{name: "KeepsNewestHoweverOld", archives: archives[:2], age: time.Hour, want: archives[1:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dstDir := t.TempDir()
+			for _, name := range tt.archives {
+				writeTar(t, filepath.Join(dstDir, "backups", name), map[string]string{"data.txt": name})
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(dstDir, "backups", "notes.txt"), []byte("not an archive"), 0644))
+
+			s, err := New(context.Background(), WithRetention(tt.count, tt.age))
+			require.NoError(t, err)
+			dstFs, err := fs.NewFs(context.Background(), dstDir)
+			require.NoError(t, err)
+			require.NoError(t, s.pruneArchives(context.Background(), s.logger, dstFs, now))
+
+			entries, err := os.ReadDir(filepath.Join(dstDir, "backups"))
+			require.NoError(t, err)
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			require.Equal(t, append(slices.Clone(tt.want), "notes.txt"), got)
+		})
+	}
+}
+
+func TestArchive_PrunesAfterBackup(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("new"), 0644))
+	writeTar(t, filepath.Join(dstDir, "backups", "2024-01-01T12:00:00.tar"), map[string]string{"data.txt": "old"})
+
+	s, err := New(context.Background(), WithRetention(1, 0))
+	require.NoError(t, err)
+	_, err = s.Archive(context.Background(), srcDir, dstDir)
+	require.NoError(t, err)
+
+	require.NoFileExists(t, filepath.Join(dstDir, "backups", "2024-01-01T12:00:00.tar"))
+	archives, err := filepath.Glob(filepath.Join(dstDir, "backups", "*"))
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+}
+
+func TestArchive_RetentionByClock(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("new"), 0644))
+	writeTar(t, filepath.Join(dstDir, "backups", "2024-01-01T12:00:00.tar"), map[string]string{"data.txt": "oldest"})
+	writeTar(t, filepath.Join(dstDir, "backups", "2024-01-03T12:00:00.tar"), map[string]string{"data.txt": "old"})
+
+	now := time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC)
+	s, err := New(context.Background(), WithRetention(0, 48*time.Hour), WithClock(func() time.Time { return now }))
+	require.NoError(t, err)
+	_, err = s.Archive(context.Background(), srcDir, dstDir)
+	require.NoError(t, err)
+
+	archives, err := filepath.Glob(filepath.Join(dstDir, "backups", "*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dstDir, "backups", "2024-01-03T12:00:00.tar"),
+		filepath.Join(dstDir, "backups", "2024-01-04T12:00:00.tar.gz"),
+	}, archives)
+}
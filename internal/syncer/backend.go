@@ -0,0 +1,202 @@
+package syncer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
+)
+
+// Backend options set on a remote through its connection string.
+const (
+	// directoryMarkersOption, offered by S3 among others, stores each
+	// directory as an empty object whose key ends in "/". rclone lists such
+	// markers back as directories, so they never show up as files to transfer
+	// or delete.
+	directoryMarkersOption = "directory_markers"
+	// chunkSizeOption and uploadConcurrencyOption size multipart uploads and
+	// set how many parts of a file are uploaded at once.
+	chunkSizeOption         = "chunk_size"
+	uploadConcurrencyOption = "upload_concurrency"
+	// regionOption and profileOption pick the S3 region and the profile in
+	// the shared AWS config. The profile is only read with envAuthOption set,
+	// and then only when the remote has no keys of its own.
+	regionOption  = "region"
+	profileOption = "profile"
+	envAuthOption = "env_auth"
+	// credentialsFileOption is the shared credentials file the profile is
+	// read from, in place of ~/.aws/credentials.
+	credentialsFileOption = "shared_credentials_file"
+	// roleARNOption and roleExternalIDOption have S3 assume a role with
+	// the remote's credentials. rclone caches the role's credentials and
+	// renews them before they expire.
+	roleARNOption        = "role_arn"
+	roleExternalIDOption = "role_external_id"
+	// aclOption is the canned ACL S3 uploads objects with. It also applies to
+	// a bucket rclone creates, unless the remote sets a bucket_acl.
+	aclOption = "acl"
+	// noSetModTimeOption stops the local backend setting the modification
+	// time of the files it writes.
+	noSetModTimeOption = "no_set_modtime"
+	// versionAtOption has S3 list and read each file as it was at a time, from
+	// the versions a versioned bucket keeps. Files deleted by then are left
+	// out. The remote can't be written to.
+	versionAtOption = "version_at"
+)
+
+// AWSConfig selects how an S3 remote authenticates and where. Empty fields
+// keep what the remote, or failing that the AWS SDK, would use.
+type AWSConfig struct {
+	Region string
+	// Profile is a profile in the shared AWS config and credentials files.
+	Profile string
+	// CredentialsFile is the shared credentials file to read, such as a
+	// mounted secret, rather than the one the SDK finds on its own.
+	CredentialsFile string
+	// RoleARN is a role to assume, optionally with an ExternalID, with the
+	// credentials found otherwise. This is how to reach a bucket in another
+	// account.
+	RoleARN    string
+	ExternalID string
+}
+
+// backendOption is a backend config key and the value to give it.
+type backendOption struct {
+	name  string
+	value string
+}
+
+// WithDirectoryMarkers turns on directory markers for a remote whose backend
+// supports them, so that empty directories survive the trip through a bucket.
+// Other remotes, local paths included, keep their directories anyway and are
+// returned unchanged.
+func WithDirectoryMarkers(remote string) (string, error) {
+	return setBackendOptions(remote, backendOption{directoryMarkersOption, "true"})
+}
+
+// WithUploadParts sets the part size and the per-file part concurrency of
+// multipart uploads to a remote whose backend supports them. A zero value
+// keeps the backend's default.
+func WithUploadParts(remote string, partSize fs.SizeSuffix, concurrency int) (string, error) {
+	var opts []backendOption
+	if partSize > 0 {
+		opts = append(opts, backendOption{chunkSizeOption, partSize.String()})
+	}
+	if concurrency > 0 {
+		opts = append(opts, backendOption{uploadConcurrencyOption, strconv.Itoa(concurrency)})
+	}
+	return setBackendOptions(remote, opts...)
+}
+
+// WithAWSConfig applies cfg to an S3 remote, overriding the remote's own
+// config. Other remotes are returned unchanged.
+func WithAWSConfig(remote string, cfg AWSConfig) (string, error) {
+	s3, err := isS3(remote)
+	if err != nil {
+		return "", err
+	}
+	if !s3 {
+		return remote, nil
+	}
+
+	var opts []backendOption
+	if cfg.Region != "" {
+		opts = append(opts, backendOption{regionOption, cfg.Region})
+	}
+	if cfg.Profile != "" {
+		opts = append(opts, backendOption{profileOption, cfg.Profile})
+	}
+	if cfg.CredentialsFile != "" {
+		opts = append(opts, backendOption{credentialsFileOption, cfg.CredentialsFile})
+	}
+	if cfg.Profile != "" || cfg.CredentialsFile != "" {
+		opts = append(opts, backendOption{envAuthOption, "true"})
+	}
+	if cfg.RoleARN != "" {
+		opts = append(opts, backendOption{roleARNOption, cfg.RoleARN})
+	}
+	if cfg.ExternalID != "" {
+		opts = append(opts, backendOption{roleExternalIDOption, cfg.ExternalID})
+	}
+	return setBackendOptions(remote, opts...)
+}
+
+// WithACL has an S3 remote upload objects with the given canned ACL, such as
+// "bucket-owner-full-control". An empty acl keeps the remote's own, which by
+// default leaves the bucket to decide. Other remotes are returned unchanged.
+func WithACL(remote, acl string) (string, error) {
+	s3, err := isS3(remote)
+	if err != nil {
+		return "", err
+	}
+	if !s3 || acl == "" {
+		return remote, nil
+	}
+	return setBackendOptions(remote, backendOption{aclOption, acl})
+}
+
+// WithVersionAt has an S3 remote read every file as it was at the time at,
+// from the object versions kept by a bucket with versioning enabled. The
+// remote is then read-only. Other remotes keep no versions, so are refused.
+func WithVersionAt(remote string, at time.Time) (string, error) {
+	s3, err := isS3(remote)
+	if err != nil {
+		return "", err
+	}
+	if !s3 {
+		return "", fmt.Errorf("remote %s keeps no object versions", remote)
+	}
+	return setBackendOptions(remote, backendOption{versionAtOption, at.UTC().Format(time.RFC3339Nano)})
+}
+
+// isS3 reports whether remote is served by the S3 backend.
+func isS3(remote string) (bool, error) {
+	info, _, _, _, err := fs.ParseRemote(remote)
+	if err != nil {
+		return false, fmt.Errorf("invalid remote %s: %w", remote, err)
+	}
+	return info.Name == "s3", nil
+}
+
+// setBackendOptions adds opts to the remote's connection string, where they
+// override the remote's own config. Options the remote's backend doesn't
+// have are left out.
+func setBackendOptions(remote string, opts ...backendOption) (string, error) {
+	info, _, _, _, err := fs.ParseRemote(remote)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote %s: %w", remote, err)
+	}
+
+	var overrides string
+	for _, opt := range opts {
+		if info.Options.Get(opt.name) != nil {
+			overrides += "," + opt.name + "=" + quoteOptionValue(opt.value)
+		}
+	}
+	if overrides == "" {
+		return remote, nil
+	}
+
+	parsed, err := fspath.Parse(remote)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote %s: %w", remote, err)
+	}
+	configString := parsed.ConfigString
+	if configString == "" {
+		// A plain local path, which takes options as an on the fly remote.
+		configString = ":" + info.Name
+	}
+	return configString + overrides + ":" + parsed.Path, nil
+}
+
+// quoteOptionValue quotes a connection string value that would otherwise end
+// the option or the connection string early.
+func quoteOptionValue(value string) string {
+	if !strings.ContainsAny(value, `,:'"`) {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,351 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDirectoryMarkers(t *testing.T) {
+	t.Setenv("RCLONE_CONFIG_MYS3_TYPE", "s3")
+
+	tests := []struct {
+		name    string
+		remote  string
+		want    string
+		wantErr bool
+	}{
+		{name: "OnTheFlyS3", remote: ":s3:bucket/db_data", want: ":s3,directory_markers=true:bucket/db_data"},
+		{name: "OnTheFlyS3WithOptions", remote: ":s3,provider=AWS:bucket", want: ":s3,provider=AWS,directory_markers=true:bucket"},
+		{name: "NamedS3", remote: "mys3:bucket/db_data", want: "mys3,directory_markers=true:bucket/db_data"},
+		{name: "LocalPath", remote: "/mnt/backups/db_data", want: "/mnt/backups/db_data"},
+		{name: "NoMarkerSupport", remote: ":memory:bucket", want: ":memory:bucket"},
+		{name: "UnknownRemote", remote: "nosuchremote:bucket", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WithDirectoryMarkers(tt.remote)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetBackendOptions_LocalPath(t *testing.T) {
+	// Plain local paths take options as an on the fly remote.
+	got, err := setBackendOptions("/mnt/backups/db_data", backendOption{noSetModTimeOption, "true"})
+	require.NoError(t, err)
+	assert.Equal(t, ":local,no_set_modtime=true:/mnt/backups/db_data", got)
+
+	got, err = setBackendOptions(":local:/mnt/backups/db_data", backendOption{noSetModTimeOption, "true"})
+	require.NoError(t, err)
+	assert.Equal(t, ":local,no_set_modtime=true:/mnt/backups/db_data", got)
+}
+
+// s3UploadOpt mirrors the S3 backend's multipart upload options.
+type s3UploadOpt struct {
+	ChunkSize         fs.SizeSuffix `config:"chunk_size"`
+	UploadConcurrency int           `config:"upload_concurrency"`
+	DirectoryMarkers  bool          `config:"directory_markers"`
+	Region            string        `config:"region"`
+	Profile           string        `config:"profile"`
+	EnvAuth           bool          `config:"env_auth"`
+	CredentialsFile   string        `config:"shared_credentials_file"`
+}
+
+// backendOpt reads a remote's upload options the way its backend does.
+func backendOpt(t *testing.T, remote string) s3UploadOpt {
+	t.Helper()
+	info, name, _, connConfig, err := fs.ParseRemote(remote)
+	require.NoError(t, err)
+	var opt s3UploadOpt
+	require.NoError(t, configstruct.Set(fs.ConfigMap(info.Prefix, info.Options, name, connConfig), &opt))
+	return opt
+}
+
+func TestWithUploadParts(t *testing.T) {
+	remote, err := WithUploadParts(":s3:bucket/db_data", 64*fs.Mebi, 8)
+	require.NoError(t, err)
+	assert.Equal(t, ":s3,chunk_size=64Mi,upload_concurrency=8:bucket/db_data", remote)
+	assert.Equal(t, s3UploadOpt{ChunkSize: 64 * fs.Mebi, UploadConcurrency: 8}, backendOpt(t, remote))
+
+	// Both kinds of option can be layered on the same remote.
+	remote, err = WithDirectoryMarkers(remote)
+	require.NoError(t, err)
+	assert.Equal(t, s3UploadOpt{ChunkSize: 64 * fs.Mebi, UploadConcurrency: 8, DirectoryMarkers: true}, backendOpt(t, remote))
+
+	// Zero values keep the backend's defaults.
+	remote, err = WithUploadParts(":s3:bucket/db_data", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, ":s3:bucket/db_data", remote)
+
+	remote, err = WithUploadParts("/mnt/backups", 64*fs.Mebi, 8)
+	require.NoError(t, err)
+	assert.Equal(t, "/mnt/backups", remote)
+}
+
+func TestWithAWSConfig(t *testing.T) {
+	t.Setenv("RCLONE_CONFIG_MYS3_TYPE", "s3")
+	t.Setenv("RCLONE_CONFIG_MYS3_REGION", "us-east-1")
+
+	tests := []struct {
+		name        string
+		remote      string
+		region      string
+		profile     string
+		credentials string
+		want        s3UploadOpt
+	}{
+		{name: "Region", remote: ":s3:bucket", region: "eu-west-1", want: s3UploadOpt{Region: "eu-west-1"}},
+		{name: "Profile", remote: ":s3:bucket", profile: "prod", want: s3UploadOpt{Profile: "prod", EnvAuth: true}},
+		{name: "OverridesRemoteConfig", remote: "mys3:bucket", region: "ap-southeast-2", want: s3UploadOpt{Region: "ap-southeast-2"}},
+		{name: "UnsetKeepsRemoteConfig", remote: "mys3:bucket", want: s3UploadOpt{Region: "us-east-1"}},
+		{name: "QuotedProfile", remote: ":s3:bucket", profile: "team:prod,eu", want: s3UploadOpt{Profile: "team:prod,eu", EnvAuth: true}},
+		{name: "CredentialsFile", remote: ":s3:bucket", credentials: "/run/secrets/aws", want: s3UploadOpt{CredentialsFile: "/run/secrets/aws", EnvAuth: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WithAWSConfig(tt.remote, AWSConfig{Region: tt.region, Profile: tt.profile, CredentialsFile: tt.credentials})
+			require.NoError(t, err)
+
+			opt := backendOpt(t, got)
+			assert.Equal(t, tt.want.Region, opt.Region)
+			assert.Equal(t, tt.want.Profile, opt.Profile)
+			assert.Equal(t, tt.want.EnvAuth, opt.EnvAuth)
+			assert.Equal(t, tt.want.CredentialsFile, opt.CredentialsFile)
+		})
+	}
+
+	// Other backends, even ones with a region of their own, are left alone.
+	for _, remote := range []string{"/mnt/backups", ":memory:bucket", ":swift:container"} {
+		got, err := WithAWSConfig(remote, AWSConfig{Region: "eu-west-1", Profile: "prod", RoleARN: "arn:aws:iam::123456789012:role/backup"})
+		require.NoError(t, err)
+		assert.Equal(t, remote, got)
+	}
+}
+
+func TestWithAWSConfig_AssumeRole(t *testing.T) {
+	var (
+		mu           sync.Mutex
+		assumed      url.Values
+		s3AuthHeader string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPost && r.URL.Path == "/" {
+			// STS AssumeRole, signed with the base credentials.
+			assert.NoError(t, r.ParseForm())
+			assumed = r.PostForm
+			fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult>
+<Credentials><AccessKeyId>ASSUMEDKEY</AccessKeyId><SecretAccessKey>assumed-secret</SecretAccessKey>
+<SessionToken>assumed-token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials>
+<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/backup/volumesync</Arn><AssumedRoleId>AROA:volumesync</AssumedRoleId></AssumedRoleUser>
+</AssumeRoleResult></AssumeRoleResponse>`)
+			return
+		}
+		// S3 bucket listing.
+		s3AuthHeader = r.Header.Get("Authorization")
+		fmt.Fprint(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>bucket</Name><IsTruncated>false</IsTruncated><KeyCount>0</KeyCount></ListBucketResult>`)
+	}))
+	defer srv.Close()
+
+	// The base credentials, and where to find STS, come from the
+	// environment.
+	t.Setenv("AWS_ACCESS_KEY_ID", "BASEKEY")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "base-secret")
+	t.Setenv("AWS_ENDPOINT_URL_STS", srv.URL)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	remote, err := WithAWSConfig(":s3,provider=AWS,env_auth=true,force_path_style=true,endpoint='"+srv.URL+"':bucket", AWSConfig{
+		Region:     "eu-west-1",
+		RoleARN:    "arn:aws:iam::123456789012:role/backup",
+		ExternalID: "partner-42",
+	})
+	require.NoError(t, err)
+	require.NoError(t, CheckRemote(context.Background(), remote))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.NotNil(t, assumed, "AssumeRole was never called")
+	assert.Equal(t, "AssumeRole", assumed.Get("Action"))
+	assert.Equal(t, "arn:aws:iam::123456789012:role/backup", assumed.Get("RoleArn"))
+	assert.Equal(t, "partner-42", assumed.Get("ExternalId"))
+	assert.Contains(t, s3AuthHeader, "Credential=ASSUMEDKEY/")
+}
+
+func TestWithAWSConfig_CredentialsFile(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		authHeader string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		authHeader = r.Header.Get("Authorization")
+		fmt.Fprint(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><Name>bucket</Name><IsTruncated>false</IsTruncated><KeyCount>0</KeyCount></ListBucketResult>`)
+	}))
+	defer srv.Close()
+
+	// The keys are only in the mounted file, not the environment nor the
+	// default credentials file.
+	secret := filepath.Join(t.TempDir(), "aws-credentials")
+	require.NoError(t, os.WriteFile(secret, []byte("[default]\naws_access_key_id = DEFAULTKEY\naws_secret_access_key = default-secret\n\n"+
+		"[backup]\naws_access_key_id = FILEKEY\naws_secret_access_key = file-secret\n"), 0600))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	remote, err := WithAWSConfig(":s3,provider=AWS,force_path_style=true,endpoint='"+srv.URL+"':bucket", AWSConfig{
+		Region:          "eu-west-1",
+		Profile:         "backup",
+		CredentialsFile: secret,
+	})
+	require.NoError(t, err)
+	require.NoError(t, CheckRemote(context.Background(), remote))
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Contains(t, authHeader, "Credential=FILEKEY/")
+}
+
+func TestWithACL(t *testing.T) {
+	s3 := newFakeS3(t)
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "data.db"), []byte("hello"), 0644))
+
+	for _, tt := range []struct {
+		name string
+		acl  string
+	}{
+		{name: "Set", acl: "bucket-owner-full-control"},
+		{name: "Unset"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, err := WithACL(s3.remote(tt.name), tt.acl)
+			require.NoError(t, err)
+
+			s, err := New(context.Background())
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(context.Background(), src, remote))
+
+			put := s3.uploaded(tt.name + "/data.db")
+			require.NotNil(t, put, "data.db was never uploaded")
+			assert.Equal(t, tt.acl, put.Get("X-Amz-Acl"))
+		})
+	}
+
+	// Other remotes are left alone.
+	for _, remote := range []string{"/mnt/backups", ":memory:bucket"} {
+		got, err := WithACL(remote, "private")
+		require.NoError(t, err)
+		assert.Equal(t, remote, got)
+	}
+}
+
+func TestWithVersionAt(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		name    string
+		remote  string
+		want    string
+		wantErr bool
+	}{
+		{name: "OnTheFlyS3", remote: ":s3:bucket/db_data", want: ":s3,version_at='2024-01-02T02:04:05Z':bucket/db_data"},
+		{name: "LocalPath", remote: "/mnt/backups/db_data", wantErr: true},
+		{name: "NoVersions", remote: ":memory:bucket", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := WithVersionAt(tt.remote, at)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestWithVersionAt_RestoresVersions restores a versioned bucket as it was at
+// different times.
+func TestWithVersionAt_RestoresVersions(t *testing.T) {
+	f := newFakeS3(t)
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 12, 0, 0, 0, time.UTC) }
+	f.putVersion("vol/a.txt", "a v1", day(1))
+	f.putVersion("vol/a.txt", "a v2", day(3))
+	f.putVersion("vol/a.txt", "a v3", day(5))
+	f.putVersion("vol/sub/b.txt", "b", day(1))
+	f.deleteVersion("vol/sub/b.txt", day(4))
+	f.putVersion("vol/c.txt", "c", day(4))
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want map[string]string
+	}{
+		{name: "BeforeAnything", at: day(1).Add(-time.Hour), want: map[string]string{}},
+		{name: "First", at: day(1), want: map[string]string{"a.txt": "a v1", "sub/b.txt": "b"}},
+		{name: "BetweenVersions", at: day(3).Add(time.Hour), want: map[string]string{"a.txt": "a v2", "sub/b.txt": "b"}},
+		{name: "AfterDelete", at: day(4), want: map[string]string{"a.txt": "a v2", "c.txt": "c"}},
+		{name: "Latest", at: day(6), want: map[string]string{"a.txt": "a v3", "c.txt": "c"}},
+	}
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, err := WithVersionAt(f.remote("vol"), tt.at)
+			require.NoError(t, err)
+			restoreDir := t.TempDir()
+			_, err = s.SyncWithStats(context.Background(), remote, restoreDir)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, readTree(t, restoreDir))
+		})
+	}
+}
+
+// readTree returns the contents of the files under dir by their slash
+// separated path.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		files[filepath.ToSlash(rel)] = string(body)
+		return err
+	})
+	require.NoError(t, err)
+	return files
+}
@@ -0,0 +1,65 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// ErrBucketNotFound and ErrAccessDenied classify the failures of CheckRemote.
+var (
+	ErrBucketNotFound = errors.New("bucket not found")
+	ErrAccessDenied   = errors.New("access denied")
+)
+
+// CheckRemote confirms that a remote can be reached with the configured
+// credentials by listing its top level, so that a mistake surfaces at startup
+// rather than in the middle of a backup. A missing directory is fine, as the
+// first backup creates it, but a missing bucket is not.
+func CheckRemote(ctx context.Context, remote string) error {
+	f, err := fs.NewFs(ctx, remote)
+	if errors.Is(err, fs.ErrorIsFile) {
+		return fmt.Errorf("%s is a file, not a directory", remote)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid remote %s: %w", remote, err)
+	}
+	return checkFs(ctx, f)
+}
+
+func checkFs(ctx context.Context, f fs.Fs) error {
+	_, err := f.List(ctx, "")
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrorDirNotFound):
+		// Bucket-based backends report a missing bucket this way, whereas a
+		// missing path inside a bucket simply lists as empty.
+		if f.Features().BucketBased {
+			return fmt.Errorf("%w: %s: %w", ErrBucketNotFound, f, err)
+		}
+		return nil
+	case isAccessDenied(err):
+		return fmt.Errorf("%w: %s, check the credentials and permissions: %w", ErrAccessDenied, f, err)
+	default:
+		return fmt.Errorf("failed to list %s: %w", f, err)
+	}
+}
+
+// isAccessDenied reports whether err is a permissions failure, either from the
+// filesystem or as an HTTP 401 or 403 from a cloud backend.
+func isAccessDenied(err error) bool {
+	if errors.Is(err, fs.ErrorPermissionDenied) || errors.Is(err, os.ErrPermission) {
+		return true
+	}
+	var httpErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &httpErr) {
+		code := httpErr.HTTPStatusCode()
+		return code == http.StatusUnauthorized || code == http.StatusForbidden
+	}
+	return false
+}
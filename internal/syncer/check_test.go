@@ -0,0 +1,89 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/require"
+)
+
+// listErrFs is a filesystem whose listing fails with err.
+type listErrFs struct {
+	fs.Fs
+	bucketBased bool
+	err         error
+}
+
+func (f *listErrFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	return nil, f.err
+}
+
+func (f *listErrFs) Features() *fs.Features {
+	return &fs.Features{BucketBased: f.bucketBased}
+}
+
+func (f *listErrFs) String() string {
+	return "fake:bucket/path"
+}
+
+// httpError mimics the HTTP response errors of cloud SDKs.
+type httpError struct {
+	code int
+}
+
+func (e httpError) Error() string       { return fmt.Sprintf("http %d", e.code) }
+func (e httpError) HTTPStatusCode() int { return e.code }
+
+func TestCheckFs(t *testing.T) {
+	tests := []struct {
+		name        string
+		bucketBased bool
+		err         error
+		wantErr     error
+	}{
+		{name: "Reachable"},
+		{name: "MissingDirectory", err: fs.ErrorDirNotFound},
+		{name: "MissingBucket", bucketBased: true, err: fs.ErrorDirNotFound, wantErr: ErrBucketNotFound},
+		{name: "Forbidden", bucketBased: true, err: fmt.Errorf("list: %w", httpError{code: 403}), wantErr: ErrAccessDenied},
+		{name: "Unauthorized", bucketBased: true, err: httpError{code: 401}, wantErr: ErrAccessDenied},
+		{name: "PermissionDenied", err: fs.ErrorPermissionDenied, wantErr: ErrAccessDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFs(context.Background(), &listErrFs{bucketBased: tt.bucketBased, err: tt.err})
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	t.Run("OtherFailure", func(t *testing.T) {
+		err := checkFs(context.Background(), &listErrFs{err: httpError{code: 500}})
+		require.Error(t, err)
+		require.False(t, errors.Is(err, ErrAccessDenied) || errors.Is(err, ErrBucketNotFound))
+	})
+}
+
+func TestCheckRemote(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	require.NoError(t, CheckRemote(ctx, tmpDir))
+	// The first backup creates the destination.
+	require.NoError(t, CheckRemote(ctx, filepath.Join(tmpDir, "missing")))
+
+	file := filepath.Join(tmpDir, "file")
+	require.NoError(t, os.WriteFile(file, []byte("x"), 0644))
+	require.Error(t, CheckRemote(ctx, file))
+
+	require.Error(t, CheckRemote(ctx, "nosuchremote:bucket"))
+}
@@ -0,0 +1,45 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by the methods of a Syncer that has been closed.
+var ErrClosed = errors.New("syncer is closed")
+
+// Close cancels the syncs, archives, snapshots, restores and verifications
+// in flight and waits for them to return. Any started afterwards fails with
+// ErrClosed. Closing a closed Syncer does nothing.
+func (s *Syncer) Close() error {
+	s.closeMu.Lock()
+	if s.closed {
+		s.closeMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.closeMu.Unlock()
+
+	s.cancelRuns()
+	s.runs.Wait()
+	return nil
+}
+
+// begin starts a run of the Syncer, returning a context that Close cancels
+// and a func to call once the run returns.
+func (s *Syncer) begin(ctx context.Context) (context.Context, func(), error) {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.closed {
+		return nil, nil, ErrClosed
+	}
+	s.runs.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.runsCtx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		s.runs.Done()
+	}, nil
+}
@@ -0,0 +1,57 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClose_FailsLaterRuns(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644))
+
+	s, err := New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, t.TempDir()))
+	require.NoError(t, s.Close())
+	require.NoError(t, s.Close(), "closing twice")
+
+	require.ErrorIs(t, s.Sync(ctx, srcDir, t.TempDir()), ErrClosed)
+	_, err = s.Archive(ctx, srcDir, t.TempDir())
+	require.ErrorIs(t, err, ErrClosed)
+	_, err = s.Snapshot(ctx, srcDir, t.TempDir())
+	require.ErrorIs(t, err, ErrClosed)
+	require.ErrorIs(t, s.Verify(ctx, srcDir, t.TempDir()), ErrClosed)
+}
+
+func TestClose_CancelsRunsInFlight(t *testing.T) {
+	s3 := newFakeS3(t)
+	for i := range 100 {
+		s3.objects[fmt.Sprintf("vol/%03d.txt", i)] = fakeS3Object{body: []byte("data"), modified: time.Now()}
+	}
+	s3.listPageSize = 10
+	listing := make(chan struct{}, 10)
+	s3.setOnList(func(int) { listing <- struct{}{} })
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Sync(context.Background(), s3.remote("vol"), t.TempDir())
+	}()
+
+	<-listing
+	require.NoError(t, s.Close())
+	select {
+	case err := <-errc:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close didn't cancel the sync")
+	}
+}
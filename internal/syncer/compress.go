@@ -5,16 +5,31 @@ import (
 	"strings"
 )
 
+// CompressMode selects the codec files are compressed with at the destination.
+type CompressMode string
+
 const (
-	compressMode  = "gzip"
-	compressLevel = 5
+	// CompressNone stores files as they are.
+	CompressNone CompressMode = ""
+	// CompressGzip stores files gzipped, as name.<size>.gz.
+	CompressGzip CompressMode = "gzip"
+	// CompressZstd stores files compressed with Zstandard, as
+	// name.<size>.zst. It is quicker than gzip for a similar ratio.
+	CompressZstd CompressMode = "zstd"
 )
 
+// compressLevels holds the level each codec is used at, the compress
+// backend's default for zstd and gzip's usual balance of speed and ratio.
+var compressLevels = map[CompressMode]int{
+	CompressGzip: 5,
+	CompressZstd: 2,
+}
+
 // WrapCompress wraps an rclone remote in the compress backend, returning an
-// rclone connection string. The remote is returned unchanged when compression
-// is disabled.
-func WrapCompress(remote string, enabled bool) string {
-	if !enabled {
+// rclone connection string. The remote is returned unchanged with
+// CompressNone.
+func WrapCompress(remote string, mode CompressMode) string {
+	if mode == CompressNone {
 		return remote
 	}
 
@@ -22,5 +37,5 @@ func WrapCompress(remote string, enabled bool) string {
 	// literal quote by doubling it.
 	quoted := strings.ReplaceAll(remote, "'", "''")
 
-	return fmt.Sprintf(":compress,mode=%s,level=%d,remote='%s':", compressMode, compressLevel, quoted)
+	return fmt.Sprintf(":compress,mode=%s,level=%d,remote='%s':", mode, compressLevels[mode], quoted)
 }
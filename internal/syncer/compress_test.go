@@ -13,45 +13,51 @@ import (
 
 func TestWrapCompress(t *testing.T) {
 	tests := []struct {
-		name    string
-		remote  string
-		enabled bool
-		want    string
+		name   string
+		remote string
+		mode   CompressMode
+		want   string
 	}{
 		{
-			name:    "Disabled returns remote unchanged",
-			remote:  "s3:my-bucket/db_data",
-			enabled: false,
-			want:    "s3:my-bucket/db_data",
+			name:   "Disabled returns remote unchanged",
+			remote: "s3:my-bucket/db_data",
+			mode:   CompressNone,
+			want:   "s3:my-bucket/db_data",
 		},
 		{
-			name:    "Enabled wraps in compress backend",
-			remote:  "s3:my-bucket/db_data",
-			enabled: true,
-			want:    ":compress,mode=gzip,level=5,remote='s3:my-bucket/db_data':",
+			name:   "Gzip wraps in compress backend",
+			remote: "s3:my-bucket/db_data",
+			mode:   CompressGzip,
+			want:   ":compress,mode=gzip,level=5,remote='s3:my-bucket/db_data':",
 		},
 		{
-			name:    "Single quotes in remote are doubled",
-			remote:  "s3:my-bucket/it's_data",
-			enabled: true,
-			want:    ":compress,mode=gzip,level=5,remote='s3:my-bucket/it''s_data':",
+			name:   "Zstd wraps in compress backend",
+			remote: "s3:my-bucket/db_data",
+			mode:   CompressZstd,
+			want:   ":compress,mode=zstd,level=2,remote='s3:my-bucket/db_data':",
 		},
 		{
-			name:    "Local path",
-			remote:  "/volumes/db_data",
-			enabled: true,
-			want:    ":compress,mode=gzip,level=5,remote='/volumes/db_data':",
+			name:   "Single quotes in remote are doubled",
+			remote: "s3:my-bucket/it's_data",
+			mode:   CompressGzip,
+			want:   ":compress,mode=gzip,level=5,remote='s3:my-bucket/it''s_data':",
+		},
+		{
+			name:   "Local path",
+			remote: "/volumes/db_data",
+			mode:   CompressGzip,
+			want:   ":compress,mode=gzip,level=5,remote='/volumes/db_data':",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := WrapCompress(tt.remote, tt.enabled)
+			got := WrapCompress(tt.remote, tt.mode)
 			require.Equal(t, tt.want, got)
 
 			// A malformed connection string must not silently pass: make sure
 			// rclone itself can parse what we produced.
-			if tt.enabled {
+			if tt.mode != CompressNone {
 				_, err := fspath.Parse(got)
 				require.NoError(t, err, "rclone should be able to parse the connection string")
 			}
@@ -61,8 +67,18 @@ func TestWrapCompress(t *testing.T) {
 
 // TestSync_CompressRoundTrip is the real proof: sync into a compressed remote,
 // verify the on-disk layout is compressed, then sync back out and verify the
-// files come back byte-identical.
+// files come back byte-identical. It runs once per codec.
 func TestSync_CompressRoundTrip(t *testing.T) {
+	for mode, ext := range map[CompressMode]string{CompressGzip: ".gz", CompressZstd: ".zst"} {
+		t.Run(string(mode), func(t *testing.T) {
+			testCompressRoundTrip(t, mode, ext)
+		})
+	}
+}
+
+// testCompressRoundTrip round trips files through a remote compressed with
+// mode, whose compressed files carry the extension ext.
+func testCompressRoundTrip(t *testing.T, mode CompressMode, ext string) {
 	tmpDir := t.TempDir()
 	srcDir := filepath.Join(tmpDir, "src")
 	dstDir := filepath.Join(tmpDir, "dst")
@@ -84,15 +100,15 @@ func TestSync_CompressRoundTrip(t *testing.T) {
 	require.NoError(t, err)
 
 	// Backup: local -> compressed remote.
-	compressedDst := WrapCompress(dstDir, true)
+	compressedDst := WrapCompress(dstDir, mode)
 	require.NoError(t, s.Sync(context.Background(), srcDir, compressedDst))
 
 	// The destination must hold compress-backend artefacts, not plaintext copies.
-	// Every file gets a .json metadata sidecar, but the data file is only .gz
-	// when gzip actually shrinks it — the backend falls back to storing the
-	// bytes as .bin otherwise (a few bytes of text gzip to more than they
-	// started with). So the sidecar is the invariant, not the .gz.
-	var gzCount, binCount, jsonCount int
+	// Every file gets a .json metadata sidecar, but the data file is only
+	// compressed when that actually shrinks it — the backend falls back to
+	// storing the bytes as .bin otherwise (a few bytes of text compress to
+	// more than they started with). So the sidecar is the invariant, not ext.
+	var compressedCount, binCount, jsonCount int
 	require.NoError(t, filepath.Walk(dstDir, func(p string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -101,8 +117,8 @@ func TestSync_CompressRoundTrip(t *testing.T) {
 			return nil
 		}
 		switch filepath.Ext(p) {
-		case ".gz":
-			gzCount++
+		case ext:
+			compressedCount++
 		case ".bin":
 			binCount++
 		case ".json":
@@ -111,14 +127,27 @@ func TestSync_CompressRoundTrip(t *testing.T) {
 		return nil
 	}))
 	require.Equal(t, len(files), jsonCount, "each file should have a metadata sidecar")
-	require.Equal(t, len(files), gzCount+binCount, "each file should have a data file")
-	require.NotZero(t, gzCount, "the compressible file should be stored gzipped")
+	require.Equal(t, len(files), compressedCount+binCount, "each file should have a data file")
+	require.NotZero(t, compressedCount, "the compressible file should be stored compressed")
 
 	for name := range files {
 		_, err := os.Stat(filepath.Join(dstDir, name))
 		require.True(t, os.IsNotExist(err), "%s should not be stored as plaintext", name)
 	}
 
+	// Compressed files differ in size from the originals, so the backend
+	// compares against the original size it recorded: an unchanged file isn't
+	// uploaded again, but one that changed is.
+	stats, err := s.SyncWithStats(context.Background(), srcDir, compressedDst)
+	require.NoError(t, err)
+	require.Zero(t, stats.Transfers, "unchanged files should not be uploaded again")
+
+	files["sub/file2.txt"] = strings.Repeat("compress me too ", 100)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "sub/file2.txt"), []byte(files["sub/file2.txt"]), 0644))
+	stats, err = s.SyncWithStats(context.Background(), srcDir, compressedDst)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Transfers, "the changed file should be uploaded again")
+
 	// Restore: compressed remote -> local.
 	require.NoError(t, s.Sync(context.Background(), compressedDst, restoreDir))
 
@@ -146,7 +175,7 @@ func TestSync_CompressActuallyShrinks(t *testing.T) {
 	s, err := New(context.Background())
 	require.NoError(t, err)
 
-	require.NoError(t, s.Sync(context.Background(), srcDir, WrapCompress(dstDir, true)))
+	require.NoError(t, s.Sync(context.Background(), srcDir, WrapCompress(dstDir, CompressGzip)))
 
 	var dstSize int64
 	require.NoError(t, filepath.Walk(dstDir, func(p string, info os.FileInfo, err error) error {
@@ -0,0 +1,102 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// Auto concurrency keeps to the same number of parts in flight as the
+// defaults, 16 files of 2 parts, and only shifts them between files and parts.
+const (
+	autoPartsInFlight = 32
+	// autoLargeFile is the size from which a file counts as large, as it is
+	// then transferred in parts.
+	autoLargeFile = 64 * 1024 * 1024
+	// autoManyFiles and autoSmallFile mark a source of many small files,
+	// where the time goes on requests rather than bytes.
+	autoManyFiles = 1000
+	autoSmallFile = 1024 * 1024
+)
+
+// sizeProfile sums up the files a sync is about to transfer.
+type sizeProfile struct {
+	files      int64
+	bytes      int64
+	largeBytes int64
+}
+
+func (p *sizeProfile) add(size int64) {
+	p.files++
+	if size < 0 {
+		return
+	}
+	p.bytes += size
+	if size >= autoLargeFile {
+		p.largeBytes += size
+	}
+}
+
+// concurrency picks how many files, and how many parts of each, to transfer
+// at once. When large files hold most of the bytes, each is split across
+// many parts. When there are many small files, they go many at a time in a
+// single part each. Anything else gets the defaults.
+func (p sizeProfile) concurrency() (files, parts int) {
+	switch {
+	case p.largeBytes > 0 && p.largeBytes*2 >= p.bytes:
+		return autoPartsInFlight / 8, 8
+	case p.files >= autoManyFiles && p.bytes/p.files < autoSmallFile:
+		return autoPartsInFlight, 1
+	default:
+		return 16, 2
+	}
+}
+
+// profileSizes lists f with the filter in ctx applied, summing up the files
+// a sync from it would consider.
+func profileSizes(ctx context.Context, f fs.Fs) (sizeProfile, error) {
+	var (
+		mu sync.Mutex
+		p  sizeProfile
+	)
+	err := walk.ListR(ctx, f, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		mu.Lock()
+		defer mu.Unlock()
+		entries.ForObject(func(o fs.Object) {
+			p.add(o.Size())
+		})
+		return nil
+	})
+	return p, err
+}
+
+// autoConcurrency lists srcFs to choose the concurrency of a sync from it,
+// setting it in the config in ctx. Part concurrency set with
+// WithPartConcurrency is kept; otherwise the parts of uploads are set on dst,
+// which is returned.
+func (s *Syncer) autoConcurrency(ctx context.Context, logger *slog.Logger, srcFs fs.Fs, dst string) (string, error) {
+	profile, err := profileSizes(ctx, srcFs)
+	if err != nil {
+		return "", fmt.Errorf("failed to list source to choose concurrency: %w", err)
+	}
+	files, parts := profile.concurrency()
+	if s.partConcurrency > 0 {
+		parts = s.partConcurrency
+	}
+	logger.Info("Chose concurrency", "files", profile.files, "bytes", profile.bytes, "concurrency", files, "part_concurrency", parts)
+
+	ci := fs.GetConfig(ctx)
+	ci.Transfers = files
+	ci.Checkers = files
+	if s.partConcurrency > 0 {
+		return dst, nil
+	}
+	ci.MultiThreadStreams = parts
+	ci.MultiThreadSet = true
+	return setBackendOptions(dst, backendOption{uploadConcurrencyOption, strconv.Itoa(parts)})
+}
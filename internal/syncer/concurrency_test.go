@@ -0,0 +1,68 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeProfile_Concurrency(t *testing.T) {
+	const mib = 1024 * 1024
+	repeat := func(n int, size int64) []int64 {
+		sizes := make([]int64, n)
+		for i := range sizes {
+			sizes[i] = size
+		}
+		return sizes
+	}
+	tests := []struct {
+		name      string
+		sizes     []int64
+		wantFiles int
+		wantParts int
+	}{
+		{name: "Empty", wantFiles: 16, wantParts: 2},
+		{name: "FewHugeFiles", sizes: repeat(3, 4096*mib), wantFiles: 4, wantParts: 8},
+		{name: "ManyTinyFiles", sizes: repeat(100000, 4096), wantFiles: 32, wantParts: 1},
+		{name: "FewSmallFiles", sizes: repeat(10, 4096), wantFiles: 16, wantParts: 2},
+		{name: "ManyMediumFiles", sizes: repeat(2000, 8*mib), wantFiles: 16, wantParts: 2},
+		// The one huge file holds most of the bytes, however many tiny files
+		// there are besides.
+		{name: "HugeFileAmongTinyOnes", sizes: append(repeat(100000, 1024), 10240*mib), wantFiles: 4, wantParts: 8},
+		// The large files are only a fraction of the bytes.
+		{name: "LargeFilesAmongManyMediumOnes", sizes: append(repeat(2000, 8*mib), repeat(2, 100*mib)...), wantFiles: 16, wantParts: 2},
+		{name: "UnknownSizes", sizes: repeat(5000, -1), wantFiles: 32, wantParts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p sizeProfile
+			for _, size := range tt.sizes {
+				p.add(size)
+			}
+			files, parts := p.concurrency()
+			assert.Equal(t, tt.wantFiles, files, "files")
+			assert.Equal(t, tt.wantParts, parts, "parts")
+		})
+	}
+}
+
+func TestSync_AutoConcurrency(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "sub/c.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+
+	s, err := New(ctx, WithConcurrency(0))
+	require.NoError(t, err)
+	stats, err := s.SyncWithStats(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Transfers)
+	require.Equal(t, map[string]string{"a.txt": "a.txt", "b.txt": "b.txt", "sub/c.txt": "sub/c.txt"}, readTree(t, dstDir))
+}
@@ -0,0 +1,78 @@
+package syncer
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// ContentTypeMode selects how the content type of each uploaded file is set,
+// for backends such as S3 that store one.
+type ContentTypeMode string
+
+const (
+	// ContentTypeExtension goes by the file's extension, falling back to
+	// application/octet-stream.
+	ContentTypeExtension ContentTypeMode = "extension"
+	// ContentTypeSniff also looks at the first bytes of the files whose
+	// extension doesn't give a type, as browsers do.
+	ContentTypeSniff ContentTypeMode = "sniff"
+	// ContentTypeOff sets application/octet-stream on every file.
+	ContentTypeOff ContentTypeMode = "off"
+)
+
+// defaultContentType is the content type of files that aren't recognised.
+const defaultContentType = "application/octet-stream"
+
+// sniffLen is how much of a file is read to sniff its content type, which is
+// all http.DetectContentType looks at.
+const sniffLen = 512
+
+// WithContentType sets how the content type of uploaded files is chosen,
+// ContentTypeExtension by default.
+func WithContentType(mode ContentTypeMode) Option {
+	return func(s *Syncer) {
+		s.contentType = mode
+	}
+}
+
+// sniffObject is a file whose content type is sniffed from its first bytes
+// when its extension doesn't give one.
+type sniffObject struct {
+	fs.Object
+}
+
+func newSniffObject(o fs.Object) fs.Object {
+	return &sniffObject{Object: o}
+}
+
+// MimeType returns the content type given by the file's extension or, failing
+// that, by its first bytes. A file that can't be read gets the default, and
+// the upload then reports the error.
+func (o *sniffObject) MimeType(ctx context.Context) string {
+	if mimeType := fs.MimeType(ctx, o.Object); mimeType != defaultContentType {
+		return mimeType
+	}
+	rc, err := o.Object.Open(ctx, &fs.RangeOption{Start: 0, End: sniffLen - 1})
+	if err != nil {
+		return defaultContentType
+	}
+	defer rc.Close()
+	head, err := io.ReadAll(io.LimitReader(rc, sniffLen))
+	if err != nil {
+		return defaultContentType
+	}
+	return http.DetectContentType(head)
+}
+
+// Metadata passes the wrapped file's metadata through, so that permissions
+// are still carried over.
+func (o *sniffObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+func (o *sniffObject) UnWrap() fs.Object {
+	return o.Object
+}
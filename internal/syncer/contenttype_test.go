@@ -0,0 +1,122 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync_ContentType(t *testing.T) {
+	files := map[string][]byte{
+		"index.html":  []byte("<p>hello</p>"),
+		"style.css":   []byte("body { color: red; }"),
+		"page":        []byte("<!DOCTYPE html><html><body>no extension</body></html>"),
+		"data.bin":    {0x00, 0x01, 0x02, 0x03},
+		"sub/a.json":  []byte(`{"a": 1}`),
+		"image":       append([]byte("\x89PNG\x0d\x0a\x1a\x0a"), make([]byte, 100)...),
+		"unknown.xyz": []byte("plain words"),
+	}
+	tests := []struct {
+		name string
+		mode ContentTypeMode
+		want map[string]string
+	}{
+		{
+			name: "Extension",
+			mode: ContentTypeExtension,
+			want: map[string]string{
+				"index.html":  "text/html; charset=utf-8",
+				"style.css":   "text/css; charset=utf-8",
+				"page":        defaultContentType,
+				"data.bin":    defaultContentType,
+				"sub/a.json":  "application/json",
+				"image":       defaultContentType,
+				"unknown.xyz": defaultContentType,
+			},
+		},
+		{
+			name: "Sniff",
+			mode: ContentTypeSniff,
+			want: map[string]string{
+				"index.html":  "text/html; charset=utf-8",
+				"style.css":   "text/css; charset=utf-8",
+				"page":        "text/html; charset=utf-8",
+				"data.bin":    defaultContentType,
+				"sub/a.json":  "application/json",
+				"image":       "image/png",
+				"unknown.xyz": "text/plain; charset=utf-8",
+			},
+		},
+		{
+			name: "Off",
+			mode: ContentTypeOff,
+			want: map[string]string{
+				"index.html":  defaultContentType,
+				"style.css":   defaultContentType,
+				"page":        defaultContentType,
+				"data.bin":    defaultContentType,
+				"sub/a.json":  defaultContentType,
+				"image":       defaultContentType,
+				"unknown.xyz": defaultContentType,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeS3(t)
+			srcDir := t.TempDir()
+			for name, body := range files {
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), body, 0640))
+			}
+
+			s, err := New(context.Background(), WithContentType(tt.mode))
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(context.Background(), srcDir, f.remote("site")))
+
+			for name, want := range tt.want {
+				header := f.uploaded("site/" + name)
+				require.NotNil(t, header, "%s should be uploaded", name)
+				assert.Equal(t, want, header.Get("Content-Type"), name)
+			}
+			// Permissions still travel with sniffed files.
+			assert.NotEmpty(t, f.uploaded("site/page").Get("X-Amz-Meta-Mode"))
+		})
+	}
+}
+
+// openRecorder is a file that records the options it is opened with.
+type openRecorder struct {
+	fs.Object
+	opened [][]fs.OpenOption
+}
+
+func (o *openRecorder) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	o.opened = append(o.opened, options)
+	return o.Object.Open(ctx, options...)
+}
+
+func TestSniffObject_ReadsOnlyTheStart(t *testing.T) {
+	body := append([]byte("<html><body>"), bytes.Repeat([]byte("x"), 10<<20)...)
+	o := &openRecorder{Object: object.NewMemoryObject("page", time.Now(), body)}
+
+	got := (&sniffObject{Object: o}).MimeType(context.Background())
+	require.Equal(t, "text/html; charset=utf-8", got)
+	require.Equal(t, [][]fs.OpenOption{{&fs.RangeOption{Start: 0, End: sniffLen - 1}}}, o.opened)
+
+	// Files with a known extension aren't opened at all.
+	o = &openRecorder{Object: object.NewMemoryObject("page.txt", time.Now(), body)}
+	got = (&sniffObject{Object: o}).MimeType(context.Background())
+	require.Equal(t, "text/plain; charset=utf-8", got)
+	require.Empty(t, o.opened)
+}
@@ -0,0 +1,114 @@
+package syncer
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Why a file was or wasn't transferred, as logged at debug level.
+const (
+	decisionNew         = "new"
+	decisionSizeDiffers = "size-differs"
+	decisionMtimeNewer  = "mtime-newer"
+	decisionMtimeOlder  = "mtime-older"
+	// decisionDiffers is a file whose size and modification time match but
+	// whose checksum doesn't, or that rclone found differs otherwise.
+	decisionDiffers   = "differs"
+	decisionUnchanged = "unchanged"
+	decisionFiltered  = "filtered"
+)
+
+// logDecision logs, at debug level, why the file rclone reported with sigil
+// is or isn't transferred. Only the sigils of files compared between both
+// sides are logged.
+func logDecision(ctx context.Context, logger *slog.Logger, sigil operations.Sigil, src, dst fs.DirEntry) {
+	var decision string
+	switch sigil {
+	case operations.MissingOnDst:
+		decision = decisionNew
+	case operations.Differ:
+		decision = differReason(ctx, src, dst)
+	case operations.Match:
+		decision = decisionUnchanged
+	default:
+		return
+	}
+	logger.Debug("Sync decision", "key", src.Remote(), "decision", decision)
+}
+
+// differReason works out why src and dst, which rclone found to differ, do.
+func differReason(ctx context.Context, src, dst fs.DirEntry) string {
+	if src.Size() != dst.Size() {
+		return decisionSizeDiffers
+	}
+	srcTime, dstTime := src.ModTime(ctx), dst.ModTime(ctx)
+	switch {
+	case srcTime.After(dstTime):
+		return decisionMtimeNewer
+	case srcTime.Before(dstTime):
+		return decisionMtimeOlder
+	default:
+		return decisionDiffers
+	}
+}
+
+// filterLogFs is a source that logs, at debug level, the files and
+// directories in its listings that the filter leaves out of the sync. rclone
+// drops them without a word, so they would otherwise never show up with the
+// decisions on the rest.
+type filterLogFs struct {
+	fs.Fs
+	logger *slog.Logger
+	fi     *filter.Filter
+}
+
+func (f *filterLogFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	entries, err := f.Fs.List(ctx, dir)
+	f.logFiltered(ctx, entries)
+	return entries, err
+}
+
+// Features passes the paged and recursive listings of the wrapped filesystem
+// through, logging what they filter out too. The wrapped filesystem is told
+// it can't filter its own listings, for the files left out to reach them.
+func (f *filterLogFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	ft.FilterAware = false
+	if listP := ft.ListP; listP != nil {
+		ft.ListP = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			return listP(ctx, dir, func(entries fs.DirEntries) error {
+				f.logFiltered(ctx, entries)
+				return callback(entries)
+			})
+		}
+	}
+	if listR := ft.ListR; listR != nil {
+		ft.ListR = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			return listR(ctx, dir, func(entries fs.DirEntries) error {
+				f.logFiltered(ctx, entries)
+				return callback(entries)
+			})
+		}
+	}
+	return &ft
+}
+
+func (f *filterLogFs) logFiltered(ctx context.Context, entries fs.DirEntries) {
+	for _, entry := range entries {
+		included := true
+		switch entry := entry.(type) {
+		case fs.Object:
+			included = f.fi.IncludeObject(ctx, entry)
+		case fs.Directory:
+			// An error here surfaces again when the sync itself checks.
+			included, _ = f.fi.IncludeDirectory(ctx, f.Fs)(entry.Remote())
+		}
+		if !included {
+			f.logger.Debug("Sync decision", "key", entry.Remote(), "decision", decisionFiltered)
+		}
+	}
+}
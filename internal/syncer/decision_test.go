@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync_LogsDecisions(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(dir, name, content string, modTime time.Time) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	write(srcDir, "new.txt", "new", old)
+	write(srcDir, "size.txt", "longer", old)
+	write(dstDir, "size.txt", "short", old)
+	write(srcDir, "mtime.txt", "same", old.Add(time.Minute))
+	write(dstDir, "mtime.txt", "same", old)
+	write(srcDir, "same.txt", "same", old)
+	write(dstDir, "same.txt", "same", old)
+	write(srcDir, "skip.log", "skip", old)
+	write(srcDir, "cache/skip.txt", "skip", old)
+
+	opt := filter.DefaultOpt
+	opt.FilterRule = []string{"- *.log", "- /cache/**"}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s, err := New(ctx, WithFilterOpt(opt), WithLogger(logger))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, dstDir))
+
+	decisions := map[string]string{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r struct {
+			Msg      string `json:"msg"`
+			Key      string `json:"key"`
+			Decision string `json:"decision"`
+		}
+		require.NoError(t, dec.Decode(&r))
+		if r.Msg == "Sync decision" {
+			decisions[r.Key] = r.Decision
+		}
+	}
+	require.Equal(t, map[string]string{
+		"new.txt":   decisionNew,
+		"size.txt":  decisionSizeDiffers,
+		"mtime.txt": decisionMtimeNewer,
+		"same.txt":  decisionUnchanged,
+		"skip.log":  decisionFiltered,
+		"cache":     decisionFiltered,
+	}, decisions)
+}
+
+func TestSync_NoDecisionsAboveDebug(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644))
+
+	var buf bytes.Buffer
+	s, err := New(ctx, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, t.TempDir()))
+	require.NotContains(t, buf.String(), "Sync decision")
+}
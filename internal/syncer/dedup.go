@@ -0,0 +1,146 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// WithDedup has an upload copy each file identical to one it already uploaded
+// from that copy, server-side, rather than upload it again. Files are
+// identical when their size, checksum, content type and metadata other than
+// times match; the modification time of the copy is then set to its own.
+// Working out the checksums reads every file uploaded in full. Only files new
+// to the destination are considered, and only within a single sync.
+func WithDedup(dedup bool) Option {
+	return func(s *Syncer) {
+		s.dedup = dedup
+	}
+}
+
+// dedupFs is a destination whose uploads of files identical to ones it has
+// already taken in the same sync are copied server-side from those.
+type dedupFs struct {
+	fs.Fs
+	logger  *slog.Logger
+	hash    hash.Type
+	mu      sync.Mutex
+	uploads map[string]*dedupUpload
+}
+
+// dedupUpload is the first upload of some content, whose object is set, if it
+// succeeds, by the time done is closed.
+type dedupUpload struct {
+	done chan struct{}
+	obj  fs.Object
+}
+
+// newDedupFs wraps dstFs for uploads to it to be deduplicated, or returns nil
+// if it can't copy server-side or keeps no checksums.
+func newDedupFs(logger *slog.Logger, dstFs fs.Fs) *dedupFs {
+	ht := dstFs.Hashes().GetOne()
+	if dstFs.Features().Copy == nil || ht == hash.None {
+		return nil
+	}
+	return &dedupFs{Fs: dstFs, logger: logger, hash: ht, uploads: map[string]*dedupUpload{}}
+}
+
+// Features drops multipart uploads, which would bypass Put. The backend then
+// splits large files into parts on its own.
+func (f *dedupFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	ft.OpenChunkWriter = nil
+	return &ft
+}
+
+func (f *dedupFs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	key, ok := f.contentKey(ctx, src)
+	if !ok {
+		return f.Fs.Put(ctx, in, src, options...)
+	}
+
+	f.mu.Lock()
+	first, seen := f.uploads[key]
+	if !seen {
+		first = &dedupUpload{done: make(chan struct{})}
+		f.uploads[key] = first
+	}
+	f.mu.Unlock()
+
+	if !seen {
+		o, err := f.Fs.Put(ctx, in, src, options...)
+		if err == nil {
+			first.obj = o
+		}
+		close(first.done)
+		return o, err
+	}
+
+	// Wait for the first upload of the content rather than upload it twice
+	// at once.
+	select {
+	case <-first.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if first.obj == nil {
+		return f.Fs.Put(ctx, in, src, options...)
+	}
+	o, err := f.copyFrom(ctx, first.obj, src)
+	if err != nil {
+		f.logger.Warn("Failed to copy duplicate file, uploading it instead", "key", src.Remote(), "copy_of", first.obj.Remote(), "error", err)
+		return f.Fs.Put(ctx, in, src, options...)
+	}
+	f.logger.Info("Copied duplicate file", "key", src.Remote(), "copy_of", first.obj.Remote())
+	return o, nil
+}
+
+// copyFrom copies from server-side to where src goes, giving the copy the
+// modification time of src.
+func (f *dedupFs) copyFrom(ctx context.Context, from fs.Object, src fs.ObjectInfo) (fs.Object, error) {
+	o, err := f.Fs.Features().Copy(ctx, from, src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	if modTime := src.ModTime(ctx); !o.ModTime(ctx).Equal(modTime) {
+		if err := o.SetModTime(ctx, modTime); err != nil {
+			return nil, fmt.Errorf("failed to set modification time: %w", err)
+		}
+	}
+	return o, nil
+}
+
+// contentKey identifies the content of src along with everything uploaded
+// with it other than its times, or reports false for files not worth
+// deduplicating.
+func (f *dedupFs) contentKey(ctx context.Context, src fs.ObjectInfo) (string, bool) {
+	if src.Size() <= 0 {
+		return "", false
+	}
+	sum, err := src.Hash(ctx, f.hash)
+	if err != nil || sum == "" {
+		return "", false
+	}
+	meta, err := fs.GetMetadata(ctx, src)
+	if err != nil {
+		return "", false
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s %s", src.Size(), sum, fs.MimeType(ctx, src))
+	for _, k := range slices.Sorted(maps.Keys(meta)) {
+		switch k {
+		case "mtime", "atime", "btime":
+			continue
+		}
+		fmt.Fprintf(&b, " %s=%q", k, meta[k])
+	}
+	return b.String(), true
+}
@@ -0,0 +1,79 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync_Dedup(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	fixture := strings.Repeat("fixture ", 1000)
+	for _, name := range []string{"a/seed.db", "b/seed.db"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(fixture), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "other.db"), []byte("other"), 0644))
+	mtimeA := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mtimeB := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "a/seed.db"), mtimeA, mtimeA))
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "b/seed.db"), mtimeB, mtimeB))
+
+	s, err := New(ctx, WithDedup(true))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// One of the identical files is uploaded, the other copied from it.
+	require.Equal(t, 2, s3.putCount())
+	s3.mu.Lock()
+	copies := len(s3.copies)
+	s3.mu.Unlock()
+	require.GreaterOrEqual(t, copies, 1)
+
+	// Both restore with their own modification time.
+	dstDir := t.TempDir()
+	require.NoError(t, s.Sync(ctx, s3.remote("vol"), dstDir))
+	require.Equal(t, map[string]string{"a/seed.db": fixture, "b/seed.db": fixture, "other.db": "other"}, readTree(t, dstDir))
+	for name, mtime := range map[string]time.Time{"a/seed.db": mtimeA, "b/seed.db": mtimeB} {
+		info, err := os.Stat(filepath.Join(dstDir, name))
+		require.NoError(t, err)
+		require.True(t, mtime.Equal(info.ModTime()), "%s: got %s", name, info.ModTime())
+	}
+}
+
+func TestSync_DedupOff(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	for _, name := range []string{"a.db", "b.db"} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte("same"), 0644))
+	}
+
+	s, err := New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+	require.Equal(t, 2, s3.putCount())
+}
+
+func TestSync_DedupKeepsDifferentModes(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.sh"), []byte("#!/bin/sh"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "b.sh"), []byte("#!/bin/sh"), 0644))
+	require.NoError(t, os.Chmod(filepath.Join(srcDir, "a.sh"), 0755))
+
+	s, err := New(ctx, WithDedup(true))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// Same content, but each carries its own mode.
+	require.Equal(t, 2, s3.putCount())
+}
@@ -0,0 +1,97 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/march"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// ErrTooManyDeletes is returned when a sync would delete a larger share of the
+// destination's files than WithMaxDeleteRatio allows.
+var ErrTooManyDeletes = errors.New("too many deletes")
+
+// deletePlan is a march.Marcher collecting the destination's files missing
+// from the source, which a sync with deletion would delete, and counting the
+// destination's files overall. The march calls it concurrently.
+type deletePlan struct {
+	mu      sync.Mutex
+	total   int64
+	deletes []fs.Object
+}
+
+func (p *deletePlan) SrcOnly(src fs.DirEntry) bool {
+	return false
+}
+
+func (p *deletePlan) DstOnly(dst fs.DirEntry) bool {
+	o, ok := dst.(fs.Object)
+	if !ok {
+		// Everything inside a directory missing from the source goes too.
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total++
+	p.deletes = append(p.deletes, o)
+	return false
+}
+
+func (p *deletePlan) Match(ctx context.Context, dst, src fs.DirEntry) bool {
+	if _, ok := dst.(fs.Object); !ok {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total++
+	return false
+}
+
+// planDeletes works out which files syncing srcFs to dstFs would delete,
+// without changing either side. Any error listing either side fails the
+// plan: a directory that couldn't be listed would otherwise look empty, and
+// everything under it deletable.
+func planDeletes(ctx context.Context, srcFs, dstFs fs.Fs) (*deletePlan, error) {
+	plan := &deletePlan{}
+	m := &march.March{
+		Ctx:      ctx,
+		Fdst:     dstFs,
+		Fsrc:     srcFs,
+		Callback: plan,
+	}
+	if err := m.Run(ctx); err != nil {
+		return nil, fmt.Errorf("failed to list files to delete: %w", err)
+	}
+	return plan, nil
+}
+
+// check fails with ErrTooManyDeletes when the plan deletes more than maxRatio
+// of the destination's files. It guards against an empty or wrong source, say
+// a mistyped path or a volume that failed to mount, wiping out a good copy.
+func (p *deletePlan) check(logger *slog.Logger, maxRatio float64) error {
+	deletes := int64(len(p.deletes))
+	if p.total == 0 || float64(deletes)/float64(p.total) <= maxRatio {
+		logger.Debug("Delete check passed", "deletes", deletes, "files", p.total)
+		return nil
+	}
+	logger.Error("Refusing to sync, too many files would be deleted", "deletes", deletes, "files", p.total, "max_ratio", maxRatio)
+	return fmt.Errorf("%w: %d of %d files would be deleted, more than the maximum ratio of %g", ErrTooManyDeletes, deletes, p.total, maxRatio)
+}
+
+// run deletes the planned files, reporting each to the operations logger in
+// ctx as the sync itself would.
+func (p *deletePlan) run(ctx context.Context) error {
+	logger, _ := operations.GetLogger(ctx)
+	toDelete := make(fs.ObjectsChan, len(p.deletes))
+	for _, o := range p.deletes {
+		logger(ctx, operations.MissingOnSrc, nil, o, nil)
+		toDelete <- o
+	}
+	close(toDelete)
+	return operations.DeleteFiles(ctx, toDelete)
+}
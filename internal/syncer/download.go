@@ -0,0 +1,107 @@
+package syncer
+
+import (
+	"context"
+	"io"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+)
+
+// sizeCheckObject is a remote file whose downloads fail when they end short of
+// the size it was listed with. A body can be cut short without the connection
+// failing, say by a proxy. The error is a retry error, so rclone downloads the
+// file again.
+type sizeCheckObject struct {
+	fs.Object
+	// size is pinned, as S3 objects update theirs from each download, which
+	// would then match a body cut short.
+	size int64
+}
+
+func newSizeCheckObject(o fs.Object) fs.Object {
+	return &sizeCheckObject{Object: o, size: o.Size()}
+}
+
+func (o *sizeCheckObject) Size() int64 {
+	return o.size
+}
+
+func (o *sizeCheckObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	rc, err := o.Object.Open(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	return checkLength(rc, o.Remote(), expectedLength(o.size, options)), nil
+}
+
+// Metadata passes the wrapped file's metadata through, so that permissions
+// are still restored.
+func (o *sizeCheckObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+func (o *sizeCheckObject) UnWrap() fs.Object {
+	return o.Object
+}
+
+// openComplete opens o like Open, but its reader fails with a retry error
+// when it ends before all of o has been read.
+func openComplete(ctx context.Context, o fs.Object) (io.ReadCloser, error) {
+	size := o.Size()
+	rc, err := o.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return checkLength(rc, o.Remote(), size), nil
+}
+
+// checkLength returns rc, failing it with a retry error if it ends before want
+// bytes have been read from it. A negative want isn't checked.
+func checkLength(rc io.ReadCloser, remote string, want int64) io.ReadCloser {
+	if want < 0 {
+		return rc
+	}
+	return &sizeCheckReader{ReadCloser: rc, remote: remote, want: want}
+}
+
+// expectedLength returns how many bytes opening a file of the given size with
+// options reads, or -1 if its size isn't known.
+func expectedLength(size int64, options []fs.OpenOption) int64 {
+	if size < 0 {
+		return -1
+	}
+	offset, limit := int64(0), int64(-1)
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.RangeOption:
+			offset, limit = x.Decode(size)
+		case *fs.SeekOption:
+			offset, limit = x.Offset, -1
+		}
+	}
+	n := max(size-offset, 0)
+	if limit >= 0 {
+		n = min(n, limit)
+	}
+	return n
+}
+
+// sizeCheckReader counts the bytes read from a download, failing it if it
+// ends before want of them.
+type sizeCheckReader struct {
+	io.ReadCloser
+	remote    string
+	want, got int64
+}
+
+func (r *sizeCheckReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.got += int64(n)
+	if err == io.EOF && r.got < r.want {
+		// Resuming from where it stopped would ask for a range sized after
+		// the short body, so the file is downloaded again from the start.
+		err = fserrors.NoLowLevelRetryError(fserrors.RetryErrorf("download of %s ended early: got %d of %d bytes", r.remote, r.got, r.want))
+	}
+	return n, err
+}
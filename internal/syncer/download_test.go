@@ -0,0 +1,71 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int64
+		options []fs.OpenOption
+		want    int64
+	}{
+		{name: "Whole", size: 100, want: 100},
+		{name: "UnknownSize", size: -1, want: -1},
+		{name: "Range", size: 100, options: []fs.OpenOption{&fs.RangeOption{Start: 10, End: 19}}, want: 10},
+		{name: "RangeToEnd", size: 100, options: []fs.OpenOption{&fs.RangeOption{Start: 90, End: -1}}, want: 10},
+		{name: "RangePastEnd", size: 100, options: []fs.OpenOption{&fs.RangeOption{Start: 90, End: 199}}, want: 10},
+		{name: "Suffix", size: 100, options: []fs.OpenOption{&fs.RangeOption{Start: -1, End: 30}}, want: 30},
+		{name: "Seek", size: 100, options: []fs.OpenOption{&fs.SeekOption{Offset: 25}}, want: 75},
+		{name: "Empty", size: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, expectedLength(tt.size, tt.options))
+		})
+	}
+}
+
+func TestSync_RetriesShortDownloads(t *testing.T) {
+	f := newFakeS3(t)
+	body := strings.Repeat("0123456789", 100)
+	f.objects["vol/data.txt"] = fakeS3Object{body: []byte(body), modified: time.Now()}
+	f.shortenReads("vol/data.txt", 1)
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	restoreDir := t.TempDir()
+	require.NoError(t, s.Sync(context.Background(), f.remote("vol"), restoreDir))
+
+	got, err := os.ReadFile(filepath.Join(restoreDir, "data.txt"))
+	require.NoError(t, err)
+	require.Equal(t, body, string(got))
+	require.Equal(t, 2, f.getCount("vol/data.txt"), "the short download should be retried")
+}
+
+func TestSync_RejectsShortDownloads(t *testing.T) {
+	f := newFakeS3(t)
+	f.objects["vol/data.txt"] = fakeS3Object{body: []byte(strings.Repeat("0123456789", 100)), modified: time.Now()}
+	f.objects["vol/other.txt"] = fakeS3Object{body: []byte("fine"), modified: time.Now()}
+	f.shortenReads("vol/data.txt", 1000)
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	restoreDir := t.TempDir()
+	err = s.Sync(context.Background(), f.remote("vol"), restoreDir)
+	require.ErrorContains(t, err, "download of data.txt ended early: got 500 of 1000 bytes")
+
+	// Neither the short file nor a partial one is left behind.
+	require.Equal(t, map[string]string{"other.txt": "fine"}, readTree(t, restoreDir))
+	require.Greater(t, f.getCount("vol/data.txt"), 1, "the download should be retried before giving up")
+}
@@ -0,0 +1,49 @@
+package syncer_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/dedalusj/docker-volume-sync/internal/syncer"
+)
+
+// A Syncer is set up entirely with Options, without the environment
+// variables the volumesync command reads.
+func Example() {
+	ctx := context.Background()
+	src, err := os.MkdirTemp("", "volume")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(src)
+	dst, err := os.MkdirTemp("", "backup")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dst)
+	if err := os.WriteFile(filepath.Join(src, "data.txt"), []byte("hello"), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	s, err := syncer.New(ctx,
+		syncer.WithDelete(true),
+		syncer.WithLogger(slog.New(slog.DiscardHandler)),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	stats, err := s.SyncWithStats(ctx, src, dst)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dst, "data.txt"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(stats.Transfers, string(data))
+	// Output: 1 hello
+}
@@ -2,22 +2,24 @@ package syncer
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/rclone/rclone/fs/filter"
 )
 
-// BuildFilterRules compiles exclude and include patterns into an ordered list
-// of rclone filter rules.
+// BuildFilterRules compiles exclude and include patterns, plus any
+// gitignore-style ignore patterns, into an ordered list of rclone filter rules.
 //
 // Rules are matched first-match-wins, so excludes are emitted before includes
-// and therefore take precedence over them. When at least one include is given,
-// a catch-all is appended so that anything not included is skipped.
+// and therefore take precedence over them. Ignore patterns sit between the two;
+// see ignoreRules for how they are translated. When at least one include is
+// given, a catch-all is appended so that anything not included is skipped.
 //
 // Rules are returned for rclone's FilterRule rather than its IncludeRule and
 // ExcludeRule: rclone parses those two in a fixed order regardless of intent,
 // which makes their precedence indeterminate when both are set.
-func BuildFilterRules(exclude, include []string) ([]string, error) {
-	rules := make([]string, 0, len(exclude)+len(include)+1)
+func BuildFilterRules(exclude, include, ignore []string) ([]string, error) {
+	rules := make([]string, 0, len(exclude)+len(include)+2*len(ignore)+1)
 
 	for _, pattern := range exclude {
 		if err := validatePattern(pattern); err != nil {
@@ -26,6 +28,12 @@ func BuildFilterRules(exclude, include []string) ([]string, error) {
 		rules = append(rules, "- "+pattern)
 	}
 
+	ignored, err := ignoreRules(ignore)
+	if err != nil {
+		return nil, err
+	}
+	rules = append(rules, ignored...)
+
 	for _, pattern := range include {
 		if err := validatePattern(pattern); err != nil {
 			return nil, err
@@ -48,3 +56,75 @@ func validatePattern(pattern string) error {
 	}
 	return nil
 }
+
+// ignoreRules translates gitignore-style patterns into rclone filter rules.
+//
+// gitignore is last-match-wins while rclone is first-match-wins, so the
+// patterns are emitted in reverse: a later "!keep.log" then comes before the
+// "*.log" it carves out of. A negation re-includes the path outright, which
+// also lets it through any volumesync.include restriction.
+//
+// Unlike git, a negation can re-include a file under an excluded directory,
+// because rclone still descends into a directory that an include rule needs.
+func ignoreRules(patterns []string) ([]string, error) {
+	var rules []string
+	for i := len(patterns) - 1; i >= 0; i-- {
+		pattern := patterns[i]
+
+		prefix := "- "
+		if strings.HasPrefix(pattern, "!") {
+			prefix = "+ "
+			pattern = pattern[1:]
+		} else if strings.HasPrefix(pattern, `\!`) || strings.HasPrefix(pattern, `\#`) {
+			pattern = pattern[1:]
+		}
+
+		globs, err := gitignoreToGlobs(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, glob := range globs {
+			rules = append(rules, prefix+glob)
+		}
+	}
+	return rules, nil
+}
+
+// gitignoreToGlobs converts a single gitignore pattern into the rclone globs
+// matching the same paths.
+//
+// A pattern with a slash anywhere but the end is anchored to the volume root,
+// otherwise it matches at any depth. A trailing slash matches directories
+// only; without one the pattern matches both files and directories, so the
+// directory form is emitted as well to cover everything beneath it.
+func gitignoreToGlobs(original string) ([]string, error) {
+	pattern := original
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+
+	// A leading "**/" matches in all directories, which is what an unanchored
+	// rclone glob already does.
+	pattern = strings.TrimPrefix(pattern, "**/")
+
+	if pattern == "" {
+		return nil, fmt.Errorf("invalid ignore pattern %q", original)
+	}
+
+	if strings.Contains(pattern, "/") && !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+
+	// Braces are literal in gitignore but alternation in rclone.
+	pattern = strings.NewReplacer("{", `\{`, "}", `\}`).Replace(pattern)
+
+	globs := []string{pattern + "/**"}
+	if !dirOnly {
+		globs = append([]string{pattern}, globs...)
+	}
+	for _, glob := range globs {
+		if err := validatePattern(glob); err != nil {
+			return nil, err
+		}
+	}
+	return globs, nil
+}
@@ -59,7 +59,7 @@ func TestBuildFilterRules(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := BuildFilterRules(tt.exclude, tt.include)
+			got, err := BuildFilterRules(tt.exclude, tt.include, nil)
 			if tt.wantErr {
 				require.Error(t, err)
 				return
@@ -77,14 +77,14 @@ func TestBuildFilterRules(t *testing.T) {
 }
 
 func TestBuildFilterRules_ErrorNamesThePattern(t *testing.T) {
-	_, err := BuildFilterRules([]string{"*.log", "bad{"}, nil)
+	_, err := BuildFilterRules([]string{"*.log", "bad{"}, nil, nil)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "bad{")
 }
 
 // syncWithPatterns syncs a populated source tree through the given filters and
 // returns the relative paths that reached the destination.
-func syncWithPatterns(t *testing.T, tree []string, exclude, include []string) []string {
+func syncWithPatterns(t *testing.T, tree []string, exclude, include, ignore []string) []string {
 	t.Helper()
 
 	tmpDir := t.TempDir()
@@ -99,15 +99,15 @@ func syncWithPatterns(t *testing.T, tree []string, exclude, include []string) []
 		require.NoError(t, os.WriteFile(full, []byte("x"), 0644))
 	}
 
-	rules, err := BuildFilterRules(exclude, include)
+	rules, err := BuildFilterRules(exclude, include, ignore)
 	require.NoError(t, err)
 
 	f := filter.Opt
 	f.MinAge = fs.DurationOff
 	f.MaxAge = fs.DurationOff
-	f.FilterRule = append([]string{"- .volumesync_done"}, rules...)
+	f.FilterRule = rules
 
-	s, err := New(context.Background(), WithFilterOpt(f))
+	s, err := New(context.Background(), WithFilterOpt(f), WithReserved(".volumesync_done"))
 	require.NoError(t, err)
 	require.NoError(t, s.Sync(context.Background(), srcDir, dstDir))
 
@@ -140,6 +140,7 @@ func TestSync_Filters(t *testing.T) {
 		"cache/blob",
 		"cache/deep/blob",
 		".volumesync_done",
+		".volumesync_done.123.tmp",
 	}
 
 	tests := []struct {
@@ -195,7 +196,157 @@ func TestSync_Filters(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := syncWithPatterns(t, tree, tt.exclude, tt.include)
+			got := syncWithPatterns(t, tree, tt.exclude, tt.include, nil)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "UnanchoredMatchesFilesAndDirectories",
+			patterns: []string{"*.log"},
+			want:     []string{"- *.log", "- *.log/**"},
+		},
+		{
+			name:     "TrailingSlashMatchesDirectoriesOnly",
+			patterns: []string{"cache/"},
+			want:     []string{"- cache/**"},
+		},
+		{
+			name:     "MiddleSlashAnchorsToRoot",
+			patterns: []string{"data/tmp"},
+			want:     []string{"- /data/tmp", "- /data/tmp/**"},
+		},
+		{
+			name:     "LeadingSlashAnchorsToRoot",
+			patterns: []string{"/build/"},
+			want:     []string{"- /build/**"},
+		},
+		{
+			name:     "LeadingDoubleStarIsUnanchored",
+			patterns: []string{"**/node_modules/"},
+			want:     []string{"- node_modules/**"},
+		},
+		{
+			// gitignore is last-match-wins, so a later negation must come first.
+			name:     "NegationIsEmittedBeforeWhatItOverrides",
+			patterns: []string{"*.log", "!keep.log"},
+			want:     []string{"+ keep.log", "+ keep.log/**", "- *.log", "- *.log/**"},
+		},
+		{
+			name:     "EscapedBangIsLiteral",
+			patterns: []string{`\!important`},
+			want:     []string{"- !important", "- !important/**"},
+		},
+		{
+			name:     "BracesAreLiteral",
+			patterns: []string{"{a,b}.txt"},
+			want:     []string{`- \{a,b\}.txt`, `- \{a,b\}.txt/**`},
+		},
+		{
+			name:     "BareSlashIsRejected",
+			patterns: []string{"/"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ignoreRules(tt.patterns)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+
+			f := filter.Opt
+			f.FilterRule = got
+			_, err = filter.NewFilter(&f)
+			require.NoError(t, err, "rclone should accept the generated rules")
+		})
+	}
+}
+
+func TestSync_IgnorePatterns(t *testing.T) {
+	tree := []string{
+		"app.db",
+		"app.log",
+		"keep.log",
+		"data/app.log",
+		"data/keep.log",
+		"build/out.bin",
+		"build/keep.txt",
+		"logs/today.txt",
+		"nested/logs/today.txt",
+		"nested/build/out.bin",
+	}
+
+	tests := []struct {
+		name    string
+		ignore  []string
+		include []string
+		want    []string
+	}{
+		{
+			name:   "NegationReincludesExcludedFiles",
+			ignore: []string{"*.log", "!keep.log"},
+			want: []string{
+				"app.db",
+				"build/keep.txt", "build/out.bin",
+				"data/keep.log", "keep.log",
+				"logs/today.txt",
+				"nested/build/out.bin", "nested/logs/today.txt",
+			},
+		},
+		{
+			name:   "DirectoryPatternMatchesAtAnyDepth",
+			ignore: []string{"logs/"},
+			want: []string{
+				"app.db", "app.log",
+				"build/keep.txt", "build/out.bin",
+				"data/app.log", "data/keep.log", "keep.log",
+				"nested/build/out.bin",
+			},
+		},
+		{
+			name:   "AnchoredPatternOnlyMatchesAtRoot",
+			ignore: []string{"/build"},
+			want: []string{
+				"app.db", "app.log",
+				"data/app.log", "data/keep.log", "keep.log",
+				"logs/today.txt",
+				"nested/build/out.bin", "nested/logs/today.txt",
+			},
+		},
+		{
+			name:   "LaterPatternWins",
+			ignore: []string{"!keep.log", "*.log"},
+			want: []string{
+				"app.db",
+				"build/keep.txt", "build/out.bin",
+				"logs/today.txt",
+				"nested/build/out.bin", "nested/logs/today.txt",
+			},
+		},
+		{
+			name:    "IgnoreAppliesWithinIncludes",
+			ignore:  []string{"*.log"},
+			include: []string{"data/**"},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := syncWithPatterns(t, tree, nil, tt.include, tt.ignore)
 			require.Equal(t, tt.want, got)
 		})
 	}
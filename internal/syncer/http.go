@@ -0,0 +1,48 @@
+package syncer
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// HTTPOptions tune the HTTP client remotes are reached with. The zero value
+// keeps rclone's defaults. Proxies are always taken from the HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY environment variables.
+type HTTPOptions struct {
+	// Timeout bounds both how long connecting may take and how long a
+	// connection may go without sending or receiving anything. It doesn't
+	// bound a whole request, which for a large file can rightly take hours.
+	Timeout time.Duration
+	// CABundle is a file of PEM certificates to trust, in addition to the
+	// system's, as for a proxy or an S3-compatible store with its own CA.
+	CABundle string
+}
+
+// ConfigureHTTP sets up the HTTP client of every remote in the process.
+// rclone builds a single one on first use, shared by every syncer, so this
+// must be called before any remote is used and has no effect after.
+func ConfigureHTTP(ctx context.Context, opt HTTPOptions) error {
+	ci := fs.GetConfig(ctx)
+	if opt.CABundle != "" {
+		pem, err := os.ReadFile(opt.CABundle)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		// rclone exits the process on a bundle it can't parse, so check it
+		// here first.
+		if !x509.NewCertPool().AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA bundle %s holds no PEM certificates", opt.CABundle)
+		}
+		ci.CaCert = []string{opt.CABundle}
+	}
+	if opt.Timeout > 0 {
+		ci.ConnectTimeout = fs.Duration(opt.Timeout)
+		ci.Timeout = fs.Duration(opt.Timeout)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package syncer
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigureHTTP(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+	notPEM := filepath.Join(t.TempDir(), "ca.der")
+	require.NoError(t, os.WriteFile(notPEM, srv.Certificate().Raw, 0644))
+
+	// httpConfig is the part of rclone's config ConfigureHTTP sets.
+	type httpConfig struct {
+		connectTimeout fs.Duration
+		timeout        fs.Duration
+		caCert         []string
+	}
+	defaults := httpConfig{connectTimeout: fs.GetConfig(context.Background()).ConnectTimeout, timeout: fs.GetConfig(context.Background()).Timeout}
+
+	tests := []struct {
+		name    string
+		opt     HTTPOptions
+		want    httpConfig
+		wantErr string
+	}{
+		{name: "Defaults", want: defaults},
+		{
+			name: "Timeout",
+			opt:  HTTPOptions{Timeout: 30 * time.Second},
+			want: httpConfig{connectTimeout: fs.Duration(30 * time.Second), timeout: fs.Duration(30 * time.Second)},
+		},
+		{
+			name: "CABundle",
+			opt:  HTTPOptions{CABundle: bundle},
+			want: httpConfig{connectTimeout: defaults.connectTimeout, timeout: defaults.timeout, caCert: []string{bundle}},
+		},
+		{name: "MissingCABundle", opt: HTTPOptions{CABundle: filepath.Join(t.TempDir(), "missing.pem")}, wantErr: "failed to read CA bundle"},
+		{name: "CABundleNotPEM", opt: HTTPOptions{CABundle: notPEM}, wantErr: "holds no PEM certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Work on a copy of the global config, so as not to change the
+			// client of every other test.
+			ctx, ci := fs.AddConfig(context.Background())
+
+			err := ConfigureHTTP(ctx, tt.opt)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, httpConfig{connectTimeout: ci.ConnectTimeout, timeout: ci.Timeout, caCert: ci.CaCert})
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package syncer
+
+import (
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+)
+
+// WithAgeLimits has uploads only consider the files last modified at least
+// minAge and at most maxAge ago, each ignored when zero. The limits apply to
+// the destination's files too, so those outside them are neither replaced
+// nor deleted, and a file aging out is left at the destination as it last
+// was. Downloads and copies between remotes ignore the limits, for a restore
+// to bring back every file. Uploads with limits keep no manifest, see
+// WithManifest, as the files aging out of it would be taken for deleted.
+func WithAgeLimits(minAge, maxAge time.Duration) Option {
+	return func(s *Syncer) {
+		s.minAge = minAge
+		s.maxAge = maxAge
+	}
+}
+
+// WithSizeLimits has uploads only consider the files of at least minSize and
+// at most maxSize bytes, each ignored when zero. Like the limits set with
+// WithAgeLimits, they apply to the destination's files too and are ignored
+// by downloads.
+func WithSizeLimits(minSize, maxSize fs.SizeSuffix) Option {
+	return func(s *Syncer) {
+		s.minSize = minSize
+		s.maxSize = maxSize
+	}
+}
+
+// uploadLimited reports whether uploads have age or size limits.
+func (s *Syncer) uploadLimited() bool {
+	return s.minAge > 0 || s.maxAge > 0 || s.minSize > 0 || s.maxSize > 0
+}
+
+// filterFor builds the filter of a sync in direction, as newFilter does, with
+// the age and size limits applied to uploads. The limits are tested against
+// each file's modification time and size as rclone lists it, alongside the
+// filter rules and the reserved files.
+func (s *Syncer) filterFor(direction string) (*filter.Filter, error) {
+	if direction != "upload" || !s.uploadLimited() {
+		return s.newFilter()
+	}
+	opt := s.filterOpt
+	if s.minAge > 0 {
+		opt.MinAge = fs.Duration(s.minAge)
+	}
+	if s.maxAge > 0 {
+		opt.MaxAge = fs.Duration(s.maxAge)
+	}
+	if s.minSize > 0 {
+		opt.MinSize = s.minSize
+	}
+	if s.maxSize > 0 {
+		opt.MaxSize = s.maxSize
+	}
+	return s.newFilterWith(opt)
+}
@@ -0,0 +1,122 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync_AgeLimits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	ages := map[string]time.Duration{
+		"hour.txt":  time.Hour,
+		"week.txt":  7 * 24 * time.Hour,
+		"month.txt": 30 * 24 * time.Hour,
+	}
+	newSrc := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, age := range ages {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+			require.NoError(t, os.Chtimes(path, now.Add(-age), now.Add(-age)))
+		}
+		return dir
+	}
+
+	tests := []struct {
+		name   string
+		minAge time.Duration
+		maxAge time.Duration
+		want   []string
+	}{
+		{name: "None", want: []string{"hour.txt", "week.txt", "month.txt"}},
+		{name: "MaxAge", maxAge: 48 * time.Hour, want: []string{"hour.txt"}},
+		{name: "MinAge", minAge: 48 * time.Hour, want: []string{"week.txt", "month.txt"}},
+		{name: "Both", minAge: 2 * time.Hour, maxAge: 14 * 24 * time.Hour, want: []string{"week.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := newFakeS3(t)
+			s, err := New(ctx, WithAgeLimits(tt.minAge, tt.maxAge))
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(ctx, newSrc(t), s3.remote("vol")))
+
+			want := map[string]string{}
+			for _, name := range tt.want {
+				want["vol/"+name] = name
+			}
+			require.Equal(t, want, s3Bodies(s3))
+		})
+	}
+}
+
+func TestSync_SizeLimits(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	for name, size := range map[string]int{"empty.txt": 0, "small.txt": 10, "medium.txt": 1000, "large.txt": 100000} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), make([]byte, size), 0644))
+	}
+	// Reserved files are left out whatever their size.
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, ".volumesync_done"), make([]byte, 100), 0644))
+
+	tests := []struct {
+		name    string
+		minSize fs.SizeSuffix
+		maxSize fs.SizeSuffix
+		want    []string
+	}{
+		{name: "None", want: []string{"empty.txt", "small.txt", "medium.txt", "large.txt"}},
+		{name: "MinSize", minSize: 100, want: []string{"medium.txt", "large.txt"}},
+		{name: "MaxSize", maxSize: 1000, want: []string{"empty.txt", "small.txt", "medium.txt"}},
+		{name: "Both", minSize: 1, maxSize: 10 * fs.Kibi, want: []string{"small.txt", "medium.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3 := newFakeS3(t)
+			s, err := New(ctx, WithSizeLimits(tt.minSize, tt.maxSize), WithReserved(".volumesync_done"))
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+			var got []string
+			for key := range s3Bodies(s3) {
+				got = append(got, strings.TrimPrefix(key, "vol/"))
+			}
+			require.ElementsMatch(t, tt.want, got)
+		})
+	}
+}
+
+func TestSync_AgeLimitsKeepOlderDestinationFiles(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "old.txt"), []byte("old"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "old.txt"), old, old))
+	full, err := New(ctx, WithDelete(true))
+	require.NoError(t, err)
+	require.NoError(t, full.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// The old file is outside the limits on both sides, so it's neither
+	// deleted from the destination nor uploaded again.
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new.txt"), []byte("new"), 0644))
+	limited, err := New(ctx, WithDelete(true), WithAgeLimits(0, 24*time.Hour))
+	require.NoError(t, err)
+	stats, err := limited.SyncWithStats(ctx, srcDir, s3.remote("vol"))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Transfers)
+	require.Equal(t, map[string]string{"vol/old.txt": "old", "vol/new.txt": "new"}, s3Bodies(s3))
+
+	// Restores bring back every file.
+	restored := t.TempDir()
+	require.NoError(t, limited.Sync(ctx, s3.remote("vol"), restored))
+	require.Equal(t, map[string]string{"old.txt": "old", "new.txt": "new"}, readTree(t, restored))
+}
@@ -0,0 +1,227 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/stretchr/testify/require"
+)
+
+// errListFailed is returned by the listfail backend for a directory named
+// "broken".
+var errListFailed = errors.New("listing failed")
+
+// listFailFs is a local filesystem whose directories named "broken" fail to
+// list, like a bucket listing cut short by a network error, and whose files
+// named "unreadable" can't be opened, like files the process may not read.
+type listFailFs struct {
+	fs.Fs
+}
+
+// unreadableObject is a file that lists fine but can't be opened.
+type unreadableObject struct {
+	fs.Object
+}
+
+func (o *unreadableObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	return nil, &os.PathError{Op: "open", Path: o.Remote(), Err: os.ErrPermission}
+}
+
+// listHook, carried in the context of a sync from a listfail remote, counts
+// the directories listed and cancels the sync once cancelAfter of them have
+// been.
+type listHook struct {
+	lists       atomic.Int32
+	cancelAfter int32
+	cancel      context.CancelFunc
+}
+
+type listHookKey struct{}
+
+func (f *listFailFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	if h, ok := ctx.Value(listHookKey{}).(*listHook); ok && h.lists.Add(1) == h.cancelAfter {
+		h.cancel()
+	}
+	if path.Base(dir) == "broken" {
+		return nil, errListFailed
+	}
+	entries, err := f.Fs.List(ctx, dir)
+	for i, entry := range entries {
+		if o, ok := entry.(fs.Object); ok && path.Base(o.Remote()) == "unreadable" {
+			entries[i] = &unreadableObject{Object: o}
+		}
+	}
+	return entries, err
+}
+
+// Features hides the paged and recursive listings of the local backend, so
+// that every listing goes through List.
+func (f *listFailFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	ft.ListP = nil
+	ft.ListR = nil
+	return &ft
+}
+
+func init() {
+	fs.Register(&fs.RegInfo{
+		Name: "listfail",
+		NewFs: func(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, error) {
+			f, err := fs.NewFs(ctx, root)
+			if err != nil {
+				return nil, err
+			}
+			return &listFailFs{Fs: f}, nil
+		},
+	})
+}
+
+func TestSync_SourceListingFails(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "Default"},
+		{name: "DeleteFirst", opts: []Option{WithDeleteFirst(true)}},
+		{name: "DeleteRatioCheck", opts: []Option{WithMaxDeleteRatio(0.9)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			dstDir := filepath.Join(tmpDir, "dst")
+
+			for _, dir := range []string{srcDir, dstDir} {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "broken"), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "broken", "data.txt"), []byte("data"), 0644))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("keep"), 0644))
+			}
+			require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stale.txt"), []byte("stale"), 0644))
+
+			s, err := New(ctx, append([]Option{WithDelete(true)}, tt.opts...)...)
+			require.NoError(t, err)
+			err = s.Sync(ctx, ":listfail:"+srcDir, dstDir)
+			require.Error(t, err)
+
+			// A source that couldn't be listed in full deletes nothing, not
+			// even what is known to be gone.
+			require.FileExists(t, filepath.Join(dstDir, "broken", "data.txt"))
+			require.FileExists(t, filepath.Join(dstDir, "stale.txt"))
+			require.FileExists(t, filepath.Join(dstDir, "keep.txt"))
+		})
+	}
+}
+
+func TestSync_SourceMissing(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	dstDir := filepath.Join(tmpDir, "dst")
+	require.NoError(t, os.MkdirAll(dstDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "keep.txt"), []byte("keep"), 0644))
+
+	s, err := New(ctx, WithDelete(true))
+	require.NoError(t, err)
+	require.Error(t, s.Sync(ctx, filepath.Join(tmpDir, "missing"), dstDir))
+	require.FileExists(t, filepath.Join(dstDir, "keep.txt"))
+}
+
+func TestSync_SourceEmpty(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+	require.NoError(t, os.MkdirAll(srcDir, 0755))
+	require.NoError(t, os.MkdirAll(dstDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stale.txt"), []byte("stale"), 0644))
+
+	// A source that lists fine but holds nothing is synced as such.
+	s, err := New(ctx, WithDelete(true))
+	require.NoError(t, err)
+	stats, err := s.SyncWithStats(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Deletes)
+	require.NoFileExists(t, filepath.Join(dstDir, "stale.txt"))
+}
+
+func TestSync_CancelStopsLocalWalk(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+	for i := range 50 {
+		dir := filepath.Join(srcDir, fmt.Sprintf("dir%02d", i))
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "data.txt"), []byte("data"), 0644))
+	}
+
+	// The sync is cancelled, say by a shutdown, a few directories into
+	// walking the volume.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hook := &listHook{cancelAfter: 3, cancel: cancel}
+	ctx = context.WithValue(ctx, listHookKey{}, hook)
+
+	s, err := New(ctx, WithConcurrency(1))
+	require.NoError(t, err)
+	err = s.Sync(ctx, ":listfail:"+srcDir, dstDir)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// The walk stopped rather than running through the rest of the tree.
+	require.Less(t, hook.lists.Load(), int32(10))
+	copied, err := filepath.Glob(filepath.Join(dstDir, "*", "data.txt"))
+	require.NoError(t, err)
+	require.Less(t, len(copied), 10)
+}
+
+func TestSync_SkipUnreadable(t *testing.T) {
+	tests := []struct {
+		name        string
+		skip        bool
+		brokenDir   bool
+		wantErr     bool
+		wantSkipped []string
+	}{
+		{name: "Off", wantErr: true},
+		{name: "On", skip: true, wantSkipped: []string{"data/unreadable"}},
+		{name: "UnreadableDirectory", skip: true, brokenDir: true, wantErr: true, wantSkipped: []string{"data/unreadable"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			dstDir := filepath.Join(tmpDir, "dst")
+			require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "data"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data", "unreadable"), []byte("secret"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data", "keep.txt"), []byte("keep"), 0644))
+			if tt.brokenDir {
+				require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "broken"), 0755))
+			}
+
+			s, err := New(ctx, WithSkipUnreadable(tt.skip))
+			require.NoError(t, err)
+			stats, err := s.SyncWithStats(ctx, ":listfail:"+srcDir, dstDir)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantSkipped, stats.Skipped)
+
+			// The rest of the volume is backed up either way.
+			require.FileExists(t, filepath.Join(dstDir, "data", "keep.txt"))
+			require.NoFileExists(t, filepath.Join(dstDir, "data", "unreadable"))
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	fssync "github.com/rclone/rclone/fs/sync"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// WithManifest has uploads record the files they leave at the destination in
+// a manifest kept in the local file path, so that the next upload compares
+// the source against it rather than list the destination, transferring only
+// the files whose size or modification time changed and deleting those gone.
+// Every fullEvery-th upload is a full sync all the same, to catch changes
+// made to the destination behind the syncer's back; zero only does one when
+// there is no usable manifest. Incremental uploads aren't verified. Downloads
+// and copies between remotes ignore the manifest, as do all syncs when path
+// is empty.
+func WithManifest(path string, fullEvery int) Option {
+	return func(s *Syncer) {
+		s.manifest = path
+		s.fullEvery = fullEvery
+	}
+}
+
+// manifest is the state of a destination as of the last upload to it.
+type manifest struct {
+	// Key identifies the destination and the filter the files were selected
+	// with, as a manifest is no use with either changed.
+	Key string `json:"key"`
+	// Incremental counts the incremental uploads since the last full one.
+	Incremental int                     `json:"incremental"`
+	Files       map[string]manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// manifestKey identifies the manifests of uploads to dst with the syncer's
+// filter. It is hashed, as dst may carry credentials.
+func (s *Syncer) manifestKey(dst string) string {
+	sum := sha256.Sum256([]byte(dst + "\n" + strings.Join(s.filterOpt.FilterRule, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// readManifest reads the manifest at path, returning nil with no error if
+// there is none yet.
+func readManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("corrupt manifest: %w", err)
+	}
+	if m.Files == nil {
+		return nil, errors.New("corrupt manifest: no files")
+	}
+	return &m, nil
+}
+
+// write replaces the manifest at path with m, whole or not at all.
+func (m *manifest) write(path string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// listManifestFiles lists f with the filter in ctx applied, as it would be
+// recorded in a manifest.
+func listManifestFiles(ctx context.Context, f fs.Fs) (map[string]manifestFile, error) {
+	var mu sync.Mutex
+	files := map[string]manifestFile{}
+	err := walk.ListR(ctx, f, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		mu.Lock()
+		defer mu.Unlock()
+		entries.ForObject(func(o fs.Object) {
+			files[o.Remote()] = manifestFile{Size: o.Size(), ModTime: o.ModTime(ctx).UTC()}
+		})
+		return nil
+	})
+	return files, err
+}
+
+// loadManifest lists srcFs for the manifest of an upload with the manifest
+// key given, returning it as next, along with the manifest of the last upload
+// as prev if this one can be incremental. A sync without next records no
+// manifest.
+func (s *Syncer) loadManifest(ctx context.Context, logger *slog.Logger, srcFs fs.Fs, key string) (prev, next *manifest) {
+	files, err := listManifestFiles(ctx, srcFs)
+	if err != nil {
+		logger.Warn("Failed to list source for the manifest, syncing in full", "error", err)
+		s.removeManifest(logger)
+		return nil, nil
+	}
+	next = &manifest{Key: key, Files: files}
+
+	prev, err = readManifest(s.manifest)
+	switch {
+	case err != nil:
+		logger.Warn("Failed to read manifest, syncing in full", "manifest", s.manifest, "error", err)
+		return nil, next
+	case prev == nil:
+		logger.Info("No manifest yet, syncing in full", "manifest", s.manifest)
+		return nil, next
+	case prev.Key != next.Key:
+		logger.Info("Manifest is for another destination or filter, syncing in full", "manifest", s.manifest)
+		return nil, next
+	case s.fullEvery > 0 && prev.Incremental+1 >= s.fullEvery:
+		logger.Info("Syncing in full to reconcile with the destination", "incremental_syncs", prev.Incremental)
+		return nil, next
+	}
+	next.Incremental = prev.Incremental + 1
+	return prev, next
+}
+
+// saveManifest records next as the manifest of the upload just done, leaving
+// out the files it skipped. Failing that, the manifest is removed, for the
+// next upload not to go by a stale one.
+func (s *Syncer) saveManifest(logger *slog.Logger, next *manifest, skipped []string) {
+	for _, name := range skipped {
+		delete(next.Files, name)
+	}
+	if err := next.write(s.manifest); err != nil {
+		logger.Error("Failed to write manifest", "manifest", s.manifest, "error", err)
+		s.removeManifest(logger)
+	}
+}
+
+func (s *Syncer) removeManifest(logger *slog.Logger) {
+	if err := os.Remove(s.manifest); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Error("Failed to remove manifest", "manifest", s.manifest, "error", err)
+	}
+}
+
+// manifestChanges returns the files of next that are new or changed since
+// prev, and those of prev that next no longer has, each sorted.
+func manifestChanges(prev, next *manifest) (changed, removed []string) {
+	for name, f := range next.Files {
+		if old, ok := prev.Files[name]; !ok || old.Size != f.Size || !old.ModTime.Equal(f.ModTime) {
+			changed = append(changed, name)
+		}
+	}
+	for name := range prev.Files {
+		if _, ok := next.Files[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	slices.Sort(changed)
+	slices.Sort(removed)
+	return changed, removed
+}
+
+// syncChanges uploads the files changed between the manifests prev and next
+// from srcFs to dstFs without listing either, and, when deleting, deletes
+// those removed, within the limit set by WithMaxDeleteRatio.
+func (s *Syncer) syncChanges(ctx context.Context, logger *slog.Logger, prev, next *manifest, srcFs, dstFs fs.Fs, deleting bool) error {
+	changed, removed := manifestChanges(prev, next)
+	logger.Info("Syncing incrementally", "changed", len(changed), "removed", len(removed))
+
+	if len(changed) > 0 {
+		fi, err := s.newFilter()
+		if err != nil {
+			return fmt.Errorf("failed to create filter: %w", err)
+		}
+		for _, name := range changed {
+			if err := fi.AddFile(name); err != nil {
+				return err
+			}
+		}
+		copyCtx, ci := fs.AddConfig(filter.ReplaceConfig(ctx, fi))
+		ci.NoTraverse = true
+		if err := fssync.CopyDir(copyCtx, dstFs, srcFs, false); err != nil {
+			return err
+		}
+	}
+	if !deleting || len(removed) == 0 {
+		return nil
+	}
+
+	plan := &deletePlan{total: int64(len(prev.Files))}
+	for _, name := range removed {
+		o, err := dstFs.NewObject(ctx, name)
+		if errors.Is(err, fs.ErrorObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find %s to delete: %w", name, err)
+		}
+		plan.deletes = append(plan.deletes, o)
+	}
+	if err := plan.check(logger, s.maxDeleteRatio); err != nil {
+		return err
+	}
+	return plan.run(ctx)
+}
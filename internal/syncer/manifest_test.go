@@ -0,0 +1,139 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// s3Bodies returns the contents of the objects in f, by key.
+func s3Bodies(f *fakeS3) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	bodies := map[string]string{}
+	for key, obj := range f.objects {
+		bodies[key] = string(obj.body)
+	}
+	return bodies
+}
+
+func readTestManifest(t *testing.T, path string) *manifest {
+	t.Helper()
+	m, err := readManifest(path)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	return m
+}
+
+func TestManifestChanges(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev := &manifest{Files: map[string]manifestFile{
+		"same.txt":    {Size: 1, ModTime: at},
+		"grown.txt":   {Size: 1, ModTime: at},
+		"touched.txt": {Size: 1, ModTime: at},
+		"gone.txt":    {Size: 1, ModTime: at},
+	}}
+	next := &manifest{Files: map[string]manifestFile{
+		"same.txt":    {Size: 1, ModTime: at},
+		"grown.txt":   {Size: 2, ModTime: at},
+		"touched.txt": {Size: 1, ModTime: at.Add(time.Second)},
+		"new.txt":     {Size: 1, ModTime: at},
+	}}
+	changed, removed := manifestChanges(prev, next)
+	require.Equal(t, []string{"grown.txt", "new.txt", "touched.txt"}, changed)
+	require.Equal(t, []string{"gone.txt"}, removed)
+}
+
+func TestSync_ManifestIncremental(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "vol.json")
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+	s, err := New(ctx, WithDelete(true), WithManifest(path, 0))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+	require.Equal(t, 0, readTestManifest(t, path).Incremental)
+	lists := s3.listCount()
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "b.txt"), []byte("b changed"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "c.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "d.txt"), []byte("d.txt"), 0644))
+	puts := s3.putCount()
+	stats, err := s.SyncWithStats(ctx, srcDir, s3.remote("vol"))
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"vol/a.txt": "a.txt", "vol/b.txt": "b changed", "vol/d.txt": "d.txt"}, s3Bodies(s3))
+	require.Equal(t, int64(2), stats.Transfers)
+	require.Equal(t, int64(1), stats.Deletes)
+	require.Equal(t, 2, s3.putCount()-puts, "only the changed files are uploaded")
+	require.Equal(t, lists, s3.listCount(), "the destination isn't listed")
+	require.Equal(t, 1, readTestManifest(t, path).Incremental)
+}
+
+func TestSync_ManifestFullEvery(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "vol.json")
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644))
+	s, err := New(ctx, WithManifest(path, 2))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// An object lost from the destination goes unnoticed by an incremental
+	// sync, but the next full one puts it back.
+	s3.mu.Lock()
+	delete(s3.objects, "vol/a.txt")
+	s3.mu.Unlock()
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+	require.Empty(t, s3Bodies(s3))
+	require.Equal(t, 1, readTestManifest(t, path).Incremental)
+
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+	require.Equal(t, map[string]string{"vol/a.txt": "a"}, s3Bodies(s3))
+	require.Equal(t, 0, readTestManifest(t, path).Incremental)
+}
+
+func TestSync_ManifestFallsBackToFull(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest func(t *testing.T, s *Syncer, dst string) []byte
+	}{
+		{name: "Corrupt", manifest: func(*testing.T, *Syncer, string) []byte {
+			return []byte("{not json")
+		}},
+		{name: "OtherDestination", manifest: func(t *testing.T, s *Syncer, dst string) []byte {
+			data, err := json.Marshal(&manifest{Key: s.manifestKey(dst + "/other"), Files: map[string]manifestFile{}})
+			require.NoError(t, err)
+			return data
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s3 := newFakeS3(t)
+			srcDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644))
+			path := filepath.Join(t.TempDir(), "vol.json")
+			s, err := New(ctx, WithManifest(path, 0))
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, tt.manifest(t, s, s3.remote("vol")), 0644))
+
+			require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+			require.Equal(t, map[string]string{"vol/a.txt": "a"}, s3Bodies(s3))
+			m := readTestManifest(t, path)
+			require.Equal(t, 0, m.Incremental)
+			require.Contains(t, m.Files, "a.txt")
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// objectsFs is a filesystem whose files are wrapped as they are listed, to
+// change how they behave in a sync.
+type objectsFs struct {
+	fs.Fs
+	wrap func(fs.Object) fs.Object
+}
+
+func (f *objectsFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	entries, err := f.Fs.List(ctx, dir)
+	return f.wrapEntries(entries), err
+}
+
+func (f *objectsFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, err := f.Fs.NewObject(ctx, remote)
+	if err != nil {
+		return nil, err
+	}
+	return f.wrap(o), nil
+}
+
+// Features passes the paged and recursive listings of the wrapped filesystem
+// through, wrapping the files they list too.
+func (f *objectsFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	if listP := ft.ListP; listP != nil {
+		ft.ListP = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			return listP(ctx, dir, func(entries fs.DirEntries) error {
+				return callback(f.wrapEntries(entries))
+			})
+		}
+	}
+	if listR := ft.ListR; listR != nil {
+		ft.ListR = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			return listR(ctx, dir, func(entries fs.DirEntries) error {
+				return callback(f.wrapEntries(entries))
+			})
+		}
+	}
+	return &ft
+}
+
+// wrapEntries wraps the files among entries, in place.
+func (f *objectsFs) wrapEntries(entries fs.DirEntries) fs.DirEntries {
+	for i, entry := range entries {
+		if o, ok := entry.(fs.Object); ok {
+			entries[i] = f.wrap(o)
+		}
+	}
+	return entries
+}
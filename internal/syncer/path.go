@@ -1,9 +1,36 @@
 package syncer
 
 import (
+	"fmt"
+	"path"
 	"path/filepath"
+	"strings"
+
+	"github.com/rclone/rclone/fs/fspath"
 )
 
-func JoinPath(base, sub string) string {
-	return filepath.Join(base, sub)
+// JoinPath appends a volume's subpath to the destination remote. The subpath
+// is always relative to the destination: its leading, trailing and repeated
+// slashes are dropped, and it may neither be empty nor climb out with "..".
+// The remote name is kept as is, so "s3:" and "vol" give "s3:vol" rather than
+// the "s3:/vol" a plain file path join would.
+func JoinPath(base, sub string) (string, error) {
+	cleaned := path.Clean("/" + filepath.ToSlash(sub))
+	if cleaned == "/" {
+		return "", fmt.Errorf("empty subpath %q", sub)
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(sub), "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("subpath %q must not contain ..", sub)
+		}
+	}
+
+	remoteName, remotePath, err := fspath.SplitFs(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid remote %s: %w", base, err)
+	}
+	if remoteName == "" {
+		return filepath.Join(base, filepath.FromSlash(cleaned)), nil
+	}
+	return remoteName + path.Join(remotePath, cleaned[1:]), nil
 }
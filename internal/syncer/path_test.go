@@ -0,0 +1,48 @@
+package syncer
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    string
+		sub     string
+		want    string
+		wantErr bool
+	}{
+		{name: "Remote", base: "s3:bucket/backups", sub: "db_data", want: "s3:bucket/backups/db_data"},
+		{name: "RemoteRoot", base: "s3:", sub: "db_data", want: "s3:db_data"},
+		{name: "TrailingSlashBase", base: "s3:bucket/", sub: "db_data", want: "s3:bucket/db_data"},
+		{name: "DoubleSlashes", base: "s3:bucket", sub: "a//b/c", want: "s3:bucket/a/b/c"},
+		{name: "LeadingAndTrailingSlashes", base: "s3:bucket", sub: "/a/b/", want: "s3:bucket/a/b"},
+		{name: "DotSegments", base: "s3:bucket", sub: "./a/./b", want: "s3:bucket/a/b"},
+		{name: "URLStyleRemote", base: "s3://bucket/backups/", sub: "db_data", want: "s3:/bucket/backups/db_data"},
+		{name: "OnTheFlyRemote", base: ":s3,provider=AWS:bucket", sub: "db_data", want: ":s3,provider=AWS:bucket/db_data"},
+		{name: "LocalPath", base: "/mnt/backups", sub: "a//b/", want: "/mnt/backups/a/b"},
+		// A subpath built with the OS separator still joins a remote with "/".
+		{name: "NativeSeparators", base: "s3:bucket", sub: filepath.Join("a", "b"), want: "s3:bucket/a/b"},
+		{name: "EmptySubpath", base: "s3:bucket", sub: "", wantErr: true},
+		{name: "SlashSubpath", base: "s3:bucket", sub: "//", wantErr: true},
+		{name: "ParentSegment", base: "s3:bucket/backups", sub: "../other", wantErr: true},
+		{name: "InnerParentSegment", base: "s3:bucket/backups", sub: "a/../../b", wantErr: true},
+		{name: "InvalidRemote", base: "::bucket", sub: "db_data", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JoinPath(tt.base, tt.sub)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package syncer
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// progressMinSize is the smallest file whose transfer progress is logged.
+// Smaller files transfer quickly enough for the per-file log line to do.
+const progressMinSize = 64 * 1024 * 1024
+
+// WithProgressInterval logs the progress of each large file every interval
+// while it is uploaded or downloaded, with the bytes moved so far, the
+// percentage of the file and the throughput. Zero, the default, turns it off.
+func WithProgressInterval(interval time.Duration) Option {
+	return func(s *Syncer) {
+		s.progressInterval = interval
+	}
+}
+
+// progressWrap returns a wrapper for the source files of a sync that logs the
+// progress of the large ones as they are read.
+func (s *Syncer) progressWrap(logger *slog.Logger, direction string) func(fs.Object) fs.Object {
+	return func(o fs.Object) fs.Object {
+		if o.Size() < progressMinSize {
+			return o
+		}
+		return &progressObject{Object: o, progress: &progress{
+			logger:   logger.With("key", o.Remote(), "direction", direction),
+			size:     o.Size(),
+			interval: s.progressInterval,
+			now:      s.now,
+		}}
+	}
+}
+
+// progressObject is a file whose reads are counted towards its progress.
+type progressObject struct {
+	fs.Object
+	progress *progress
+}
+
+func (o *progressObject) Open(ctx context.Context, options ...fs.OpenOption) (io.ReadCloser, error) {
+	rc, err := o.Object.Open(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	// A download split into parts opens each part separately, and they all
+	// count towards the file. Opening the whole file starts it over, as a
+	// retry does.
+	if expectedLength(o.progress.size, options) == o.progress.size {
+		o.progress.reset()
+	}
+	return &progressReader{ReadCloser: rc, progress: o.progress}, nil
+}
+
+// MimeType passes the wrapped file's content type through, so that sniffed
+// types still apply.
+func (o *progressObject) MimeType(ctx context.Context) string {
+	return fs.MimeType(ctx, o.Object)
+}
+
+// Metadata passes the wrapped file's metadata through, so that permissions
+// are still carried.
+func (o *progressObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return fs.GetMetadata(ctx, o.Object)
+}
+
+func (o *progressObject) UnWrap() fs.Object {
+	return o.Object
+}
+
+// progressReader counts what is read from it towards progress.
+type progressReader struct {
+	io.ReadCloser
+	progress *progress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.progress.add(int64(n))
+	return n, err
+}
+
+// progress tracks how much of a file has been transferred, logging it at most
+// once per interval.
+type progress struct {
+	logger   *slog.Logger
+	size     int64
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	done    int64
+	started time.Time
+	logged  time.Time
+}
+
+func (p *progress) reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done = 0
+	p.started = time.Time{}
+}
+
+func (p *progress) add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	if p.started.IsZero() {
+		p.started, p.logged = now, now
+	}
+	p.done += n
+	if now.Sub(p.logged) < p.interval {
+		return
+	}
+	p.logged = now
+	var rate int64
+	if elapsed := now.Sub(p.started).Seconds(); elapsed > 0 {
+		rate = int64(float64(p.done) / elapsed)
+	}
+	p.logger.Info("Transfer progress", "bytes", p.done, "size", p.size, "percent", p.done*100/p.size, "bytes_per_second", rate)
+}
@@ -0,0 +1,81 @@
+package syncer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress_LogsEveryInterval(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &progress{
+		logger:   slog.New(slog.NewJSONHandler(&buf, nil)),
+		size:     1000,
+		interval: 10 * time.Second,
+		now:      func() time.Time { return now },
+	}
+	r := &progressReader{ReadCloser: io.NopCloser(strings.NewReader(strings.Repeat("x", 1000))), progress: p}
+
+	// Each read of 100 bytes takes 4 seconds, so every third read crosses
+	// the interval.
+	chunk := make([]byte, 100)
+	for {
+		_, err := r.Read(chunk)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		now = now.Add(4 * time.Second)
+	}
+
+	type record struct {
+		Msg            string `json:"msg"`
+		Bytes          int64  `json:"bytes"`
+		Size           int64  `json:"size"`
+		Percent        int64  `json:"percent"`
+		BytesPerSecond int64  `json:"bytes_per_second"`
+	}
+	var updates []record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		require.NoError(t, dec.Decode(&r))
+		updates = append(updates, r)
+	}
+
+	require.Equal(t, []record{
+		{Msg: "Transfer progress", Bytes: 400, Size: 1000, Percent: 40, BytesPerSecond: 33},
+		{Msg: "Transfer progress", Bytes: 700, Size: 1000, Percent: 70, BytesPerSecond: 29},
+		{Msg: "Transfer progress", Bytes: 1000, Size: 1000, Percent: 100, BytesPerSecond: 27},
+	}, updates)
+}
+
+func TestProgress_ResetStartsOver(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := &progress{
+		logger:   slog.New(slog.NewJSONHandler(&buf, nil)),
+		size:     1000,
+		interval: time.Second,
+		now:      func() time.Time { return now },
+	}
+
+	p.add(600)
+	p.reset()
+	p.add(100)
+	now = now.Add(time.Second)
+	p.add(100)
+
+	var r struct {
+		Bytes int64 `json:"bytes"`
+	}
+	require.NoError(t, json.NewDecoder(&buf).Decode(&r))
+	require.Equal(t, int64(200), r.Bytes, "a retried download shouldn't count the first attempt")
+}
@@ -0,0 +1,25 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+)
+
+// LimitRequests caps the HTTP requests made to remotes at perSecond, spaced
+// out evenly rather than let through in bursts. List, get, put and delete
+// requests all count, as do the parts of multipart transfers. S3 throttles
+// the requests to a prefix with SlowDown errors once they come too fast,
+// however few files are in flight. The limit is shared by every syncer in
+// the process, so the requests of syncs running at the same time add up to
+// it. Zero leaves requests unlimited.
+func LimitRequests(ctx context.Context, perSecond float64) {
+	if perSecond <= 0 {
+		return
+	}
+	ci := fs.GetConfig(ctx)
+	ci.TPSLimit = perSecond
+	ci.TPSLimitBurst = 1
+	fshttp.StartHTTPTokenBucket(ctx)
+}
@@ -0,0 +1,39 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRequests(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	for i := range 5 {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, fmt.Sprintf("%d.txt", i)), []byte("data"), 0644))
+	}
+
+	const perSecond = 20
+	LimitRequests(ctx, perSecond)
+	// The limit is global, so lift it again for the other tests.
+	t.Cleanup(func() { LimitRequests(ctx, math.Inf(1)) })
+
+	s, err := New(ctx, WithConcurrency(16))
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+	elapsed := time.Since(start)
+
+	// However many files are in flight, the requests are spread out to no
+	// more than perSecond, the first going straight away.
+	requests := s3.requestCount()
+	require.Greater(t, requests, 5)
+	require.GreaterOrEqual(t, elapsed, time.Duration(requests-1)*time.Second/perSecond)
+}
@@ -0,0 +1,42 @@
+package syncer
+
+import (
+	"slices"
+
+	"github.com/rclone/rclone/fs/filter"
+)
+
+// reservedRules keep files the syncer writes for itself out of every sync,
+// whatever the filters: the partial downloads rclone names
+// "<name>.<8 random characters>.partial" and leaves behind when interrupted
+// by a crash.
+var reservedRules = []string{"- *.????????.partial"}
+
+// WithReserved names files at the root of the volume that are bookkeeping
+// rather than data, such as the sentinel marking a volume restored. Like the
+// syncer's own temporary files, they are left out of every sync, archive and
+// check in either direction, whatever the filters: a backup neither uploads
+// them nor deletes them from the destination, and a restore neither
+// downloads nor deletes them. So is a temporary copy of each, named
+// "<name>.*.tmp" as os.CreateTemp would.
+func WithReserved(names ...string) Option {
+	return func(s *Syncer) {
+		s.reserved = names
+	}
+}
+
+// newFilter builds the filter of a sync, with the reserved rules ahead of
+// the configured ones so that none of those can let a reserved file through.
+func (s *Syncer) newFilter() (*filter.Filter, error) {
+	return s.newFilterWith(s.filterOpt)
+}
+
+// newFilterWith is newFilter with opt in place of the configured options.
+func (s *Syncer) newFilterWith(opt filter.Options) (*filter.Filter, error) {
+	rules := slices.Clone(reservedRules)
+	for _, name := range s.reserved {
+		rules = append(rules, "- /"+name, "- /"+name+".*.tmp")
+	}
+	opt.FilterRule = append(rules, opt.FilterRule...)
+	return filter.NewFilter(&opt)
+}
@@ -0,0 +1,64 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync_Reserved(t *testing.T) {
+	ctx := context.Background()
+	writeTree := func(dir string, files map[string]string) {
+		for name, body := range files {
+			require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(body), 0644))
+		}
+	}
+	reserved := map[string]string{
+		".sentinel":                "src",
+		".sentinel.123.tmp":        "src",
+		"data.db.a1b2c3d4.partial": "src",
+	}
+
+	// The user's rules let everything through, and the sync deletes
+	// whatever the source doesn't have.
+	rules, err := BuildFilterRules(nil, []string{"**"}, nil)
+	require.NoError(t, err)
+	opt := filter.Opt
+	opt.FilterRule = rules
+	s, err := New(ctx, WithDelete(true), WithFilterOpt(opt), WithReserved(".sentinel"))
+	require.NoError(t, err)
+
+	t.Run("Backup", func(t *testing.T) {
+		srcDir, dstDir := t.TempDir(), t.TempDir()
+		writeTree(srcDir, map[string]string{"data.txt": "data", "sub/.sentinel": "nested"})
+		writeTree(srcDir, reserved)
+		writeTree(dstDir, map[string]string{".sentinel": "dst", "data.db.a1b2c3d4.partial": "dst"})
+
+		require.NoError(t, s.Sync(ctx, srcDir, dstDir))
+
+		// Reserved files are neither uploaded nor deleted. The names are
+		// only reserved at the root.
+		require.Equal(t, map[string]string{
+			"data.txt":                 "data",
+			"sub/.sentinel":            "nested",
+			".sentinel":                "dst",
+			"data.db.a1b2c3d4.partial": "dst",
+		}, readTree(t, dstDir))
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		remoteDir, volumeDir := t.TempDir(), t.TempDir()
+		writeTree(remoteDir, map[string]string{"data.txt": "data"})
+		writeTree(remoteDir, reserved)
+		writeTree(volumeDir, map[string]string{".sentinel": "local"})
+
+		require.NoError(t, s.Sync(ctx, remoteDir, volumeDir))
+
+		require.Equal(t, map[string]string{"data.txt": "data", ".sentinel": "local"}, readTree(t, volumeDir))
+	})
+}
@@ -0,0 +1,479 @@
+package syncer
+
+import (
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is just enough of a single S3 bucket, served path-style, for rclone
+// to sync to and from it. It records the headers of every upload.
+type fakeS3 struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mu      sync.Mutex
+	objects map[string]fakeS3Object
+	puts    []string
+	// copies records the server-side copies made, as "src -> dst".
+	copies []string
+
+	// deleteDelay holds up each delete, so that concurrent ones overlap, and
+	// maxDeletes records how many were ever in flight at once. Deletes of
+	// the keys in failDeletes fail. Both are set with slowDeletes.
+	deleteDelay time.Duration
+	failDeletes map[string]bool
+	deleting    atomic.Int32
+	maxDeletes  atomic.Int32
+	// requests counts every request served.
+	requests atomic.Int32
+
+	// listPageSize, when set, caps the keys in each page of a listing, which
+	// only pages correctly through directories without subdirectories, and
+	// onList, set with setOnList, is called with every page served.
+	// listPages counts the pages, as listCount returns.
+	listPageSize int
+	onList       func(page int)
+	listPages    int
+	// unlisted keys are left out of listings, as if their upload had been
+	// lost after it was acknowledged.
+	unlisted map[string]bool
+
+	// shortReads is how many more GETs of each key serve only the first half
+	// of its body, with a Content-Length to match, and gets counts the GETs
+	// of each key. They are set with shortenReads and read with getCount.
+	shortReads map[string]int
+	gets       map[string]int
+
+	// versions holds the history of each key, oldest first, as a versioned
+	// bucket would. It's only kept for the keys written with putVersion and
+	// deleteVersion.
+	versions map[string][]fakeS3Version
+}
+
+// fakeS3Version is a version of a key, or a delete marker.
+type fakeS3Version struct {
+	id      string
+	object  fakeS3Object
+	deleted bool
+}
+
+type fakeS3Object struct {
+	body     []byte
+	header   http.Header
+	modified time.Time
+}
+
+const fakeS3Bucket = "bucket"
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	f := &fakeS3{t: t, objects: map[string]fakeS3Object{}}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+// remote returns an on the fly rclone remote for path in the bucket.
+func (f *fakeS3) remote(path string) string {
+	return ":s3,provider=AWS,access_key_id=KEY,secret_access_key=secret,region=us-east-1," +
+		"force_path_style=true,no_check_bucket=true,use_unsigned_payload=true," +
+		"endpoint='" + f.srv.URL + "':" + fakeS3Bucket + "/" + path
+}
+
+// putVersion makes body the latest version of key, as of modified.
+func (f *fakeS3) putVersion(key, body string, modified time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj := fakeS3Object{body: []byte(body), header: http.Header{}, modified: modified}
+	f.objects[key] = obj
+	f.addVersion(key, fakeS3Version{object: obj})
+}
+
+// deleteVersion deletes key as of modified, leaving a delete marker.
+func (f *fakeS3) deleteVersion(key string, modified time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.objects, key)
+	f.addVersion(key, fakeS3Version{object: fakeS3Object{modified: modified}, deleted: true})
+}
+
+func (f *fakeS3) addVersion(key string, v fakeS3Version) {
+	if f.versions == nil {
+		f.versions = map[string][]fakeS3Version{}
+	}
+	v.id = fmt.Sprintf("v%d", len(f.versions[key])+1)
+	f.versions[key] = append(f.versions[key], v)
+}
+
+// version returns the version of key with the given id.
+func (f *fakeS3) version(key, id string) (fakeS3Object, bool) {
+	for _, v := range f.versions[key] {
+		if v.id == id && !v.deleted {
+			return v.object, true
+		}
+	}
+	return fakeS3Object{}, false
+}
+
+// uploaded returns the headers key was last uploaded with, or nil.
+func (f *fakeS3) uploaded(key string) http.Header {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, ok := f.objects[key]
+	if !ok {
+		return nil
+	}
+	return obj.header
+}
+
+// slowDeletes holds up each delete by delay and fails those of the keys in
+// failing.
+func (f *fakeS3) slowDeletes(delay time.Duration, failing ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleteDelay = delay
+	f.failDeletes = map[string]bool{}
+	for _, key := range failing {
+		f.failDeletes[key] = true
+	}
+}
+
+// setOnList has fn called with every page of a listing served.
+func (f *fakeS3) setOnList(fn func(page int)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onList = fn
+}
+
+// listCount returns the number of listing pages served so far.
+func (f *fakeS3) listCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.listPages
+}
+
+// shortenReads has the next n GETs of key serve only half of it.
+func (f *fakeS3) shortenReads(key string, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.shortReads == nil {
+		f.shortReads = map[string]int{}
+	}
+	f.shortReads[key] = n
+}
+
+// getCount returns the number of GETs of key so far.
+func (f *fakeS3) getCount(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.gets[key]
+}
+
+// requestCount returns the number of requests served so far.
+func (f *fakeS3) requestCount() int {
+	return int(f.requests.Load())
+}
+
+// putCount returns the number of uploads so far.
+func (f *fakeS3) putCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.puts)
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	f.requests.Add(1)
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+fakeS3Bucket), "/")
+	if r.Method == http.MethodDelete {
+		f.delete(w, key)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if key == "" && r.Method == http.MethodGet {
+		if r.URL.Query().Has("versions") {
+			f.listVersions(w, r)
+			return
+		}
+		f.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+			f.copy(w, r, source, key)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(f.t, err)
+		f.objects[key] = fakeS3Object{body: body, header: r.Header.Clone(), modified: time.Now().UTC()}
+		f.puts = append(f.puts, key)
+		w.Header().Set("ETag", etag(body))
+	case http.MethodHead, http.MethodGet:
+		obj, ok := f.objects[key]
+		if id := r.URL.Query().Get("versionId"); id != "" {
+			obj, ok = f.version(key, id)
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range obj.header {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+				w.Header()[name] = values
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.body)))
+		w.Header().Set("ETag", etag(obj.body))
+		w.Header().Set("Last-Modified", obj.modified.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			if f.gets == nil {
+				f.gets = map[string]int{}
+			}
+			f.gets[key]++
+			body, status := obj.body, http.StatusOK
+			if start, end, ok := parseRange(r.Header.Get("Range"), len(body)); ok {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, len(body)))
+				body, status = body[start:end], http.StatusPartialContent
+			}
+			if f.shortReads[key] > 0 {
+				f.shortReads[key]--
+				body = body[:len(body)/2]
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(status)
+			_, _ = w.Write(body)
+		}
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// copy answers a CopyObject request, keeping the source's metadata unless
+// the request replaces it.
+func (f *fakeS3) copy(w http.ResponseWriter, r *http.Request, source, key string) {
+	source, err := url.PathUnescape(source)
+	assert.NoError(f.t, err)
+	source = strings.TrimPrefix(strings.TrimPrefix(source, "/"), fakeS3Bucket+"/")
+	obj, ok := f.objects[source]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>Not Found</Message></Error>`)
+		return
+	}
+	if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+		obj.header = r.Header.Clone()
+	}
+	obj.modified = time.Now().UTC()
+	f.objects[key] = obj
+	f.copies = append(f.copies, source+" -> "+key)
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, `<CopyObjectResult><ETag>%s</ETag><LastModified>%s</LastModified></CopyObjectResult>`,
+		etag(obj.body), obj.modified.Format(time.RFC3339))
+}
+
+func (f *fakeS3) delete(w http.ResponseWriter, key string) {
+	n := f.deleting.Add(1)
+	defer f.deleting.Add(-1)
+	for {
+		peak := f.maxDeletes.Load()
+		if n <= peak || f.maxDeletes.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	f.mu.Lock()
+	delay := f.deleteDelay
+	f.mu.Unlock()
+	time.Sleep(delay)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failDeletes[key] {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		return
+	}
+	delete(f.objects, key)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// list answers a ListObjectsV2 request in a single page.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	type content struct {
+		Key          string
+		Size         int
+		ETag         string
+		LastModified string
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name                  string
+		Prefix                string
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+		Contents              []content
+		CommonPrefixes        []commonPrefix
+	}{Name: fakeS3Bucket}
+
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	after := r.URL.Query().Get("continuation-token")
+	result.Prefix = prefix
+
+	keys := make([]string, 0, len(f.objects))
+	for key := range f.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || key <= after || f.unlisted[key] {
+			continue
+		}
+		if f.listPageSize > 0 && len(result.Contents)+len(result.CommonPrefixes) == f.listPageSize {
+			result.IsTruncated = true
+			result.NextContinuationToken = after
+			break
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				dir := key[:len(prefix)+i+len(delimiter)]
+				if !seen[dir] {
+					seen[dir] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{dir})
+				}
+				continue
+			}
+		}
+		after = key
+		obj := f.objects[key]
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			Size:         len(obj.body),
+			ETag:         etag(obj.body),
+			LastModified: obj.modified.Format(time.RFC3339),
+		})
+	}
+	f.listPages++
+	if f.onList != nil {
+		f.onList(f.listPages)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	assert.NoError(f.t, xml.NewEncoder(w).Encode(result))
+}
+
+// listVersions answers a ListObjectVersions request in a single page, for the
+// keys with a history. Like S3, it sorts them by key and then newest first.
+func (f *fakeS3) listVersions(w http.ResponseWriter, r *http.Request) {
+	type version struct {
+		Key          string
+		VersionId    string
+		IsLatest     bool
+		LastModified string
+		ETag         string `xml:",omitempty"`
+		Size         int    `xml:",omitempty"`
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	result := struct {
+		XMLName        xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListVersionsResult"`
+		Name           string
+		Prefix         string
+		IsTruncated    bool
+		Version        []version
+		DeleteMarker   []version
+		CommonPrefixes []commonPrefix
+	}{Name: fakeS3Bucket}
+
+	prefix := r.URL.Query().Get("prefix")
+	delimiter := r.URL.Query().Get("delimiter")
+	result.Prefix = prefix
+
+	keys := make([]string, 0, len(f.versions))
+	for key := range f.versions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				dir := key[:len(prefix)+i+len(delimiter)]
+				if !seen[dir] {
+					seen[dir] = true
+					result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{dir})
+				}
+				continue
+			}
+		}
+		history := f.versions[key]
+		for i := len(history) - 1; i >= 0; i-- {
+			v := history[i]
+			entry := version{
+				Key:          key,
+				VersionId:    v.id,
+				IsLatest:     i == len(history)-1,
+				LastModified: v.object.modified.Format(time.RFC3339),
+			}
+			if v.deleted {
+				result.DeleteMarker = append(result.DeleteMarker, entry)
+				continue
+			}
+			entry.ETag = etag(v.object.body)
+			entry.Size = len(v.object.body)
+			result.Version = append(result.Version, entry)
+		}
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	assert.NoError(f.t, xml.NewEncoder(w).Encode(result))
+}
+
+// parseRange parses a Range header of the form "bytes=start-" or
+// "bytes=start-end" against a body of size bytes, returning the half-open
+// span it asks for.
+func parseRange(header string, size int) (start, end int, ok bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return 0, 0, false
+	}
+	from, to, _ := strings.Cut(spec, "-")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return 0, 0, false
+	}
+	end = size
+	if to != "" {
+		last, err := strconv.Atoi(to)
+		if err != nil {
+			return 0, 0, false
+		}
+		end = min(last+1, size)
+	}
+	return min(start, end), end, true
+}
+
+func etag(body []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(body))
+}
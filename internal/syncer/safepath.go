@@ -0,0 +1,72 @@
+package syncer
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// safePathFs is a remote restored from that leaves out the files and
+// directories whose names wouldn't map to a path inside the directory restored
+// to, logging each. A key such as "vol/../../etc/passwd" would otherwise be
+// written outside the volume. rclone's S3 backend already encodes "." and ".."
+// segments by default, but not with every encoding nor on every backend.
+type safePathFs struct {
+	fs.Fs
+	logger *slog.Logger
+}
+
+func (f *safePathFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	entries, err := f.Fs.List(ctx, dir)
+	return f.safeEntries(entries), err
+}
+
+func (f *safePathFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	if !safePath(remote) {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return f.Fs.NewObject(ctx, remote)
+}
+
+// Features passes the paged and recursive listings of the wrapped filesystem
+// through, leaving out unsafe names from them too.
+func (f *safePathFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	if listP := ft.ListP; listP != nil {
+		ft.ListP = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			return listP(ctx, dir, func(entries fs.DirEntries) error {
+				return callback(f.safeEntries(entries))
+			})
+		}
+	}
+	if listR := ft.ListR; listR != nil {
+		ft.ListR = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			return listR(ctx, dir, func(entries fs.DirEntries) error {
+				return callback(f.safeEntries(entries))
+			})
+		}
+	}
+	return &ft
+}
+
+// safeEntries drops the entries with unsafe names, in place.
+func (f *safePathFs) safeEntries(entries fs.DirEntries) fs.DirEntries {
+	return slices.DeleteFunc(entries, func(entry fs.DirEntry) bool {
+		if safePath(entry.Remote()) {
+			return false
+		}
+		f.logger.Warn("Skipping file with unsafe path", "key", entry.Remote())
+		return true
+	})
+}
+
+// safePath reports whether remote names a path inside the directory it is
+// relative to: it must not be absolute, nor contain a ".." segment, even one
+// that would stay inside.
+func safePath(remote string) bool {
+	return filepath.IsLocal(filepath.FromSlash(remote)) && !slices.Contains(strings.Split(remote, "/"), "..")
+}
@@ -0,0 +1,66 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafePath(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   bool
+	}{
+		{remote: "file.txt", want: true},
+		{remote: "dir/file.txt", want: true},
+		{remote: "dir/..file", want: true},
+		{remote: "..", want: false},
+		{remote: "../../etc/passwd", want: false},
+		{remote: "dir/../../escape", want: false},
+		{remote: "dir/../file.txt", want: false},
+		{remote: "/etc/passwd", want: false},
+		{remote: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			assert.Equal(t, tt.want, safePath(tt.remote))
+		})
+	}
+}
+
+func TestSync_SkipsUnsafeKeys(t *testing.T) {
+	ctx := context.Background()
+	f := newFakeS3(t)
+	now := time.Now()
+	f.objects["vol/ok.txt"] = fakeS3Object{body: []byte("ok"), modified: now}
+	f.objects["vol/dir/fine.txt"] = fakeS3Object{body: []byte("fine"), modified: now}
+	f.objects["vol/../../escape.txt"] = fakeS3Object{body: []byte("escaped"), modified: now}
+	f.objects["vol/dir/../../../escape2.txt"] = fakeS3Object{body: []byte("escaped"), modified: now}
+
+	var buf bytes.Buffer
+	s, err := New(ctx, WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+	require.NoError(t, err)
+
+	// Restore a few levels down, so that anything climbing out lands in root.
+	root := t.TempDir()
+	dstDir := filepath.Join(root, "a", "b", "restore")
+	require.NoError(t, os.MkdirAll(dstDir, 0755))
+	// rclone's S3 backend encodes "." and ".." segments by default, so turn
+	// that off to get the names as they are.
+	remote := strings.Replace(f.remote("vol"), ":s3,", ":s3,encoding='Slash,InvalidUtf8',", 1)
+	require.NoError(t, s.Sync(ctx, remote, dstDir))
+
+	require.Equal(t, map[string]string{
+		"a/b/restore/ok.txt":       "ok",
+		"a/b/restore/dir/fine.txt": "fine",
+	}, readTree(t, root))
+	assert.Contains(t, buf.String(), "Skipping file with unsafe path")
+}
@@ -0,0 +1,55 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+)
+
+// Signature sums up the files a sync from src would consider, by path, size
+// and modification time, along with the filter selecting them. Anything a
+// sync from src could transfer or delete changes it, short of a file
+// rewritten with the same size and time, and working it out only lists src,
+// so comparing it with the one taken at the last sync tells cheaply whether
+// another is needed. Empty directories and permissions aren't part of it.
+func (s *Syncer) Signature(ctx context.Context, src string) (string, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer end()
+
+	ctx = s.withConfig(ctx)
+	fi, err := s.filterFor("upload")
+	if err != nil {
+		return "", fmt.Errorf("failed to create filter: %w", err)
+	}
+	ctx = filter.ReplaceConfig(ctx, fi)
+	srcRemote, err := s.symlinkOptions(src)
+	if err != nil {
+		return "", err
+	}
+	srcFs, err := fs.NewFs(ctx, srcRemote)
+	if err != nil {
+		return "", fmt.Errorf("failed to create source fs: %w", err)
+	}
+	srcFs = s.wrapSymlinks(s.logger.With("src", src), srcFs)
+	files, err := listManifestFiles(ctx, srcFs)
+	if err != nil {
+		return "", fmt.Errorf("failed to list source: %w", err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%q\n", s.filterOpt.FilterRule)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		f := files[name]
+		fmt.Fprintf(h, "%q %d %d\n", name, f.Size, f.ModTime.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
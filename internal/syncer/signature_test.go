@@ -0,0 +1,69 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(t *testing.T, dir, name, data string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	f := filter.Opt
+	f.MinAge = fs.DurationOff
+	f.MaxAge = fs.DurationOff
+	f.FilterRule = []string{"- *.log"}
+	s, err := New(ctx, WithFilterOpt(f), WithReserved(".volumesync_done"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		change  func(t *testing.T, dir string)
+		changed bool
+	}{
+		{name: "Untouched", change: func(t *testing.T, dir string) {}},
+		{name: "Rewritten", change: func(t *testing.T, dir string) { write(t, dir, "a.txt", "a", at) }},
+		{name: "Excluded", change: func(t *testing.T, dir string) { write(t, dir, "app.log", "more", at) }},
+		{name: "Reserved", change: func(t *testing.T, dir string) { write(t, dir, ".volumesync_done", "now", at) }},
+		{name: "EmptyDir", change: func(t *testing.T, dir string) { require.NoError(t, os.Mkdir(filepath.Join(dir, "empty"), 0755)) }},
+		{name: "Touched", change: func(t *testing.T, dir string) { write(t, dir, "a.txt", "a", at.Add(time.Second)) }, changed: true},
+		{name: "Resized", change: func(t *testing.T, dir string) { write(t, dir, "sub/b.txt", "bigger", at) }, changed: true},
+		{name: "Added", change: func(t *testing.T, dir string) { write(t, dir, "c.txt", "c", at) }, changed: true},
+		{name: "Removed", change: func(t *testing.T, dir string) { require.NoError(t, os.Remove(filepath.Join(dir, "a.txt"))) }, changed: true},
+		{name: "Renamed", change: func(t *testing.T, dir string) {
+			require.NoError(t, os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "z.txt")))
+		}, changed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			write(t, dir, "a.txt", "a", at)
+			write(t, dir, "sub/b.txt", "b", at)
+			write(t, dir, "app.log", "log", at)
+			before, err := s.Signature(ctx, dir)
+			require.NoError(t, err)
+
+			tt.change(t, dir)
+			after, err := s.Signature(ctx, dir)
+			require.NoError(t, err)
+			if tt.changed {
+				require.NotEqual(t, before, after)
+			} else {
+				require.Equal(t, before, after)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+const (
+	// snapshotDir is where snapshots go, under the destination they back up.
+	snapshotDir = "snapshots"
+	// snapshotDoneSuffix names the object written beside a snapshot once it
+	// completes, so that one cut short is never restored.
+	snapshotDoneSuffix = ".done"
+)
+
+// Snapshot backs the local directory src up to a new snapshot at dst: a full
+// copy of it under a prefix named after the UTC time of the backup, such as
+// snapshots/2024-01-02T03:04:05, which later backups leave alone. Files
+// unchanged since the previous snapshot are copied from it server-side where
+// the destination supports it, rather than uploaded again. Otherwise it syncs
+// as SyncWithStats does. Older snapshots are then pruned as set by
+// WithRetention; failing to prune them doesn't fail the backup.
+func (s *Syncer) Snapshot(ctx context.Context, src, dst string) (Stats, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer end()
+
+	dstFs, err := fs.NewFs(ctx, dst)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create destination fs: %w", err)
+	}
+	snapshots, err := listSnapshots(ctx, dstFs)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	name := s.now().UTC().Format(archiveTimeFormat)
+	target, err := snapshotPath(dst, name)
+	if err != nil {
+		return Stats{}, err
+	}
+	if len(snapshots) > 0 && dstFs.Features().Copy != nil {
+		previous, err := snapshotPath(dst, snapshots[len(snapshots)-1])
+		if err != nil {
+			return Stats{}, err
+		}
+		var ci *fs.ConfigInfo
+		ctx, ci = fs.AddConfig(ctx)
+		ci.CopyDest = []string{previous}
+	}
+
+	stats, err := s.SyncWithStats(ctx, src, target)
+	if err != nil {
+		return stats, err
+	}
+	done := path.Join(snapshotDir, name+snapshotDoneSuffix)
+	if _, err := operations.Rcat(ctx, dstFs, done, io.NopCloser(strings.NewReader("")), time.Now(), nil); err != nil {
+		return stats, fmt.Errorf("failed to mark snapshot %s complete: %w", name, err)
+	}
+	if err := s.pruneSnapshots(ctx, dstFs, s.now()); err != nil {
+		s.logger.Error("Failed to prune old snapshots", "dst", dst, "error", err)
+	}
+	return stats, nil
+}
+
+// pruneSnapshots deletes the completed snapshots in dstFs that WithRetention
+// doesn't keep as of now, logging each one. Snapshots cut short are left
+// alone, as they are never restored anyway.
+func (s *Syncer) pruneSnapshots(ctx context.Context, dstFs fs.Fs, now time.Time) error {
+	if s.retentionCount == 0 && s.retentionAge == 0 {
+		return nil
+	}
+	snapshots, err := listSnapshots(ctx, dstFs)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, name := range snapshots[:max(len(snapshots)-1, 0)] {
+		// listSnapshots only returns names that parse.
+		t, _ := time.Parse(archiveTimeFormat, name)
+		if !s.expired(len(snapshots)-1-i, t, now) {
+			continue
+		}
+		s.logger.Info("Pruning snapshot", "snapshot", name, "time", t)
+		if err := deleteSnapshot(ctx, dstFs, name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete snapshot %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deleteSnapshot deletes the snapshot name from f. Its marker goes first, so
+// that a snapshot only partly deleted is never restored.
+func deleteSnapshot(ctx context.Context, f fs.Fs, name string) error {
+	done, err := f.NewObject(ctx, path.Join(snapshotDir, name+snapshotDoneSuffix))
+	if err != nil {
+		return err
+	}
+	if err := operations.DeleteFile(ctx, done); err != nil {
+		return err
+	}
+	err = operations.Purge(ctx, f, path.Join(snapshotDir, name))
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		// A snapshot of an empty volume holds no files.
+		return nil
+	}
+	return err
+}
+
+// RestoreSnapshot restores the local directory dst from the snapshot at src
+// taken at the time at, written as the snapshot is named, or from the latest
+// one when at is empty. Only snapshots that completed are considered. With no
+// snapshots at all there is nothing to restore, which isn't an error.
+func (s *Syncer) RestoreSnapshot(ctx context.Context, src, dst, at string) (Stats, error) {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer end()
+
+	srcFs, err := fs.NewFs(ctx, src)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create source fs: %w", err)
+	}
+	snapshots, err := listSnapshots(ctx, srcFs)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	switch {
+	case at != "" && !slices.Contains(snapshots, at):
+		return Stats{}, fmt.Errorf("snapshot %s not found", at)
+	case at != "":
+	case len(snapshots) == 0:
+		s.logger.Info("No snapshot to restore", "src", src, "dst", dst)
+		return Stats{}, nil
+	default:
+		at = snapshots[len(snapshots)-1]
+	}
+
+	s.logger.Info("Restoring snapshot", "snapshot", at, "dst", dst)
+	from, err := snapshotPath(src, at)
+	if err != nil {
+		return Stats{}, err
+	}
+	return s.SyncWithStats(ctx, from, dst)
+}
+
+// snapshotPath returns the remote of the snapshot name under remote.
+func snapshotPath(remote, name string) (string, error) {
+	return JoinPath(remote, path.Join(snapshotDir, name))
+}
+
+// listSnapshots returns the names of the completed snapshots in f, oldest
+// first.
+func listSnapshots(ctx context.Context, f fs.Fs) ([]string, error) {
+	entries, err := f.List(ctx, snapshotDir)
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snapshots []string
+	for _, entry := range entries {
+		if _, ok := entry.(fs.Object); !ok {
+			continue
+		}
+		name, ok := strings.CutSuffix(path.Base(entry.Remote()), snapshotDoneSuffix)
+		if !ok {
+			continue
+		}
+		if _, err := time.Parse(archiveTimeFormat, name); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, name)
+	}
+	// The names sort in time order.
+	slices.Sort(snapshots)
+	return snapshots, nil
+}
@@ -0,0 +1,190 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote string
+		want   string
+	}{
+		{name: "Local", remote: "/backups/vol", want: "/backups/vol/snapshots/2024-01-02T03:04:05"},
+		{name: "Remote", remote: "s3:bucket/vol", want: "s3:bucket/vol/snapshots/2024-01-02T03:04:05"},
+		{name: "BucketRoot", remote: "s3:bucket", want: "s3:bucket/snapshots/2024-01-02T03:04:05"},
+		{name: "OnTheFly", remote: ":s3,region=eu-west-1:bucket/vol", want: ":s3,region=eu-west-1:bucket/vol/snapshots/2024-01-02T03:04:05"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := snapshotPath(tt.remote, "2024-01-02T03:04:05")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// snapshotAt backs src up to dst as a snapshot taken at the time at.
+func snapshotAt(t *testing.T, s *Syncer, src, dst string, at time.Time) Stats {
+	t.Helper()
+	s.now = func() time.Time { return at }
+	stats, err := s.Snapshot(context.Background(), src, dst)
+	require.NoError(t, err)
+	return stats
+}
+
+func TestSnapshot_RestoresByTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+	require.NoError(t, os.MkdirAll(srcDir, 0755))
+	require.NoError(t, os.MkdirAll(dstDir, 0755))
+
+	s, err := New(context.Background(), WithDelete(true))
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("first"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "removed.txt"), []byte("gone later"), 0644))
+	snapshotAt(t, s, srcDir, dstDir, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("second"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "removed.txt")))
+	// The time is kept in UTC whatever the zone it is given in.
+	snapshotAt(t, s, srcDir, dstDir, time.Date(2024, 1, 3, 4, 4, 5, 0, time.FixedZone("CET", 3600)))
+
+	// Deleting a file only drops it from the next snapshot.
+	require.FileExists(t, filepath.Join(dstDir, "snapshots", "2024-01-02T03:04:05", "removed.txt"))
+	require.NoFileExists(t, filepath.Join(dstDir, "snapshots", "2024-01-03T03:04:05", "removed.txt"))
+	require.FileExists(t, filepath.Join(dstDir, "snapshots", "2024-01-03T03:04:05.done"))
+
+	// A snapshot cut short has no marker, so isn't restored however new.
+	require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "snapshots", "2024-01-04T03:04:05"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "snapshots", "2024-01-04T03:04:05", "data.txt"), []byte("partial"), 0644))
+
+	tests := []struct {
+		name        string
+		at          string
+		want        string
+		wantRemoved bool
+		wantErr     string
+	}{
+		{name: "Latest", want: "second"},
+		{name: "ByTime", at: "2024-01-02T03:04:05", want: "first", wantRemoved: true},
+		{name: "Incomplete", at: "2024-01-04T03:04:05", wantErr: "snapshot 2024-01-04T03:04:05 not found"},
+		{name: "Unknown", at: "2023-12-31T00:00:00", wantErr: "snapshot 2023-12-31T00:00:00 not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restoreDir := t.TempDir()
+			_, err := s.RestoreSnapshot(context.Background(), dstDir, restoreDir, tt.at)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(filepath.Join(restoreDir, "data.txt"))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(got))
+			if tt.wantRemoved {
+				require.FileExists(t, filepath.Join(restoreDir, "removed.txt"))
+			} else {
+				require.NoFileExists(t, filepath.Join(restoreDir, "removed.txt"))
+			}
+		})
+	}
+}
+
+func TestSnapshot_NothingToRestore(t *testing.T) {
+	s, err := New(context.Background())
+	require.NoError(t, err)
+
+	restoreDir := t.TempDir()
+	stats, err := s.RestoreSnapshot(context.Background(), filepath.Join(t.TempDir(), "missing"), restoreDir, "")
+	require.NoError(t, err)
+	require.Zero(t, stats.Transfers)
+	entries, err := os.ReadDir(restoreDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+// TestSnapshot_CopiesUnchangedServerSide checks that a snapshot to S3 only
+// uploads what changed since the previous one.
+func TestSnapshot_CopiesUnchangedServerSide(t *testing.T) {
+	f := newFakeS3(t)
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "same.txt"), []byte("unchanged"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "changed.txt"), []byte("before"), 0644))
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	snapshotAt(t, s, srcDir, f.remote("vol"), time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.Equal(t, 3, f.putCount(), "two files and the marker")
+
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "changed.txt"), []byte("after"), 0644))
+	snapshotAt(t, s, srcDir, f.remote("vol"), time.Date(2024, 1, 3, 3, 4, 5, 0, time.UTC))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.Equal(t, []string{
+		"vol/snapshots/2024-01-03T03:04:05/changed.txt",
+		"vol/snapshots/2024-01-03T03:04:05.done",
+	}, f.puts[3:])
+	require.Equal(t, []string{"vol/snapshots/2024-01-02T03:04:05/same.txt -> vol/snapshots/2024-01-03T03:04:05/same.txt"}, f.copies)
+	require.Equal(t, "unchanged", string(f.objects["vol/snapshots/2024-01-03T03:04:05/same.txt"].body))
+	require.Equal(t, "after", string(f.objects["vol/snapshots/2024-01-03T03:04:05/changed.txt"].body))
+}
+
+func TestSnapshot_PrunesAfterBackup(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.txt"), []byte("data"), 0644))
+
+	s, err := New(context.Background(), WithRetention(2, 0))
+	require.NoError(t, err)
+	snapshotAt(t, s, srcDir, dstDir, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	// A snapshot cut short isn't counted, nor deleted.
+	require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "snapshots", "2024-01-01T12:00:00"), 0755))
+	snapshotAt(t, s, srcDir, dstDir, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	snapshotAt(t, s, srcDir, dstDir, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+
+	entries, err := os.ReadDir(filepath.Join(dstDir, "snapshots"))
+	require.NoError(t, err)
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	require.Equal(t, []string{
+		"2024-01-01T12:00:00",
+		"2024-01-02T00:00:00",
+		"2024-01-02T00:00:00.done",
+		"2024-01-03T00:00:00",
+		"2024-01-03T00:00:00.done",
+	}, got)
+}
+
+func TestSnapshot_PruneByAge(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+	// An empty volume makes snapshots with no files at all.
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	for _, day := range []int{1, 5, 9} {
+		snapshotAt(t, s, srcDir, dstDir, time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC))
+	}
+
+	s.retentionAge = 72 * time.Hour
+	dstFs, err := fs.NewFs(context.Background(), dstDir)
+	require.NoError(t, err)
+	require.NoError(t, s.pruneSnapshots(context.Background(), dstFs, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)))
+
+	snapshots, err := listSnapshots(context.Background(), dstFs)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2024-01-09T00:00:00"}, snapshots)
+}
@@ -0,0 +1,68 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+var (
+	// ErrSourceMissing is returned by a sync or archive from a local
+	// directory that doesn't exist, such as a volume that isn't mounted.
+	ErrSourceMissing = errors.New("source directory does not exist")
+	// ErrSourceEmpty is returned by a sync or archive from a local directory
+	// with no files in it, see WithRequireNonEmptySource.
+	ErrSourceEmpty = errors.New("source directory is empty")
+)
+
+// WithRequireNonEmptySource refuses a sync or archive from a local directory
+// holding no files the filters let through, before it changes anything. This
+// keeps a volume that failed to mount, and so shows up as an empty directory,
+// from wiping out its backup. The check costs a listing of the source, cut
+// short at the first file.
+func WithRequireNonEmptySource(require bool) Option {
+	return func(s *Syncer) {
+		s.nonEmptySource = require
+	}
+}
+
+// errFoundFile stops the listing of checkSource at the first file.
+var errFoundFile = errors.New("found a file")
+
+// checkSource fails a sync from the local directory of srcFs, with the
+// filter in ctx, when the directory doesn't exist or, with
+// WithRequireNonEmptySource, holds no files. Remote sources are left alone:
+// an empty one is a restore with nothing to restore yet.
+func (s *Syncer) checkSource(ctx context.Context, srcFs fs.Fs) error {
+	if !srcFs.Features().IsLocal {
+		return nil
+	}
+	if _, err := os.Stat(srcFs.Root()); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrSourceMissing, srcFs.Root())
+	} else if err != nil {
+		return fmt.Errorf("failed to read source directory: %w", err)
+	}
+	if !s.nonEmptySource {
+		return nil
+	}
+
+	err := walk.ListR(ctx, srcFs, "", false, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			if _, ok := entry.(fs.Object); ok {
+				return errFoundFile
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errFoundFile):
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to list source directory: %w", err)
+	}
+	return fmt.Errorf("%w: %s", ErrSourceEmpty, srcFs.Root())
+}
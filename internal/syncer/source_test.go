@@ -0,0 +1,102 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync_MissingSource(t *testing.T) {
+	ctx := context.Background()
+	dstDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "data.txt"), []byte("backed up"), 0644))
+	missing := filepath.Join(t.TempDir(), "volume")
+
+	s, err := New(ctx, WithDelete(true))
+	require.NoError(t, err)
+	_, err = s.SyncWithStats(ctx, missing, dstDir)
+	require.ErrorIs(t, err, ErrSourceMissing)
+	require.ErrorContains(t, err, missing)
+
+	_, err = s.Archive(ctx, missing, dstDir)
+	require.ErrorIs(t, err, ErrSourceMissing)
+
+	require.Equal(t, map[string]string{"data.txt": "backed up"}, readTree(t, dstDir))
+}
+
+func TestSync_EmptySource(t *testing.T) {
+	ctx := context.Background()
+	// Only holds what the filters leave out, so counts as empty.
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, ".sentinel"), []byte("done"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "sub", "app.log"), []byte("log"), 0644))
+	rules, err := BuildFilterRules([]string{"*.log"}, nil, nil)
+	require.NoError(t, err)
+	opt := filter.Opt
+	opt.FilterRule = rules
+
+	tests := []struct {
+		name     string
+		nonEmpty bool
+		wantErr  error
+		want     map[string]string
+	}{
+		{name: "Allowed", want: map[string]string{}},
+		{name: "Refused", nonEmpty: true, wantErr: ErrSourceEmpty, want: map[string]string{"data.txt": "backed up"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dstDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dstDir, "data.txt"), []byte("backed up"), 0644))
+
+			s, err := New(ctx, WithDelete(true), WithFilterOpt(opt), WithReserved(".sentinel"), WithRequireNonEmptySource(tt.nonEmpty))
+			require.NoError(t, err)
+			_, err = s.SyncWithStats(ctx, srcDir, dstDir)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, readTree(t, dstDir))
+		})
+	}
+}
+
+func TestSync_NonEmptySource(t *testing.T) {
+	ctx := context.Background()
+	srcDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "a", "b"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a", "b", "data.txt"), []byte("data"), 0644))
+	dstDir := t.TempDir()
+
+	s, err := New(ctx, WithRequireNonEmptySource(true))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, dstDir))
+	require.Equal(t, map[string]string{"a/b/data.txt": "data"}, readTree(t, dstDir))
+}
+
+func TestRestore_CreatesMissingDestination(t *testing.T) {
+	ctx := context.Background()
+	volume := filepath.Join(t.TempDir(), "not", "mounted", "yet")
+	s, err := New(ctx, WithDelete(true))
+	require.NoError(t, err)
+
+	// Even with nothing to restore, the volume is there afterwards.
+	remote, err := fs.NewFs(ctx, ":memory:empty")
+	require.NoError(t, err)
+	require.NoError(t, remote.Mkdir(ctx, ""))
+	_, err = s.SyncWithStats(ctx, ":memory:empty", volume)
+	require.NoError(t, err)
+	require.DirExists(t, volume)
+
+	archived := filepath.Join(t.TempDir(), "archived")
+	_, err = s.RestoreArchive(ctx, t.TempDir(), archived)
+	require.NoError(t, err)
+	require.DirExists(t, archived)
+}
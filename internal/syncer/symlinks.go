@@ -0,0 +1,186 @@
+package syncer
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Local backend options choosing what becomes of symlinks in a local source.
+const (
+	// copyLinksOption follows symlinks, listing each as the file or
+	// directory it points to.
+	copyLinksOption = "copy_links"
+	// skipLinksOption quietens the notice the local backend logs for each
+	// symlink it skips, symlinkFs logging them instead.
+	skipLinksOption = "skip_links"
+)
+
+// WithFollowSymlinks has a sync or archive from a local path follow
+// symlinks, backing up the file or directory each points to in its place.
+// A symlinked directory leading back to one of its own ancestors is skipped
+// with a warning, rather than walked into over and over. Restores bring the
+// files back as plain files and directories. It can't be combined with
+// WithPreserveSymlinks, which takes precedence.
+func WithFollowSymlinks(follow bool) Option {
+	return func(s *Syncer) {
+		s.followSymlinks = follow
+	}
+}
+
+// symlinkOptions sets the local backend options on src that have it follow
+// symlinks, or skip them quietly, unless they are preserved. Remotes other
+// than local paths are left as they are.
+func (s *Syncer) symlinkOptions(src string) (string, error) {
+	switch {
+	case s.preserveSymlinks:
+		return src, nil
+	case s.followSymlinks:
+		return setBackendOptions(src, backendOption{copyLinksOption, "true"})
+	default:
+		return setBackendOptions(src, backendOption{skipLinksOption, "true"})
+	}
+}
+
+// wrapSymlinks wraps the local source srcFs to log the symlinks it skips or,
+// following them, to leave out those looping back on themselves.
+func (s *Syncer) wrapSymlinks(logger *slog.Logger, srcFs fs.Fs) fs.Fs {
+	if s.preserveSymlinks || !srcFs.Features().IsLocal {
+		return srcFs
+	}
+	return &symlinkFs{Fs: srcFs, logger: logger, follow: s.followSymlinks, loops: newLoopDetector(srcFs.Root())}
+}
+
+// symlinkFs is a local source whose symlinks the local backend either skips,
+// each of which it logs, or follows, in which case it leaves out the
+// directories that are their own ancestors. The local backend would
+// otherwise follow such a loop until the path grew too long to stat.
+type symlinkFs struct {
+	fs.Fs
+	logger *slog.Logger
+	follow bool
+	loops  *loopDetector
+}
+
+func (f *symlinkFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	f.logSkipped(dir)
+	entries, err := f.Fs.List(ctx, dir)
+	if err != nil {
+		return entries, err
+	}
+	return f.checkEntries(dir, entries), nil
+}
+
+// Features passes the paged listing of the wrapped filesystem through,
+// checked the same way. The local backend has no recursive listing.
+func (f *symlinkFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	if listP := ft.ListP; listP != nil {
+		ft.ListP = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			f.logSkipped(dir)
+			return listP(ctx, dir, func(entries fs.DirEntries) error {
+				return callback(f.checkEntries(dir, entries))
+			})
+		}
+	}
+	return &ft
+}
+
+// checkEntries drops the directory entries that loop, in place, when
+// following symlinks.
+func (f *symlinkFs) checkEntries(dir string, entries fs.DirEntries) fs.DirEntries {
+	if !f.follow {
+		return entries
+	}
+	return slices.DeleteFunc(entries, func(entry fs.DirEntry) bool {
+		if _, ok := entry.(fs.Directory); !ok {
+			return false
+		}
+		loop, err := f.loops.loops(entry.Remote())
+		if err != nil {
+			// Let the listing of the directory report it.
+			return false
+		}
+		if loop {
+			f.logger.Warn("Skipping symlink loop", "dir", entry.Remote())
+		}
+		return loop
+	})
+}
+
+// logSkipped logs each symlink in dir when not following them, as the local
+// backend leaves them out of its listing. Listing the names again is cheap
+// next to reading the files, and a subtree missing from a backup should
+// never go unnoticed.
+func (f *symlinkFs) logSkipped(dir string) {
+	if f.follow {
+		return
+	}
+	names, err := os.ReadDir(filepath.Join(f.Root(), filepath.FromSlash(dir)))
+	if err != nil {
+		return
+	}
+	for _, d := range names {
+		if d.Type()&os.ModeSymlink != 0 {
+			f.logger.Info("Skipping symlink", "file", path.Join(dir, d.Name()))
+		}
+	}
+}
+
+// loopDetector tells the directories under root, reached through symlinks,
+// that are one of their own ancestors. The file each directory visited
+// resolves to is kept, so that each is only looked up once.
+type loopDetector struct {
+	root string
+
+	mu      sync.Mutex
+	visited map[string]os.FileInfo
+}
+
+func newLoopDetector(root string) *loopDetector {
+	return &loopDetector{root: root, visited: map[string]os.FileInfo{}}
+}
+
+// loops reports whether the directory at rel, a slash separated path
+// relative to root, is the same directory as one of its ancestors.
+func (d *loopDetector) loops(rel string) (bool, error) {
+	info, err := d.stat(rel)
+	if err != nil {
+		return false, err
+	}
+	for parent := rel; parent != "."; {
+		parent = path.Dir(parent)
+		ancestor, err := d.stat(parent)
+		if err != nil {
+			return false, err
+		}
+		if os.SameFile(info, ancestor) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stat returns what rel resolves to, following symlinks.
+func (d *loopDetector) stat(rel string) (os.FileInfo, error) {
+	d.mu.Lock()
+	info, ok := d.visited[rel]
+	d.mu.Unlock()
+	if ok {
+		return info, nil
+	}
+	info, err := os.Stat(filepath.Join(d.root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.visited[rel] = info
+	d.mu.Unlock()
+	return info, nil
+}
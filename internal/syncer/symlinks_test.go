@@ -0,0 +1,105 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// symlinkTree writes a volume holding a symlink to a directory outside it, a
+// symlink to a file and a symlink looping back to the volume's root, returning
+// the volume's directory and the files a backup following them should hold.
+func symlinkTree(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	outside := filepath.Join(tmpDir, "outside")
+	require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sub"), 0755))
+	require.NoError(t, os.Mkdir(outside, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "sub", "b.txt"), []byte("b"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "c.txt"), []byte("c"), 0644))
+	require.NoError(t, os.Symlink(outside, filepath.Join(srcDir, "linked")))
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(srcDir, "alias.txt")))
+	require.NoError(t, os.Symlink("..", filepath.Join(srcDir, "sub", "loop")))
+	return srcDir, map[string]string{
+		"a.txt":        "a",
+		"alias.txt":    "a",
+		"sub/b.txt":    "b",
+		"linked/c.txt": "c",
+	}
+}
+
+func TestSync_FollowSymlinks(t *testing.T) {
+	srcDir, want := symlinkTree(t)
+	dstDir := filepath.Join(t.TempDir(), "dst")
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	// The loop must be skipped rather than followed until the path is too
+	// long, which would take far longer than this.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s, err := New(ctx, WithFollowSymlinks(true), WithLogger(logger))
+	require.NoError(t, err)
+	stats, err := s.SyncWithStats(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(want)), stats.Transfers)
+
+	for name, content := range want {
+		path := filepath.Join(dstDir, filepath.FromSlash(name))
+		info, err := os.Lstat(path)
+		require.NoError(t, err, "%s should be backed up", name)
+		require.True(t, info.Mode().IsRegular(), "%s should be a plain file", name)
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, content, string(got))
+	}
+	require.NoDirExists(t, filepath.Join(dstDir, "sub", "loop"))
+	require.Contains(t, logs.String(), "Skipping symlink loop")
+}
+
+func TestSync_SymlinksSkippedAreLogged(t *testing.T) {
+	srcDir, _ := symlinkTree(t)
+	dstDir := filepath.Join(t.TempDir(), "dst")
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	s, err := New(context.Background(), WithLogger(logger))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(context.Background(), srcDir, dstDir))
+
+	require.FileExists(t, filepath.Join(dstDir, "a.txt"))
+	require.NoFileExists(t, filepath.Join(dstDir, "alias.txt"))
+	require.NoDirExists(t, filepath.Join(dstDir, "linked"))
+	require.Contains(t, logs.String(), "Skipping symlink")
+	for _, name := range []string{"linked", "alias.txt", "sub/loop"} {
+		require.Contains(t, logs.String(), "file="+name)
+	}
+}
+
+func TestArchive_FollowSymlinks(t *testing.T) {
+	srcDir, want := symlinkTree(t)
+	dstDir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	s, err := New(ctx, WithFollowSymlinks(true), WithArchiveFormat(ArchiveTar))
+	require.NoError(t, err)
+	stats, err := s.Archive(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(want)), stats.Transfers)
+
+	archives, err := filepath.Glob(filepath.Join(dstDir, "backups", "*"))
+	require.NoError(t, err)
+	require.Len(t, archives, 1)
+	data, err := os.ReadFile(archives[0])
+	require.NoError(t, err)
+	require.Equal(t, want, readArchive(t, data, ArchiveTar))
+}
@@ -1,26 +1,95 @@
+// Package syncer copies a volume to or from a remote with rclone. It reads no
+// environment variables: everything is set with the Options passed to New,
+// and a Syncer can be used on its own, without the volumesync command.
 package syncer
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/rclone/rclone/backend/all" // register all rclone backends
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/filter"
-	"github.com/rclone/rclone/fs/sync"
+	"github.com/rclone/rclone/fs/operations"
+	fssync "github.com/rclone/rclone/fs/sync"
 )
 
 type Syncer struct {
-	deleteDestination bool
-	concurrency       int
-	filterOpt         filter.Options
+	deleteDestination   bool
+	deleteFirst         bool
+	skipRemoteDeletes   bool
+	maxDeleteRatio      float64
+	concurrency         int
+	filterOpt           filter.Options
+	reserved            []string
+	nonEmptySource      bool
+	preservePermissions bool
+	preserveSymlinks    bool
+	followSymlinks      bool
+	preserveEmptyDirs   bool
+	partConcurrency     int
+	downloadPartSize    fs.SizeSuffix
+	objectTags          string
+	contentType         ContentTypeMode
+	skipUnreadable      bool
+	modifyWindow        time.Duration
+	preserveModTime     bool
+	verify              VerifyMode
+	archive             ArchiveFormat
+	retentionCount      int
+	retentionAge        time.Duration
+	logger              *slog.Logger
+	failFast            bool
+	progressInterval    time.Duration
+	dedup               bool
+	manifest            string
+	fullEvery           int
+	minAge              time.Duration
+	maxAge              time.Duration
+	minSize             fs.SizeSuffix
+	maxSize             fs.SizeSuffix
+	// now names archives and snapshots after the time of the backup, see
+	// WithClock.
+	now func() time.Time
+
+	// runsCtx is cancelled, and closed set, when the Syncer is closed. runs
+	// counts the runs in flight.
+	closeMu    sync.Mutex
+	closed     bool
+	runsCtx    context.Context
+	cancelRuns context.CancelFunc
+	runs       sync.WaitGroup
 }
 
 type Option func(*Syncer)
 
+// Stats summarises what a sync did.
+type Stats struct {
+	// Bytes is the amount of data transferred.
+	Bytes int64
+	// Transfers is the number of files transferred.
+	Transfers int64
+	// Deletes is the number of files deleted from the destination.
+	Deletes int64
+	// Skipped lists the source files left out because they couldn't be
+	// read, see WithSkipUnreadable.
+	Skipped  []string
+	Duration time.Duration
+}
+
+// statsGroupSeq numbers the rclone stats group of each sync.
+var statsGroupSeq atomic.Int64
+
 // WithFilterOpt allows passing custom rclone filter options
 func WithFilterOpt(opt filter.Options) Option {
 	return func(s *Syncer) {
@@ -34,17 +103,186 @@ func WithDelete(delete bool) Option {
 	}
 }
 
+// WithDeleteFirst makes a sync with deletion enabled delete the files missing
+// from the source before it copies anything, freeing up room on a nearly full
+// destination. Files that are about to be copied over are never deleted, only
+// replaced. By default deletes happen once every file has been copied.
+func WithDeleteFirst(deleteFirst bool) Option {
+	return func(s *Syncer) {
+		s.deleteFirst = deleteFirst
+	}
+}
+
+// WithSkipRemoteDeletes stops syncs deleting anything from a destination
+// other than the local filesystem, even with WithDelete, leaving it to the
+// bucket's lifecycle rules to expire what's gone from the source.
+func WithSkipRemoteDeletes(skip bool) Option {
+	return func(s *Syncer) {
+		s.skipRemoteDeletes = skip
+	}
+}
+
+// WithMaxDeleteRatio refuses a sync with deletion enabled, before it changes
+// anything, when it would delete more than ratio of the destination's files.
+// The check costs an extra listing of both sides. A ratio of 1, the default,
+// turns it off.
+func WithMaxDeleteRatio(ratio float64) Option {
+	return func(s *Syncer) {
+		s.maxDeleteRatio = ratio
+	}
+}
+
+// WithConcurrency sets how many files are transferred at once, 16 by
+// default. Zero chooses it for each sync from a listing of the source, along
+// with how many parts of each file are transferred at once unless
+// WithPartConcurrency sets that: large files are split across more parts,
+// and many small files go more at a time. The listing costs an extra pass
+// over the source.
 func WithConcurrency(n int) Option {
 	return func(s *Syncer) {
-		s.concurrency = n
+		if n >= 0 {
+			s.concurrency = n
+		}
+	}
+}
+
+// WithPartConcurrency sets how many parts of a single large file are
+// downloaded at once. Together with WithConcurrency it bounds the number of
+// requests in flight. Zero keeps rclone's default. Uploads are tuned on the
+// remote instead, see WithUploadParts.
+func WithPartConcurrency(n int) Option {
+	return func(s *Syncer) {
+		s.partConcurrency = n
+	}
+}
+
+// WithDownloadPartSize sets the size of the parts a large file is downloaded
+// in. Zero keeps rclone's default.
+func WithDownloadPartSize(size fs.SizeSuffix) Option {
+	return func(s *Syncer) {
+		s.downloadPartSize = size
+	}
+}
+
+// WithPreservePermissions controls whether file mode and ownership travel with
+// each file as rclone metadata. On remotes such as S3 they are stored as
+// x-amz-meta-mode, x-amz-meta-uid and x-amz-meta-gid, and applied again once a
+// restored file has been written.
+func WithPreservePermissions(preserve bool) Option {
+	return func(s *Syncer) {
+		s.preservePermissions = preserve
+	}
+}
+
+// WithPreserveSymlinks backs symlinks up as links rather than skipping them.
+// rclone stores each one as a "<name>.rclonelink" object whose body is the
+// link target, and recreates the symlink on restore, replacing anything already
+// at that path.
+func WithPreserveSymlinks(preserve bool) Option {
+	return func(s *Syncer) {
+		s.preserveSymlinks = preserve
+	}
+}
+
+// WithPreserveEmptyDirs creates the source's empty directories on the
+// destination. Bucket-based remotes such as S3 have no directories of their
+// own, so a backup there needs directory markers, see WithDirectoryMarkers.
+func WithPreserveEmptyDirs(preserve bool) Option {
+	return func(s *Syncer) {
+		s.preserveEmptyDirs = preserve
+	}
+}
+
+// WithObjectTags tags every object uploaded to S3, for instance for lifecycle
+// rules to match on. Tags are set when an object is written and aren't read
+// back, so they play no part in deciding what to transfer: changing them
+// retags files only as they next change. Other remotes ignore them.
+func WithObjectTags(tags map[string]string) Option {
+	return func(s *Syncer) {
+		s.objectTags = encodeTags(tags)
+	}
+}
+
+// encodeTags encodes tags the way S3 expects them in the X-Amz-Tagging
+// header, as URL query parameters. Spaces are percent-encoded, S3 doesn't
+// take "+" for one.
+func encodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return strings.ReplaceAll(values.Encode(), "+", "%20")
+}
+
+// WithSkipUnreadable has a sync from a local path skip the files it isn't
+// allowed to read, logging and reporting each in Stats.Skipped, rather than
+// failing. A directory that can't be read still fails the sync, as everything
+// under it would go missing from the destination unnoticed. Deletes are
+// skipped too when a file is, just as when one fails.
+func WithSkipUnreadable(skip bool) Option {
+	return func(s *Syncer) {
+		s.skipUnreadable = skip
+	}
+}
+
+// WithPreserveModTime controls whether files written to a local destination,
+// as by a restore, get the modification time of their source. It is on by
+// default. Turn it off for mounts the process may write to but not set times
+// on; files there are then compared by size alone.
+func WithPreserveModTime(preserve bool) Option {
+	return func(s *Syncer) {
+		s.preserveModTime = preserve
+	}
+}
+
+// WithModifyWindow treats files whose modification times are within window
+// of each other as unchanged, as long as their sizes match. rclone already
+// allows for the coarsest precision of the two sides, so this is only needed
+// where that falls short, say for a destination that rounds modification
+// times without saying so. Zero keeps rclone's default.
+func WithModifyWindow(window time.Duration) Option {
+	return func(s *Syncer) {
+		s.modifyWindow = window
+	}
+}
+
+// WithLogger sets the logger for sync progress and per-file events. It
+// defaults to slog.Default() at the time New is called.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Syncer) {
+		s.logger = logger
+	}
+}
+
+// WithClock sets the clock that names archives and snapshots and that their
+// retention age is measured against. It defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *Syncer) {
+		s.now = now
+	}
+}
+
+// WithFailFast stops a sync at the first file that fails. By default a sync
+// carries on with the remaining files and reports every failure at the end.
+func WithFailFast(failFast bool) Option {
+	return func(s *Syncer) {
+		s.failFast = failFast
 	}
 }
 
 func New(ctx context.Context, opts ...Option) (*Syncer, error) {
 	s := &Syncer{
-		concurrency: 16,
-		filterOpt:   filter.Opt,
+		concurrency:         16,
+		filterOpt:           filter.Opt,
+		preservePermissions: true,
+		preserveModTime:     true,
+		maxDeleteRatio:      1,
+		archive:             ArchiveTarGz,
+		contentType:         ContentTypeExtension,
+		logger:              slog.Default(),
+		now:                 time.Now,
 	}
+	s.runsCtx, s.cancelRuns = context.WithCancel(context.Background())
 
 	for _, opt := range opts {
 		opt(s)
@@ -53,37 +291,140 @@ func New(ctx context.Context, opts ...Option) (*Syncer, error) {
 	return s, nil
 }
 
+// Sync makes dst match src. Either side may be a local path or any rclone
+// remote, so besides backups and restores it also handles migrating between
+// buckets or mounts. Copies within a single remote are done server-side where
+// the backend supports it. Deletion and filters apply the same in every
+// direction.
 func (s *Syncer) Sync(ctx context.Context, src, dst string) error {
-	log.Printf("Syncing %s -> %s", src, dst)
+	_, err := s.SyncWithStats(ctx, src, dst)
+	return err
+}
 
-	srcFs, err := fs.NewFs(ctx, src)
+// SyncWithStats is Sync, also reporting what the sync did. Stats are returned
+// even when the sync fails partway through.
+func (s *Syncer) SyncWithStats(ctx context.Context, src, dst string) (Stats, error) {
+	ctx, end, err := s.begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create source fs: %w", err)
+		return Stats{}, err
 	}
+	defer end()
 
-	dstFs, err := fs.NewFs(ctx, dst)
+	logger := s.logger.With("src", src, "dst", dst)
+	logger.Info("Syncing")
+	start := time.Now()
+
+	// The config must be in place before the filesystems are created, as the
+	// local backend reads Links on creation.
+	ctx = s.withConfig(ctx)
+
+	// Apply filter if provided
+	fi, err := s.newFilter()
 	if err != nil {
-		return fmt.Errorf("failed to create destination fs: %w", err)
+		return Stats{}, fmt.Errorf("failed to create filter: %w", err)
 	}
 
-	// Apply filter if provided
-	fi, err := filter.NewFilter(&s.filterOpt)
+	ctx = filter.ReplaceConfig(ctx, fi)
+
+	srcRemote, err := s.symlinkOptions(src)
 	if err != nil {
-		return fmt.Errorf("failed to create filter: %w", err)
+		return Stats{}, err
+	}
+	srcFs, err := fs.NewFs(ctx, srcRemote)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create source fs: %w", err)
+	}
+	srcFs = s.wrapSymlinks(logger, srcFs)
+	if err := s.checkSource(ctx, srcFs); err != nil {
+		return Stats{}, err
+	}
+	skipUnreadable := s.skipUnreadable && srcFs.Features().IsLocal
+	var srcWatch *listWatchFs
+	if skipUnreadable {
+		srcWatch = &listWatchFs{Fs: srcFs}
+		srcFs = srcWatch
 	}
 
-	ci := fs.GetConfig(ctx)
-	ci.Transfers = s.concurrency
-	ci.Checkers = s.concurrency
-	ci.Metadata = true
+	// The options set on dst below can change from one sync to the next.
+	var manifestKey string
+	if s.manifest != "" {
+		manifestKey = s.manifestKey(dst)
+	}
+	if s.concurrency == 0 {
+		dst, err = s.autoConcurrency(ctx, logger, srcFs, dst)
+		if err != nil {
+			return Stats{}, err
+		}
+	}
+	if !s.preserveModTime {
+		dst, err = setBackendOptions(dst, backendOption{noSetModTimeOption, "true"})
+		if err != nil {
+			return Stats{}, err
+		}
+	}
+	dstFs, err := fs.NewFs(ctx, dst)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to create destination fs: %w", err)
+	}
+	if s.contentType == ContentTypeSniff && srcFs.Features().IsLocal && !dstFs.Features().IsLocal {
+		srcFs = &objectsFs{Fs: srcFs, wrap: newSniffObject}
+	}
+	direction := syncDirection(srcFs, dstFs)
+	if s.uploadLimited() {
+		fi, err = s.filterFor(direction)
+		if err != nil {
+			return Stats{}, fmt.Errorf("failed to create filter: %w", err)
+		}
+		ctx = filter.ReplaceConfig(ctx, fi)
+	}
+	if direction == "download" {
+		// A volume restored before anything was ever written to it may not
+		// exist yet.
+		if err := dstFs.Mkdir(ctx, ""); err != nil {
+			return Stats{}, fmt.Errorf("failed to create destination directory: %w", err)
+		}
+		srcFs = &safePathFs{Fs: srcFs, logger: logger}
+		srcFs = &objectsFs{Fs: srcFs, wrap: newSizeCheckObject}
+	}
+	var prevManifest, nextManifest *manifest
+	if s.manifest != "" && direction == "upload" && !s.uploadLimited() {
+		prevManifest, nextManifest = s.loadManifest(ctx, logger, srcFs, manifestKey)
+	}
+	if s.dedup && direction == "upload" {
+		if d := newDedupFs(logger, dstFs); d != nil {
+			dstFs = d
+		}
+	}
+	if s.progressInterval > 0 && direction != "copy" {
+		// Copies between remotes are left alone, as a wrapped file can't be
+		// copied server-side.
+		srcFs = &objectsFs{Fs: srcFs, wrap: s.progressWrap(logger, direction)}
+	}
+	if logger.Enabled(ctx, slog.LevelDebug) {
+		srcFs = &filterLogFs{Fs: srcFs, logger: logger, fi: fi}
+	}
+	if !s.preserveModTime && dstFs.Features().IsLocal {
+		// With metadata on, the local backend also sets the times carried in
+		// it. It ignores times it can't parse, so blank them.
+		ci := fs.GetConfig(ctx)
+		ci.MetadataSet = fs.Metadata{"mtime": "", "atime": ""}
+	}
 
-	ctx = filter.ReplaceConfig(ctx, fi)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	failures := &fileFailures{}
+	if s.failFast {
+		failures.onFailure = cancel
+	}
+	failures.skipUnreadable = skipUnreadable
+	deleting := s.deleteDestination && !(s.skipRemoteDeletes && !dstFs.Features().IsLocal)
+	ctx = operations.WithLogger(ctx, s.fileLogger(logger, direction, deleting, failures))
 
-	// Create a new stats object for this sync operation
-	// This ensures that progress is tracked per-sync if multiple is running
-	// Note: GlobalStats is still updated by rclone, but we can track this sync specifically
-	// However, rclone's sync.Sync uses the global accounting if not told otherwise.
-	// For now, we'll use GlobalStats as it's the most reliable way to get what rclone is doing.
+	// Account this sync in a stats group of its own, so its progress and
+	// totals aren't mixed up with other syncs running at the same time. rclone
+	// discards the oldest groups once there are too many.
+	ctx = accounting.WithStatsGroup(ctx, fmt.Sprintf("volumesync-%d", statsGroupSeq.Add(1)))
+	stats := accounting.Stats(ctx)
 
 	stopStats := make(chan struct{})
 	go func() {
@@ -92,8 +433,7 @@ func (s *Syncer) Sync(ctx context.Context, src, dst string) error {
 		for {
 			select {
 			case <-ticker.C:
-				stats := accounting.GlobalStats()
-				log.Printf("[%s -> %s] Progress: %s", src, dst, stats.String())
+				logger.Info("Sync progress", "stats", stats.String())
 			case <-stopStats:
 				return
 			case <-ctx.Done():
@@ -102,18 +442,205 @@ func (s *Syncer) Sync(ctx context.Context, src, dst string) error {
 		}
 	}()
 
-	if s.deleteDestination {
-		err = sync.Sync(ctx, dstFs, srcFs, false)
-	} else {
-		err = sync.CopyDir(ctx, dstFs, srcFs, false)
+	switch {
+	case prevManifest != nil:
+		err = s.syncChanges(ctx, logger, prevManifest, nextManifest, srcFs, dstFs, deleting)
+	case deleting:
+		err = s.deleteFirstPass(ctx, logger, srcFs, dstFs)
+		if err == nil {
+			err = fssync.Sync(ctx, dstFs, srcFs, s.preserveEmptyDirs)
+		}
+	default:
+		err = fssync.CopyDir(ctx, dstFs, srcFs, s.preserveEmptyDirs)
+	}
+	// rclone stops walking the trees once ctx is cancelled, but can return
+	// without an error, which would pass a sync cut short for a complete one.
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && s.verify != VerifyNone && prevManifest == nil {
+		err = s.verifyFs(ctx, logger, srcFs, dstFs)
 	}
 
 	close(stopStats)
 
+	result := Stats{
+		Bytes:     stats.GetBytes(),
+		Transfers: stats.GetTransfers(),
+		Deletes:   stats.GetDeletes(),
+		Skipped:   failures.skippedFiles(),
+		Duration:  time.Since(start),
+	}
+
+	// rclone only returns one error however many files failed, so report the
+	// failures themselves when there are any. When the only errors were
+	// files skipped on purpose, the sync otherwise went fine.
+	if failed := failures.errors(); len(failed) > 0 {
+		err = errors.Join(failed...)
+	} else if err != nil && ctx.Err() == nil && len(result.Skipped) > 0 && !srcWatch.failed.Load() {
+		err = nil
+	}
 	if err != nil {
-		return fmt.Errorf("sync failed: %w", err)
+		return result, fmt.Errorf("sync failed: %w", err)
 	}
 
-	log.Println("Sync completed successfully.")
+	if nextManifest != nil {
+		s.saveManifest(logger, nextManifest, result.Skipped)
+	}
+	logger.Info("Sync completed", "duration", result.Duration, "bytes", result.Bytes, "transfers", result.Transfers, "deletes", result.Deletes)
+	return result, nil
+}
+
+// withConfig returns a context carrying a copy of rclone's config with the
+// syncer's settings applied. Working on a copy means that concurrent syncs
+// with different settings don't trample each other.
+func (s *Syncer) withConfig(ctx context.Context) context.Context {
+	ctx, ci := fs.AddConfig(ctx)
+	// Transfers bounds the files in flight and MultiThreadStreams the parts
+	// of each, so at most their product of parts move at once.
+	if s.concurrency > 0 {
+		ci.Transfers = s.concurrency
+		ci.Checkers = s.concurrency
+	}
+	ci.Metadata = s.preservePermissions
+	ci.Links = s.preserveSymlinks
+	if s.partConcurrency > 0 {
+		ci.MultiThreadStreams = s.partConcurrency
+		ci.MultiThreadSet = true
+	}
+	if s.downloadPartSize > 0 {
+		ci.MultiThreadChunkSize = s.downloadPartSize
+	}
+	if s.modifyWindow > 0 {
+		ci.ModifyWindow = fs.Duration(s.modifyWindow)
+	}
+	if s.objectTags != "" {
+		// Copy the headers so as not to append to the global config's.
+		ci.UploadHeaders = append(slices.Clone(ci.UploadHeaders), &fs.HTTPOption{Key: "X-Amz-Tagging", Value: s.objectTags})
+	}
+	if s.contentType == ContentTypeOff {
+		ci.UploadHeaders = append(slices.Clone(ci.UploadHeaders), &fs.HTTPOption{Key: "Content-Type", Value: defaultContentType})
+	}
+	// Deleting after the transfers means that rclone deletes nothing if any
+	// listing or transfer failed, so an error can't pass for missing files.
+	ci.DeleteMode = fs.DeleteModeAfter
+	return ctx
+}
+
+// deleteFirstPass plans the deletes of a sync before it runs, to refuse too
+// many of them and to carry them out ahead of the transfers when deleting
+// first. rclone's own delete-before mode isn't used as it deletes while still
+// listing, so a source directory that fails to list loses its copy.
+func (s *Syncer) deleteFirstPass(ctx context.Context, logger *slog.Logger, srcFs, dstFs fs.Fs) error {
+	if s.maxDeleteRatio >= 1 && !s.deleteFirst {
+		return nil
+	}
+	plan, err := planDeletes(ctx, srcFs, dstFs)
+	if err != nil {
+		return err
+	}
+	if err := plan.check(logger, s.maxDeleteRatio); err != nil {
+		return err
+	}
+	if s.deleteFirst {
+		return plan.run(ctx)
+	}
 	return nil
 }
+
+// fileFailures collects the files that failed during a sync, and those
+// skipped because they couldn't be read.
+type fileFailures struct {
+	mu      sync.Mutex
+	errs    []error
+	skipped []string
+	// onFailure, if set, is called after each failure is recorded.
+	onFailure func()
+	// skipUnreadable skips files that fail for lack of permission to read
+	// them instead of recording a failure.
+	skipUnreadable bool
+}
+
+func (f *fileFailures) add(key string, err error) {
+	f.mu.Lock()
+	f.errs = append(f.errs, fmt.Errorf("%s: %w", key, err))
+	f.mu.Unlock()
+	if f.onFailure != nil {
+		f.onFailure()
+	}
+}
+
+func (f *fileFailures) skip(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.skipped = append(f.skipped, key)
+}
+
+func (f *fileFailures) skippedFiles() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.skipped)
+}
+
+func (f *fileFailures) errors() []error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.errs
+}
+
+// fileLogger reports each file rclone decides to transfer or delete, and
+// records each file that fails. rclone calls it as it makes the decision, ahead
+// of the transfer itself, so the duration of a sync is only logged once it
+// completes.
+func (s *Syncer) fileLogger(logger *slog.Logger, direction string, deleting bool, failures *fileFailures) operations.LoggerFn {
+	return func(ctx context.Context, sigil operations.Sigil, src, dst fs.DirEntry, err error) {
+		if errors.Is(err, fs.ErrorIsDir) {
+			return
+		}
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			logDecision(ctx, logger, sigil, src, dst)
+		}
+		switch sigil {
+		case operations.MissingOnDst, operations.Differ:
+			logger.Info("Transferring file", "key", src.Remote(), "size", src.Size(), "direction", direction)
+		case operations.MissingOnSrc:
+			// Also reported by copies, which leave such files alone.
+			if deleting {
+				logger.Info("Deleting file", "key", dst.Remote(), "size", dst.Size(), "direction", direction)
+			}
+		case operations.TransferError:
+			// Files cut short by a cancelled sync didn't fail as such.
+			if err == nil || (errors.Is(err, context.Canceled) && ctx.Err() != nil) {
+				return
+			}
+			entry := src
+			if entry == nil {
+				entry = dst
+			}
+			if entry == nil {
+				return
+			}
+			if failures.skipUnreadable && src != nil && errors.Is(err, os.ErrPermission) {
+				logger.Warn("Skipping unreadable file", "key", entry.Remote(), "direction", direction, "error", err)
+				failures.skip(entry.Remote())
+				return
+			}
+			logger.Error("Failed to sync file", "key", entry.Remote(), "direction", direction, "error", err)
+			failures.add(entry.Remote(), err)
+		}
+	}
+}
+
+// syncDirection describes a sync as an upload from the local filesystem, a
+// download to it, or a copy between two remotes or two local paths.
+func syncDirection(srcFs, dstFs fs.Fs) string {
+	srcLocal, dstLocal := srcFs.Features().IsLocal, dstFs.Features().IsLocal
+	switch {
+	case srcLocal && !dstLocal:
+		return "upload"
+	case !srcLocal && dstLocal:
+		return "download"
+	default:
+		return "copy"
+	}
+}
@@ -1,16 +1,48 @@
 package syncer
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/object"
 	"github.com/stretchr/testify/require"
 )
 
+func TestSyncer_Concurrency(t *testing.T) {
+	ctx := context.Background()
+	s, err := New(ctx, WithConcurrency(8), WithPartConcurrency(2), WithDownloadPartSize(32*fs.Mebi))
+	require.NoError(t, err)
+
+	ci := fs.GetConfig(s.withConfig(ctx))
+	require.Equal(t, 8, ci.Transfers)
+	require.Equal(t, 8, ci.Checkers)
+	require.Equal(t, 2, ci.MultiThreadStreams)
+	require.Equal(t, 32*fs.Mebi, ci.MultiThreadChunkSize)
+
+	// The global config is left alone.
+	require.NotEqual(t, 2, fs.GetConfig(ctx).MultiThreadStreams)
+
+	// Unset, part concurrency stays at rclone's default.
+	s, err = New(ctx, WithConcurrency(8))
+	require.NoError(t, err)
+	ci = fs.GetConfig(s.withConfig(ctx))
+	require.Equal(t, 8, ci.Transfers)
+	require.Equal(t, fs.GetConfig(ctx).MultiThreadStreams, ci.MultiThreadStreams)
+}
+
 func TestSync_CopyDir(t *testing.T) {
 	tmpDir := t.TempDir()
 	srcDir := filepath.Join(tmpDir, "src")
@@ -143,3 +175,741 @@ func TestSync_PreservePermissions(t *testing.T) {
 	// or might have extra bits. We check the lower 9 bits.
 	require.Equal(t, expectedMode, info.Mode().Perm(), "Permissions should be preserved")
 }
+
+func TestSync_PermissionsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		preserve bool
+	}{
+		{name: "Preserved", preserve: true},
+		{name: "Disabled", preserve: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			volumeDir := filepath.Join(tmpDir, "volume")
+			backupDir := filepath.Join(tmpDir, "backup")
+			restoreDir := filepath.Join(tmpDir, "restore")
+			require.NoError(t, os.Mkdir(volumeDir, 0755))
+
+			// An executable is the case that breaks when restores come back 0644.
+			scriptPath := filepath.Join(volumeDir, "entrypoint.sh")
+			require.NoError(t, os.WriteFile(scriptPath, []byte("#!/bin/sh\n"), 0644))
+			require.NoError(t, os.Chmod(scriptPath, 0750))
+
+			s, err := New(context.Background(), WithPreservePermissions(tt.preserve))
+			require.NoError(t, err)
+
+			require.NoError(t, s.Sync(context.Background(), volumeDir, backupDir))
+			require.NoError(t, s.Sync(context.Background(), backupDir, restoreDir))
+
+			info, err := os.Stat(filepath.Join(restoreDir, "entrypoint.sh"))
+			require.NoError(t, err)
+			if tt.preserve {
+				require.Equal(t, os.FileMode(0750), info.Mode().Perm())
+			} else {
+				require.NotEqual(t, os.FileMode(0750), info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestSync_PreserveSymlinks(t *testing.T) {
+	tests := []struct {
+		name   string
+		bucket string
+		target func(volumeDir string) string
+	}{
+		{
+			name:   "RelativeTarget",
+			bucket: "symlinks-relative",
+			target: func(string) string { return "data/file.txt" },
+		},
+		{
+			name:   "AbsoluteTarget",
+			bucket: "symlinks-absolute",
+			target: func(volumeDir string) string { return filepath.Join(volumeDir, "data", "file.txt") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			volumeDir := filepath.Join(tmpDir, "volume")
+			restoreDir := filepath.Join(tmpDir, "restore")
+			remote := ":memory:" + tt.bucket + "/volume"
+
+			require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "data"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "data", "file.txt"), []byte("hello"), 0644))
+			target := tt.target(volumeDir)
+			require.NoError(t, os.Symlink(target, filepath.Join(volumeDir, "link")))
+
+			// Something already in the way of the restored link must be replaced.
+			require.NoError(t, os.MkdirAll(restoreDir, 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(restoreDir, "link"), []byte("stale"), 0644))
+
+			s, err := New(ctx, WithPreserveSymlinks(true))
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(ctx, volumeDir, remote))
+
+			// The link is backed up as an object holding its target.
+			remoteFs, err := fs.NewFs(ctx, remote)
+			require.NoError(t, err)
+			obj, err := remoteFs.NewObject(ctx, "link"+fs.LinkSuffix)
+			require.NoError(t, err)
+			rc, err := obj.Open(ctx)
+			require.NoError(t, err)
+			body, err := io.ReadAll(rc)
+			require.NoError(t, rc.Close())
+			require.NoError(t, err)
+			require.Equal(t, target, string(body))
+
+			require.NoError(t, s.Sync(ctx, remote, restoreDir))
+
+			got, err := os.Readlink(filepath.Join(restoreDir, "link"))
+			require.NoError(t, err)
+			require.Equal(t, target, got)
+		})
+	}
+}
+
+func TestSync_PreserveEmptyDirs(t *testing.T) {
+	tests := []struct {
+		name     string
+		preserve bool
+	}{
+		{name: "Preserved", preserve: true},
+		{name: "DroppedByDefault", preserve: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			volumeDir := filepath.Join(tmpDir, "volume")
+			backupDir := filepath.Join(tmpDir, "backup")
+			restoreDir := filepath.Join(tmpDir, "restore")
+
+			require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "logs", "app"), 0755))
+			require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "file.txt"), []byte("hello"), 0644))
+
+			s, err := New(ctx, WithPreserveEmptyDirs(tt.preserve))
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(ctx, volumeDir, backupDir))
+			require.NoError(t, s.Sync(ctx, backupDir, restoreDir))
+
+			require.FileExists(t, filepath.Join(restoreDir, "file.txt"))
+			if tt.preserve {
+				require.DirExists(t, filepath.Join(restoreDir, "logs", "app"))
+			} else {
+				require.NoDirExists(t, filepath.Join(restoreDir, "logs"))
+			}
+		})
+	}
+}
+
+func TestSync_DirectoryMarkerKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		preserve bool
+	}{
+		{name: "Preserved", preserve: true},
+		{name: "DroppedByDefault", preserve: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newFakeS3(t)
+			now := time.Now()
+			// Markers as other tools create them, with and without files
+			// underneath.
+			f.objects["vol/folder/"] = fakeS3Object{modified: now}
+			f.objects["vol/nested/empty/"] = fakeS3Object{modified: now}
+			f.objects["vol/data/"] = fakeS3Object{modified: now}
+			f.objects["vol/data/file.txt"] = fakeS3Object{body: []byte("hello"), modified: now}
+
+			s, err := New(ctx, WithPreserveEmptyDirs(tt.preserve))
+			require.NoError(t, err)
+			restoreDir := t.TempDir()
+			require.NoError(t, s.Sync(ctx, f.remote("vol"), restoreDir))
+
+			// No marker is restored as a file.
+			require.Equal(t, map[string]string{"data/file.txt": "hello"}, readTree(t, restoreDir))
+			if tt.preserve {
+				require.DirExists(t, filepath.Join(restoreDir, "folder"))
+				require.DirExists(t, filepath.Join(restoreDir, "nested", "empty"))
+			} else {
+				require.NoDirExists(t, filepath.Join(restoreDir, "folder"))
+				require.NoDirExists(t, filepath.Join(restoreDir, "nested"))
+			}
+		})
+	}
+}
+
+func TestSync_SymlinksSkippedByDefault(t *testing.T) {
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+
+	require.NoError(t, os.Mkdir(srcDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.Symlink("file.txt", filepath.Join(srcDir, "link")))
+
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(context.Background(), srcDir, dstDir))
+
+	_, err = os.Lstat(filepath.Join(dstDir, "link"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Lstat(filepath.Join(dstDir, "link"+fs.LinkSuffix))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSync_RemoteToRemote(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	volumeDir := filepath.Join(tmpDir, "volume")
+	src := ":memory:migrate-src/volume"
+	dst := ":memory:migrate-dst/volume"
+
+	require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "keep.txt"), []byte("keep"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "sub", "keep.txt"), []byte("keep"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "skip.tmp"), []byte("skip"), 0644))
+
+	s, err := New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, volumeDir, src))
+
+	// Something only in the destination, to be deleted.
+	dstFs, err := fs.NewFs(ctx, dst)
+	require.NoError(t, err)
+	require.NoError(t, dstFs.Mkdir(ctx, ""))
+	_, err = dstFs.Put(ctx, strings.NewReader("stale"), object.NewStaticObjectInfo("stale.txt", time.Now(), 5, true, nil, nil))
+	require.NoError(t, err)
+
+	rules, err := BuildFilterRules([]string{"*.tmp"}, nil, nil)
+	require.NoError(t, err)
+	opt := filter.Opt
+	opt.FilterRule = rules
+	s, err = New(ctx, WithDelete(true), WithFilterOpt(opt))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, src, dst))
+
+	for _, name := range []string{"keep.txt", "sub/keep.txt"} {
+		_, err := dstFs.NewObject(ctx, name)
+		require.NoError(t, err, name)
+	}
+	for _, name := range []string{"skip.tmp", "stale.txt"} {
+		_, err := dstFs.NewObject(ctx, name)
+		require.ErrorIs(t, err, fs.ErrorObjectNotFound, name)
+	}
+}
+
+func TestSync_LogsFileEvents(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	volumeDir := filepath.Join(tmpDir, "volume")
+	remote := ":memory:file-events/volume"
+
+	require.NoError(t, os.MkdirAll(volumeDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(volumeDir, "file.txt"), []byte("hello"), 0644))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	s, err := New(ctx, WithDelete(true), WithLogger(logger))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, volumeDir, remote))
+
+	require.NoError(t, os.Remove(filepath.Join(volumeDir, "file.txt")))
+	require.NoError(t, s.Sync(ctx, volumeDir, remote))
+
+	type record struct {
+		Msg       string `json:"msg"`
+		Key       string `json:"key"`
+		Size      int64  `json:"size"`
+		Direction string `json:"direction"`
+	}
+	var events []record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		require.NoError(t, dec.Decode(&r))
+		if r.Key != "" {
+			events = append(events, r)
+		}
+	}
+
+	require.Equal(t, []record{
+		{Msg: "Transferring file", Key: "file.txt", Size: 5, Direction: "upload"},
+		{Msg: "Deleting file", Key: "file.txt", Size: 5, Direction: "upload"},
+	}, events)
+}
+
+func TestSync_DeleteFirst(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+
+	require.NoError(t, os.MkdirAll(srcDir, 0755))
+	require.NoError(t, os.MkdirAll(dstDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "new.txt"), []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "changed.txt"), []byte("changed"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "changed.txt"), []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stale1.txt"), []byte("stale"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stale2.txt"), []byte("stale"), 0644))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	s, err := New(ctx, WithDelete(true), WithDeleteFirst(true), WithLogger(logger))
+	require.NoError(t, err)
+	stats, err := s.SyncWithStats(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+
+	type record struct {
+		Msg string `json:"msg"`
+		Key string `json:"key"`
+	}
+	var events []record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r record
+		require.NoError(t, dec.Decode(&r))
+		if r.Key != "" {
+			events = append(events, r)
+		}
+	}
+
+	// Every delete comes before the first transfer.
+	require.Len(t, events, 4)
+	for i, ev := range events {
+		if i < 2 {
+			require.Equal(t, "Deleting file", ev.Msg, ev.Key)
+		} else {
+			require.Equal(t, "Transferring file", ev.Msg, ev.Key)
+		}
+	}
+
+	// The file copied over is replaced, not deleted.
+	require.Equal(t, int64(2), stats.Deletes)
+	content, err := os.ReadFile(filepath.Join(dstDir, "changed.txt"))
+	require.NoError(t, err)
+	require.Equal(t, "changed", string(content))
+	require.FileExists(t, filepath.Join(dstDir, "new.txt"))
+	require.NoFileExists(t, filepath.Join(dstDir, "stale1.txt"))
+	require.NoFileExists(t, filepath.Join(dstDir, "stale2.txt"))
+}
+
+func TestSync_MaxDeleteRatio(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     []string
+		wantErr bool
+	}{
+		{name: "EmptySource", src: nil, wantErr: true},
+		{name: "MostlyGone", src: []string{"a.txt"}, wantErr: true},
+		{name: "AtTheLimit", src: []string{"a.txt", "b.txt"}},
+		{name: "NothingGone", src: []string{"a.txt", "b.txt", "c.txt", "d.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			dstDir := filepath.Join(tmpDir, "dst")
+
+			require.NoError(t, os.MkdirAll(srcDir, 0755))
+			require.NoError(t, os.MkdirAll(filepath.Join(dstDir, "sub"), 0755))
+			for _, name := range tt.src {
+				require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte("new"), 0644))
+			}
+			// Files inside a directory missing from the source count too.
+			dstFiles := []string{"a.txt", "b.txt", "sub/c.txt", "sub/d.txt"}
+			for _, name := range dstFiles {
+				require.NoError(t, os.WriteFile(filepath.Join(dstDir, name), []byte("old"), 0644))
+			}
+			if tt.name == "NothingGone" {
+				require.NoError(t, os.MkdirAll(filepath.Join(srcDir, "sub"), 0755))
+				require.NoError(t, os.Rename(filepath.Join(srcDir, "c.txt"), filepath.Join(srcDir, "sub", "c.txt")))
+				require.NoError(t, os.Rename(filepath.Join(srcDir, "d.txt"), filepath.Join(srcDir, "sub", "d.txt")))
+			}
+
+			s, err := New(ctx, WithDelete(true), WithMaxDeleteRatio(0.5))
+			require.NoError(t, err)
+			_, err = s.SyncWithStats(ctx, srcDir, dstDir)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrTooManyDeletes)
+			// Nothing was touched, not even the files that would be updated.
+			for _, name := range dstFiles {
+				content, err := os.ReadFile(filepath.Join(dstDir, name))
+				require.NoError(t, err)
+				require.Equal(t, "old", string(content), name)
+			}
+		})
+	}
+}
+
+func TestSync_MaxDeleteRatio_NewDestination(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	require.NoError(t, os.MkdirAll(srcDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("new"), 0644))
+
+	// The first backup, to a destination that doesn't exist yet.
+	s, err := New(ctx, WithDelete(true), WithMaxDeleteRatio(0))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, filepath.Join(tmpDir, "dst")))
+	require.NoError(t, s.Sync(ctx, srcDir, ":memory:max-delete-ratio/volume"))
+}
+
+func TestSyncWithStats(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+
+	require.NoError(t, os.MkdirAll(srcDir, 0755))
+	require.NoError(t, os.MkdirAll(dstDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "b.txt"), []byte("world!"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "stale.txt"), []byte("stale"), 0644))
+
+	s, err := New(ctx, WithDelete(true))
+	require.NoError(t, err)
+
+	stats, err := s.SyncWithStats(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, int64(11), stats.Bytes)
+	require.Equal(t, int64(2), stats.Transfers)
+	require.Equal(t, int64(1), stats.Deletes)
+
+	// Each sync is counted separately.
+	stats, err = s.SyncWithStats(ctx, srcDir, dstDir)
+	require.NoError(t, err)
+	require.Equal(t, Stats{Duration: stats.Duration}, stats)
+}
+
+func TestSync_ReportsEveryFailure(t *testing.T) {
+	tests := []struct {
+		name         string
+		failFast     bool
+		wantFailures int
+	}{
+		{name: "CarryOn", failFast: false, wantFailures: 3},
+		{name: "FailFast", failFast: true, wantFailures: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			dstDir := filepath.Join(tmpDir, "dst")
+
+			// A non-empty directory in the way of each file makes every
+			// download fail.
+			names := []string{"a.txt", "b.txt", "c.txt"}
+			require.NoError(t, os.MkdirAll(srcDir, 0755))
+			for _, name := range names {
+				require.NoError(t, os.MkdirAll(filepath.Join(dstDir, name), 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(dstDir, name, "child"), []byte("x"), 0644))
+				require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte("hello"), 0644))
+			}
+
+			// One transfer at a time, so fail-fast has nothing else in flight.
+			s, err := New(ctx, WithConcurrency(1), WithFailFast(tt.failFast))
+			require.NoError(t, err)
+
+			err = s.Sync(ctx, srcDir, dstDir)
+			require.Error(t, err)
+
+			failed := 0
+			for _, name := range names {
+				if strings.Contains(err.Error(), name+":") {
+					failed++
+				}
+			}
+			require.Equal(t, tt.wantFailures, failed, err.Error())
+		})
+	}
+}
+
+func TestSync_ObjectTags(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "data.db"), []byte("hello"), 0644))
+
+	s, err := New(ctx, WithObjectTags(map[string]string{"app": "my app", "env": "prod", "owner": "a&b=c"}))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, src, s3.remote("vol")))
+
+	put := s3.uploaded("vol/data.db")
+	require.NotNil(t, put, "data.db was never uploaded")
+	require.Equal(t, "app=my%20app&env=prod&owner=a%26b%3Dc", put.Get("X-Amz-Tagging"))
+
+	// An unchanged file isn't uploaded again, with the same tags or others.
+	puts := s3.putCount()
+	require.NoError(t, s.Sync(ctx, src, s3.remote("vol")))
+	s, err = New(ctx, WithObjectTags(map[string]string{"env": "staging"}))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, src, s3.remote("vol")))
+	require.Equal(t, puts, s3.putCount())
+
+	// Restores don't trip over the tags.
+	dst := t.TempDir()
+	require.NoError(t, s.Sync(ctx, s3.remote("vol"), dst))
+	got, err := os.ReadFile(filepath.Join(dst, "data.db"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(got))
+}
+
+func TestSync_KeysUseForwardSlashes(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	for _, name := range []string{"top.txt", filepath.Join("a", "mid.txt"), filepath.Join("a", "b", "deep.txt")} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(srcDir, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644))
+	}
+
+	s, err := New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// Keys are the same whatever the separator of the OS backed up from, so
+	// a backup restores anywhere.
+	s3.mu.Lock()
+	puts := slices.Sorted(slices.Values(s3.puts))
+	s3.mu.Unlock()
+	require.Equal(t, []string{"vol/a/b/deep.txt", "vol/a/mid.txt", "vol/top.txt"}, puts)
+
+	// And they come back with the OS separator.
+	restoreDir := t.TempDir()
+	require.NoError(t, s.Sync(ctx, s3.remote("vol"), restoreDir))
+	require.FileExists(t, filepath.Join(restoreDir, "a", "b", "deep.txt"))
+}
+
+func TestSync_DeletesToS3(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	for i := range 20 {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, fmt.Sprintf("%02d.txt", i)), []byte("data"), 0644))
+	}
+	s, err := New(ctx, WithDelete(true), WithConcurrency(4))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// Prune the source down to one file, with two of the deletes refused.
+	for i := 1; i < 20; i++ {
+		require.NoError(t, os.Remove(filepath.Join(srcDir, fmt.Sprintf("%02d.txt", i))))
+	}
+	s3.slowDeletes(20*time.Millisecond, "vol/03.txt", "vol/11.txt")
+
+	err = s.Sync(ctx, srcDir, s3.remote("vol"))
+
+	// Every failed delete is reported, not just the first.
+	require.Error(t, err)
+	require.ErrorContains(t, err, "03.txt")
+	require.ErrorContains(t, err, "11.txt")
+
+	// Deletes run in parallel, but no more at once than the concurrency.
+	require.Greater(t, s3.maxDeletes.Load(), int32(1))
+	require.LessOrEqual(t, s3.maxDeletes.Load(), int32(4))
+
+	// Everything else was deleted.
+	s3.mu.Lock()
+	defer s3.mu.Unlock()
+	var left []string
+	for key := range s3.objects {
+		left = append(left, key)
+	}
+	require.ElementsMatch(t, []string{"vol/00.txt", "vol/03.txt", "vol/11.txt"}, left)
+}
+
+func TestSync_SkipRemoteDeletes(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "kept.txt"), []byte("data"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "removed.txt"), []byte("data"), 0644))
+	s, err := New(ctx, WithDelete(true), WithSkipRemoteDeletes(true))
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// A backup leaves the bucket's copy of a removed file alone.
+	require.NoError(t, os.Remove(filepath.Join(srcDir, "removed.txt")))
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+	require.NotNil(t, s3.uploaded("vol/removed.txt"))
+
+	// A restore still deletes what the bucket doesn't have.
+	restoreDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(restoreDir, "extra.txt"), []byte("data"), 0644))
+	require.NoError(t, s.Sync(ctx, s3.remote("vol"), restoreDir))
+	require.NoFileExists(t, filepath.Join(restoreDir, "extra.txt"))
+	require.FileExists(t, filepath.Join(restoreDir, "removed.txt"))
+}
+
+func TestSync_CancelStopsS3Listing(t *testing.T) {
+	s3 := newFakeS3(t)
+	for i := range 100 {
+		s3.objects[fmt.Sprintf("vol/%03d.txt", i)] = fakeS3Object{body: []byte("data"), modified: time.Now()}
+	}
+	s3.listPageSize = 10
+
+	// Left alone, a restore pages through the whole listing.
+	s, err := New(context.Background())
+	require.NoError(t, err)
+	dst := t.TempDir()
+	require.NoError(t, s.Sync(context.Background(), s3.remote("vol"), dst))
+	entries, err := os.ReadDir(dst)
+	require.NoError(t, err)
+	require.Len(t, entries, 100)
+	require.Equal(t, 10, s3.listCount())
+
+	// The sync is cancelled, say by a shutdown, while the first page of the
+	// source is being served.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s3.setOnList(func(int) { cancel() })
+
+	err = s.Sync(ctx, s3.remote("vol"), t.TempDir())
+
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, s3.listCount()-10, "listing carried on after the sync was cancelled")
+}
+
+func TestSync_ModifyWindow(t *testing.T) {
+	tests := []struct {
+		name       string
+		window     time.Duration
+		wantCopied bool
+	}{
+		{name: "Default", wantCopied: true},
+		{name: "WithinWindow", window: time.Second, wantCopied: false},
+		{name: "OutsideWindow", window: 100 * time.Millisecond, wantCopied: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tmpDir := t.TempDir()
+			srcDir := filepath.Join(tmpDir, "src")
+			dstDir := filepath.Join(tmpDir, "dst")
+			require.NoError(t, os.MkdirAll(srcDir, 0755))
+			require.NoError(t, os.MkdirAll(dstDir, 0755))
+
+			// Same size, different content, and modification times a fraction
+			// of a second apart, as with a destination that rounds them.
+			mtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.db"), []byte("new"), 0644))
+			require.NoError(t, os.Chtimes(filepath.Join(srcDir, "data.db"), mtime, mtime.Add(400*time.Millisecond)))
+			require.NoError(t, os.WriteFile(filepath.Join(dstDir, "data.db"), []byte("old"), 0644))
+			require.NoError(t, os.Chtimes(filepath.Join(dstDir, "data.db"), mtime, mtime))
+
+			s, err := New(ctx, WithModifyWindow(tt.window))
+			require.NoError(t, err)
+			stats, err := s.SyncWithStats(ctx, srcDir, dstDir)
+			require.NoError(t, err)
+
+			got, err := os.ReadFile(filepath.Join(dstDir, "data.db"))
+			require.NoError(t, err)
+			if tt.wantCopied {
+				require.Equal(t, "new", string(got))
+				require.Equal(t, int64(1), stats.Transfers)
+			} else {
+				require.Equal(t, "old", string(got))
+				require.Zero(t, stats.Transfers)
+			}
+		})
+	}
+}
+
+func TestSync_PreserveModTime(t *testing.T) {
+	lastModified := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	rcloneMtime := time.Date(2024, 2, 1, 8, 15, 0, 123456789, time.UTC)
+
+	s3 := newFakeS3(t)
+	// An object uploaded by another tool, with nothing but its LastModified,
+	// and one uploaded by rclone, which keeps the original time in metadata.
+	s3.objects["vol/plain.txt"] = fakeS3Object{body: []byte("plain"), modified: lastModified}
+	s3.objects["vol/rclone.txt"] = fakeS3Object{
+		body:     []byte("rclone"),
+		header:   http.Header{"X-Amz-Meta-Mtime": {fmt.Sprintf("%d.%09d", rcloneMtime.Unix(), rcloneMtime.Nanosecond())}},
+		modified: lastModified,
+	}
+
+	tests := []struct {
+		name     string
+		preserve bool
+	}{
+		{name: "On", preserve: true},
+		{name: "Off", preserve: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			dstDir := t.TempDir()
+			s, err := New(ctx, WithPreserveModTime(tt.preserve))
+			require.NoError(t, err)
+			require.NoError(t, s.Sync(ctx, s3.remote("vol"), dstDir))
+
+			plain, err := os.Stat(filepath.Join(dstDir, "plain.txt"))
+			require.NoError(t, err)
+			fromRclone, err := os.Stat(filepath.Join(dstDir, "rclone.txt"))
+			require.NoError(t, err)
+			if tt.preserve {
+				require.True(t, lastModified.Equal(plain.ModTime()), "got %s", plain.ModTime())
+				require.True(t, rcloneMtime.Equal(fromRclone.ModTime()), "got %s", fromRclone.ModTime())
+			} else {
+				require.WithinDuration(t, time.Now(), plain.ModTime(), time.Minute)
+				require.WithinDuration(t, time.Now(), fromRclone.ModTime(), time.Minute)
+			}
+		})
+	}
+}
+
+func TestSync_ModTimeRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	mtime := time.Date(2023, 7, 14, 18, 45, 12, 987654321, time.UTC)
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.db"), []byte("hello"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(srcDir, "data.db"), mtime, mtime))
+
+	s, err := New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// The original time travels as metadata, LastModified being the time of
+	// the upload.
+	put := s3.uploaded("vol/data.db")
+	require.NotNil(t, put, "data.db was never uploaded")
+	require.Equal(t, "1689360312.987654321", put.Get("X-Amz-Meta-Mtime"))
+
+	// A restore brings it back to the nanosecond.
+	dstDir := t.TempDir()
+	require.NoError(t, s.Sync(ctx, s3.remote("vol"), dstDir))
+	info, err := os.Stat(filepath.Join(dstDir, "data.db"))
+	require.NoError(t, err)
+	require.True(t, mtime.Equal(info.ModTime()), "got %s", info.ModTime())
+
+	// And the restored volume counts as unchanged on the next backup.
+	puts := s3.putCount()
+	require.NoError(t, s.Sync(ctx, dstDir, s3.remote("vol")))
+	require.Equal(t, puts, s3.putCount())
+}
@@ -0,0 +1,51 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// listWatchFs is a filesystem that notes whether any of its directories
+// failed to list. rclone returns only the last error of a sync, so it can't
+// tell on its own whether a sync that skipped unreadable files also missed a
+// directory.
+type listWatchFs struct {
+	fs.Fs
+	failed atomic.Bool
+}
+
+func (f *listWatchFs) List(ctx context.Context, dir string) (fs.DirEntries, error) {
+	entries, err := f.Fs.List(ctx, dir)
+	f.record(err)
+	return entries, err
+}
+
+// Features passes the paged and recursive listings of the wrapped filesystem
+// through, watching them too.
+func (f *listWatchFs) Features() *fs.Features {
+	ft := *f.Fs.Features()
+	if listP := ft.ListP; listP != nil {
+		ft.ListP = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			err := listP(ctx, dir, callback)
+			f.record(err)
+			return err
+		}
+	}
+	if listR := ft.ListR; listR != nil {
+		ft.ListR = func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+			err := listR(ctx, dir, callback)
+			f.record(err)
+			return err
+		}
+	}
+	return &ft
+}
+
+func (f *listWatchFs) record(err error) {
+	if err != nil && !errors.Is(err, context.Canceled) {
+		f.failed.Store(true)
+	}
+}
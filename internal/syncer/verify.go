@@ -0,0 +1,148 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// VerifyMode selects how a sync is checked once it completes.
+type VerifyMode string
+
+const (
+	// VerifyNone skips the check.
+	VerifyNone VerifyMode = ""
+	// VerifySize checks that every source file has a copy of the same size.
+	VerifySize VerifyMode = "size"
+	// VerifyChecksum also compares checksums, where both sides have one in
+	// common. Local files are read in full to compute theirs.
+	VerifyChecksum VerifyMode = "checksum"
+)
+
+// ErrVerifyFailed is returned when a destination doesn't hold a faithful copy
+// of every file in the source.
+var ErrVerifyFailed = errors.New("verification failed")
+
+// maxReportedFiles caps the files named in a verification error.
+const maxReportedFiles = 10
+
+// WithVerify checks each sync once it completes by listing the destination
+// again and comparing it with the source, see Verify. This catches uploads
+// that reported success but didn't land.
+func WithVerify(mode VerifyMode) Option {
+	return func(s *Syncer) {
+		s.verify = mode
+	}
+}
+
+// Verify checks that every file in src, as selected by the syncer's filters,
+// has a copy in dst of the same size and, with VerifyChecksum, the same
+// checksum. Files only in dst are ignored. A mismatch returns an error
+// wrapping ErrVerifyFailed that names the files concerned.
+func (s *Syncer) Verify(ctx context.Context, src, dst string) error {
+	ctx, end, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	ctx = s.withConfig(ctx)
+	logger := s.logger.With("src", src, "dst", dst)
+	srcRemote, err := s.symlinkOptions(src)
+	if err != nil {
+		return err
+	}
+	srcFs, err := fs.NewFs(ctx, srcRemote)
+	if err != nil {
+		return fmt.Errorf("failed to create source fs: %w", err)
+	}
+	srcFs = s.wrapSymlinks(logger, srcFs)
+	dstFs, err := fs.NewFs(ctx, dst)
+	if err != nil {
+		return fmt.Errorf("failed to create destination fs: %w", err)
+	}
+	fi, err := s.filterFor(syncDirection(srcFs, dstFs))
+	if err != nil {
+		return fmt.Errorf("failed to create filter: %w", err)
+	}
+	return s.verifyFs(filter.ReplaceConfig(ctx, fi), logger, srcFs, dstFs)
+}
+
+// verifyFs is Verify for filesystems already created, with ctx carrying the
+// syncer's config and filter.
+func (s *Syncer) verifyFs(ctx context.Context, logger *slog.Logger, srcFs, dstFs fs.Fs) error {
+	ctx, ci := fs.AddConfig(ctx)
+	ci.SizeOnly = s.verifyMode() == VerifySize
+
+	var missing, differ, failed bytes.Buffer
+	err := operations.Check(ctx, &operations.CheckOpt{
+		Fsrc:         srcFs,
+		Fdst:         dstFs,
+		OneWay:       true,
+		MissingOnDst: &missing,
+		Differ:       &differ,
+		Error:        &failed,
+	})
+	if err == nil {
+		logger.Info("Verification passed", "mode", s.verifyMode())
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	var problems []string
+	for _, r := range []struct {
+		what  string
+		files []string
+	}{
+		{"missing", reportedFiles(&missing)},
+		{"differing", reportedFiles(&differ)},
+		{"failed to check", reportedFiles(&failed)},
+	} {
+		if len(r.files) > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s (%s)", len(r.files), r.what, summariseFiles(r.files)))
+		}
+	}
+	if len(problems) == 0 {
+		// Listing either side failed, so nothing could be compared.
+		return fmt.Errorf("%w: %w", ErrVerifyFailed, err)
+	}
+	logger.Error("Verification failed", "mode", s.verifyMode(), "problems", problems)
+	return fmt.Errorf("%w: %s", ErrVerifyFailed, strings.Join(problems, ", "))
+}
+
+// verifyMode returns the check Verify makes, which is by size unless asked
+// for checksums.
+func (s *Syncer) verifyMode() VerifyMode {
+	if s.verify == VerifyChecksum {
+		return VerifyChecksum
+	}
+	return VerifySize
+}
+
+// reportedFiles returns the file names rclone's check wrote to b, one a line.
+func reportedFiles(b *bytes.Buffer) []string {
+	var files []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// summariseFiles joins files, naming at most maxReportedFiles of them.
+func summariseFiles(files []string) string {
+	if len(files) <= maxReportedFiles {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(files[:maxReportedFiles], ", "), len(files)-maxReportedFiles)
+}
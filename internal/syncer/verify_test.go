@@ -0,0 +1,95 @@
+package syncer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	s3 := newFakeS3(t)
+	srcDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(srcDir, name), []byte("data"), 0644))
+	}
+	s, err := New(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.Sync(ctx, srcDir, s3.remote("vol")))
+
+	// A faithful copy, with extra files at the destination, passes.
+	s3.objects["vol/extra.txt"] = fakeS3Object{body: []byte("extra")}
+	require.NoError(t, s.Verify(ctx, srcDir, s3.remote("vol")))
+
+	// A missing and a truncated object are both named.
+	delete(s3.objects, "vol/a.txt")
+	b := s3.objects["vol/b.txt"]
+	b.body = []byte("da")
+	s3.objects["vol/b.txt"] = b
+
+	err = s.Verify(ctx, srcDir, s3.remote("vol"))
+	require.ErrorIs(t, err, ErrVerifyFailed)
+	require.ErrorContains(t, err, "1 missing (a.txt)")
+	require.ErrorContains(t, err, "1 differing (b.txt)")
+	require.NotContains(t, err.Error(), "c.txt")
+}
+
+func TestSync_Verify(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    VerifyMode
+		wantErr bool
+	}{
+		{name: "Off", mode: VerifyNone},
+		{name: "Size", mode: VerifySize, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s3 := newFakeS3(t)
+			srcDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("data"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(srcDir, "b.txt"), []byte("data"), 0644))
+
+			// The upload of b.txt is acknowledged but never shows up.
+			s3.unlisted = map[string]bool{"vol/b.txt": true}
+
+			s, err := New(ctx, WithVerify(tt.mode))
+			require.NoError(t, err)
+			err = s.Sync(ctx, srcDir, s3.remote("vol"))
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrVerifyFailed)
+				require.ErrorContains(t, err, "b.txt")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestVerify_Checksum(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	srcDir := filepath.Join(tmpDir, "src")
+	dstDir := filepath.Join(tmpDir, "dst")
+	for _, dir := range []string{srcDir, dstDir} {
+		require.NoError(t, os.MkdirAll(dir, 0755))
+	}
+	// Same size, different content.
+	require.NoError(t, os.WriteFile(filepath.Join(srcDir, "data.db"), []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dstDir, "data.db"), []byte("old"), 0644))
+
+	s, err := New(ctx, WithVerify(VerifySize))
+	require.NoError(t, err)
+	require.NoError(t, s.Verify(ctx, srcDir, dstDir))
+
+	s, err = New(ctx, WithVerify(VerifyChecksum))
+	require.NoError(t, err)
+	err = s.Verify(ctx, srcDir, dstDir)
+	require.ErrorIs(t, err, ErrVerifyFailed)
+	require.ErrorContains(t, err, "1 differing (data.db)")
+}